@@ -37,6 +37,57 @@ function prettyJSONString(inputString) {
   return JSON.stringify(JSON.parse(inputString), null, 2);
 }
 
+// HTTP status for each error code of the chaincode's ContractError
+const contractErrorStatus = {
+  NOT_FOUND: 404,
+  ALREADY_EXISTS: 409,
+  FORBIDDEN: 403,
+  INVALID_STATE: 409,
+  VALIDATION_FAILED: 400,
+  INTERNAL: 500,
+};
+
+// parseContractError finds the {"code", "message", "details"} JSON the chaincode returns in a failed
+// transaction. Failures without one, such as an unreachable peer, are reported as INTERNAL.
+function parseContractError(error) {
+  const text = `${(error && error.message) || error}`;
+  const start = text.indexOf('{"code":');
+  if (start >= 0) {
+    let depth = 0;
+    let inString = false;
+    for (let i = start; i < text.length; i++) {
+      const c = text[i];
+      if (inString) {
+        if (c === "\\") i++;
+        else if (c === '"') inString = false;
+      } else if (c === '"') {
+        inString = true;
+      } else if (c === "{") {
+        depth++;
+      } else if (c === "}" && --depth === 0) {
+        try {
+          return JSON.parse(text.slice(start, i + 1));
+        } catch (e) {
+          break;
+        }
+      }
+    }
+  }
+  return { code: "INTERNAL", message: text, details: {} };
+}
+
+// sendError answers a failed request with the HTTP status of the chaincode error code, adding the code and
+// details so that the UI can show a localized message
+function sendError(res, error, body) {
+  const contractError = parseContractError(error);
+  res.status(contractErrorStatus[contractError.code] || 500).send({
+    ...body,
+    success: false,
+    code: contractError.code,
+    details: contractError.details,
+  });
+}
+
 const crypto = require("crypto");
 const express = require("express");
 const bodyParser = require("body-parser");
@@ -349,8 +400,7 @@ app.get("/getAllProducts", async (req, res) => {
     }
   } catch (error) {
     console.error(`Failed to get products: ${error}`);
-    res.status(500).send({
-      success: false,
+    sendError(res, error, {
       message: `Failed to get products: ${error}`,
     });
   }
@@ -386,13 +436,61 @@ app.post("/getProductListByManufacturerID", async (req, res) => {
     }
   } catch (error) {
     console.error(`Failed to get products: ${error}`);
-    res.status(500).send({
-      success: false,
+    sendError(res, error, {
       message: `Failed to get products: ${error}`,
     });
   }
 });
 
+app.post("/getManufacturerMetrics", async (req, res) => {
+  var username = req.body.userName;
+  var period = req.body.period || "day";
+
+  try {
+    console.log(
+      "\n--> Evaluate Transaction: GetManufacturerMetrics, function returns the dashboard metrics of a manufacturer"
+    );
+    let result = await contract.evaluateTransaction(
+      "GetManufacturerMetrics",
+      username,
+      period
+    );
+
+    res.status(200).send({
+      success: true,
+      message: "Metrics loaded Successfully.",
+      data: JSON.parse(result.toString()),
+    });
+  } catch (error) {
+    console.error(`Failed to get metrics: ${error}`);
+    sendError(res, error, {
+      message: `Failed to get metrics: ${error}`,
+    });
+  }
+});
+
+app.post("/getStatusCounts", async (req, res) => {
+  var username = req.body.userName || "";
+
+  try {
+    console.log(
+      "\n--> Evaluate Transaction: GetStatusCounts, function returns the number of products in each status"
+    );
+    let result = await contract.evaluateTransaction("GetStatusCounts", username);
+
+    res.status(200).send({
+      success: true,
+      message: "Status counts loaded Successfully.",
+      data: JSON.parse(result.toString()),
+    });
+  } catch (error) {
+    console.error(`Failed to get status counts: ${error}`);
+    sendError(res, error, {
+      message: `Failed to get status counts: ${error}`,
+    });
+  }
+});
+
 app.get("/registerUser", async (req, res) => {
   console.log("\n--> Evaluate Transaction: Register User...");
 
@@ -472,12 +570,21 @@ app.get("/createProduct", async (req, res) => {
   console.log("\n--> Submit Transaction: Creating Product...");
 
   try {
-    await contract.submitTransaction("CreateProduct", "3", "beer", "good");
+    let productId = await contract.submitTransaction(
+      "CreateProductWithGeneratedID",
+      "",
+      "beer",
+      "good",
+      "10.00",
+      "admin",
+      getCurrentDate()
+    );
+    productId = productId.toString();
 
-    console.log(`Successfully created product with id 3!`);
-    res.status(200).send(`Successfully created product with id 3!`);
+    console.log(`Successfully created product with id ${productId}!`);
+    res.status(200).send(`Successfully created product with id ${productId}!`);
   } catch (error) {
-    console.error(`Failed to create product 3: ${error}`);
+    console.error(`Failed to create product: ${error}`);
   }
 });
 
@@ -689,32 +796,47 @@ app.post("/createProduct", async (req, res) => {
     var createdDate = moment(req.body.createdDate).format(
       "MMMM Do YYYY, h:mm:ss a"
     );
-    var productId = generateUniqueHash(
-      username + productName + productDescription
-    );
+    var productId = req.body.productId;
 
     console.log(username, productName, productDescription, productId);
 
-    // id string, name string, description string, manufacturer string, createddate string
-    let txn = await contract.submitTransaction(
-      "CreateProduct",
-      productId,
-      productName,
-      productDescription,
-      productPrice,
-      username,
-      createdDate
-    );
-
-    txn = txn.toString();
+    if (productId) {
+      // id string, name string, description string, price string, manufacturer string, createddate string
+      await contract.submitTransaction(
+        "CreateProduct",
+        productId,
+        productName,
+        productDescription,
+        productPrice,
+        username,
+        createdDate
+      );
+    } else {
+      // Without a client ID the contract derives one from the transaction ID, after the optional prefix
+      productId = await contract.submitTransaction(
+        "CreateProductWithGeneratedID",
+        req.body.idPrefix || "",
+        productName,
+        productDescription,
+        productPrice,
+        username,
+        createdDate
+      );
+      productId = productId.toString();
+    }
 
-    console.log(`Successfully created product with id 5!`);
-    console.log(txn);
-    res
-      .status(200)
-      .send({ success: true, message: "Created product successfully!", txn });
+    console.log(`Successfully created product with id ${productId}!`);
+    res.status(200).send({
+      success: true,
+      message: "Created product successfully!",
+      productId,
+    });
   } catch (error) {
-    console.error(`Failed to create product 3: ${error}`);
+    console.error(`Failed to create product: ${error}`);
+    sendError(res, error, {
+      message: `Fail to create product: ${error}`,
+      error: `${error}`,
+    });
   }
 });
 
@@ -762,9 +884,10 @@ app.post("/updateProduct", async (req, res) => {
       .send({ success: true, message: "Updated product successfully!", txn });
   } catch (error) {
     console.error(`Failed to update product : ${error}`);
-    res
-      .status(500)
-      .send({ success: false, message: `Fail to update product : ${error}` });
+    sendError(res, error, {
+      message: `Fail to update product : ${error}`,
+      error: `${error}`,
+    });
   }
 });
 
@@ -795,8 +918,7 @@ app.post("/orderProduct", async (req, res) => {
     });
   } catch (error) {
     console.error(`Failed to order product with id ${token}: ${error}`);
-    res.status(500).send({
-      success: false,
+    sendError(res, error, {
       message: `Fail to order product with id ${token}:${error}`,
       error: `${error}`,
     });
@@ -830,8 +952,7 @@ app.post("/acceptProductOrder", async (req, res) => {
     });
   } catch (error) {
     console.error(`Failed to accept product order with id ${token}: ${error}`);
-    res.status(500).send({
-      success: false,
+    sendError(res, error, {
       message: `Fail to accept product order with id ${token}:${error}`,
       error: `${error}`,
     });
@@ -865,14 +986,112 @@ app.post("/shipProductOrder", async (req, res) => {
     console.error(
       `Failed to ship product order with id ${token.token}: ${error}`
     );
-    res.status(500).send({
-      success: false,
+    sendError(res, error, {
       message: `Fail to ship product order with id ${token.token}:${error}`,
       error: `${error}`,
     });
   }
 });
 
+app.post("/createProductsBatch", async (req, res) => {
+  console.log("\n--> Submit Transaction: Creating Products Batch...");
+
+  var products = req.body.products || [];
+
+  try {
+    var username = req.body.userName;
+    var createdDate = getCurrentDate();
+
+    // All products are created in one transaction, or none of them if any is rejected
+    var batch = products.map((product, index) => ({
+      ID: generateUniqueHash(
+        username + product.productName + product.productDescription + index
+      ),
+      Name: product.productName,
+      Description: product.productDescription,
+      Price: product.productPrice,
+      Manufacturer: username,
+      CreatedDate: createdDate,
+    }));
+
+    await contract.submitTransaction(
+      "CreateProductsBatch",
+      JSON.stringify(batch)
+    );
+
+    console.log(`Successfully created ${batch.length} products!`);
+    res.status(200).send({
+      success: true,
+      message: `Successfully created ${batch.length} products!`,
+      ids: batch.map((product) => product.ID),
+    });
+  } catch (error) {
+    console.error(`Failed to create ${products.length} products: ${error}`);
+    sendError(res, error, {
+      message: `Fail to create ${products.length} products: ${error}`,
+      error: `${error}`,
+    });
+  }
+});
+
+app.post("/acceptProductOrders", async (req, res) => {
+  console.log("\n--> Submit Transaction: Accepting Product Orders...");
+
+  var tokens = req.body.tokens || [];
+
+  try {
+    var userName = req.body.userName;
+    let modifiedDate = getCurrentDate();
+
+    await contract.submitTransaction(
+      "ProductAcceptBatch",
+      JSON.stringify(tokens),
+      userName,
+      modifiedDate
+    );
+
+    console.log(`Successfully accepted ${tokens.length} product orders!`);
+    res.status(200).send({
+      success: true,
+      message: `Successfully accepted ${tokens.length} product orders!`,
+    });
+  } catch (error) {
+    console.error(`Failed to accept ${tokens.length} product orders: ${error}`);
+    sendError(res, error, {
+      message: `Fail to accept ${tokens.length} product orders: ${error}`,
+      error: `${error}`,
+    });
+  }
+});
+
+app.post("/shipProductOrders", async (req, res) => {
+  console.log("\n--> Submit Transaction: Shipping Product Orders...");
+
+  var tokens = req.body.tokens || [];
+
+  try {
+    let modifiedDate = getCurrentDate();
+
+    await contract.submitTransaction(
+      "ProductShipBatch",
+      JSON.stringify(tokens),
+      modifiedDate
+    );
+
+    console.log(`Successfully shipped ${tokens.length} product orders!`);
+    res.status(200).send({
+      success: true,
+      message: `Successfully shipped ${tokens.length} product orders!`,
+    });
+  } catch (error) {
+    console.error(`Failed to ship ${tokens.length} product orders: ${error}`);
+    sendError(res, error, {
+      message: `Fail to ship ${tokens.length} product orders: ${error}`,
+      error: `${error}`,
+    });
+  }
+});
+
 app.post("/deliverProductOrder", async (req, res) => {
   console.log("\n--> Submit Transaction: Delivering Product Order...");
 
@@ -902,14 +1121,89 @@ app.post("/deliverProductOrder", async (req, res) => {
     console.error(
       `Failed to deliver product order with id ${token.token}: ${error}`
     );
-    res.status(500).send({
-      success: false,
+    sendError(res, error, {
       message: `Fail to deliver product order with id ${token.token}:${error}`,
       error: `${error}`,
     });
   }
 });
 
+app.post("/rateOrder", async (req, res) => {
+  console.log("\n--> Submit Transaction: Rating Order...");
+
+  var token = req.body.token;
+  var productScore = req.body.productScore;
+  var manufacturerScore = req.body.manufacturerScore;
+  var commentHash = req.body.commentHash || "";
+
+  try {
+    await contract.submitTransaction(
+      "RateOrder",
+      token,
+      `${productScore}`,
+      `${manufacturerScore}`,
+      commentHash
+    );
+
+    console.log(`Successfully rated order of product with id ${token}!`);
+    res.status(200).send({
+      success: true,
+      message: `Successfully rated order of product with id ${token}!`,
+    });
+  } catch (error) {
+    console.error(`Failed to rate order of product with id ${token}: ${error}`);
+    sendError(res, error, {
+      message: `Fail to rate order of product with id ${token}:${error}`,
+      error: `${error}`,
+    });
+  }
+});
+
+app.get("/getManufacturerReputation/:name", async (req, res) => {
+  console.log("\n--> Evaluate Transaction: Reading Manufacturer Reputation...");
+
+  var name = req.params.name;
+
+  try {
+    let result = await contract.evaluateTransaction(
+      "GetManufacturerReputation",
+      name
+    );
+
+    res
+      .status(200)
+      .send({ success: true, result: JSON.parse(result.toString()) });
+  } catch (error) {
+    console.error(`Failed to read reputation of ${name}: ${error}`);
+    sendError(res, error, {
+      message: `Failed to read reputation of ${name}: ${error}`,
+      error: `${error}`,
+    });
+  }
+});
+
+// The contract metadata lists the parameters of every transaction with their validation rules: minLength 1
+// for required parameters, maxLength, pattern and format
+app.get("/getContractMetadata", async (req, res) => {
+  console.log("\n--> Evaluate Transaction: Reading Contract Metadata...");
+
+  try {
+    let result = await contract.evaluateTransaction(
+      "org.hyperledger.fabric:GetMetadata"
+    );
+
+    res
+      .status(200)
+      .send({ success: true, result: JSON.parse(result.toString()) });
+  } catch (error) {
+    console.error(`Failed to read contract metadata: ${error}`);
+    sendError(res, error, {
+      message: `Failed to read contract metadata: ${error}`,
+      error: `${error}`,
+    });
+  }
+});
+
 app.post("/getOrderedProductList", async (req, res) => {
   console.log("\n--> Evaluate Transaction: Reading Ordered Product...");
 
@@ -927,8 +1221,7 @@ app.post("/getOrderedProductList", async (req, res) => {
     console.log(`Successfully read ordered product.`);
   } catch (error) {
     console.error(`Failed to read products : ${error}`);
-    res.status(500).send({
-      success: false,
+    sendError(res, error, {
       message: `Failed to read products: ${error}`,
       error: `${error}`,
     });
@@ -966,8 +1259,7 @@ app.post("/getOrderRequestedProductList", async (req, res) => {
     }
   } catch (error) {
     console.error(`Failed to read requested order product: ${error}`);
-    res.status(500).send({
-      success: false,
+    sendError(res, error, {
       message: `Failed to read requested order product: ${error}`,
       error: `${error}`,
     });
@@ -1005,8 +1297,7 @@ app.post("/getConsumerProductOrderList", async (req, res) => {
     }
   } catch (error) {
     console.error(`Failed to read requested order product: ${error}`);
-    res.status(500).send({
-      success: false,
+    sendError(res, error, {
       message: `Failed to read requested order product: ${error}`,
       error: `${error}`,
     });
@@ -1027,14 +1318,34 @@ app.get("/readProduct/:id", async (req, res) => {
     console.log(`Successfully read product with id ${id}!`);
   } catch (error) {
     console.error(`Failed to read product ${id}: ${error}`);
-    res.status(500).send({
-      success: false,
+    sendError(res, error, {
       message: `Failed to read product ${id}: ${error}`,
       error: `${error}`,
     });
   }
 });
 
+app.get("/readShipment/:id", async (req, res) => {
+  console.log("\n--> Evaluate Transaction: Reading Shipment...");
+
+  var id = req.params.id;
+
+  try {
+    let result = await contract.evaluateTransaction("ReadShipment", id);
+
+    res
+      .status(200)
+      .send({ success: true, result: JSON.parse(result.toString()) });
+    console.log(`Successfully read shipment with id ${id}!`);
+  } catch (error) {
+    console.error(`Failed to read shipment ${id}: ${error}`);
+    sendError(res, error, {
+      message: `Failed to read shipment ${id}: ${error}`,
+      error: `${error}`,
+    });
+  }
+});
+
 app.get("/getProductHistory/:id", async (req, res) => {
   console.log("\n--> Evaluate Transaction: Reading Product History...");
 
@@ -1061,8 +1372,7 @@ app.get("/getProductHistory/:id", async (req, res) => {
     });
   } catch (error) {
     console.error(`Failed to read product history ${id}: ${error}`);
-    res.status(500).send({
-      success: false,
+    sendError(res, error, {
       error: `${error}`,
     });
   }
@@ -7,12 +7,21 @@ package main
 import (
 	"log"
 
-	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
 )
 
 func main() {
-	assetChaincode, err := contractapi.NewChaincode(&chaincode.SmartContract{})
+	// SmartContract is registered first so that it stays the default contract and unprefixed function
+	// names, as used by application-javascript, keep working
+	assetChaincode, err := chaincode.NewChaincode(
+		chaincode.NewSmartContract(),
+		chaincode.NewProductContract(),
+		chaincode.NewOrderContract(),
+		chaincode.NewShipmentContract(),
+		chaincode.NewWarehouseContract(),
+		chaincode.NewAdminContract(),
+		chaincode.NewTokenContract(),
+	)
 	if err != nil {
 		log.Panicf("Error creating asset-transfer-basic chaincode: %v", err)
 	}
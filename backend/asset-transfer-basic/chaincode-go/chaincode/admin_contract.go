@@ -0,0 +1,106 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AdminContract groups ledger setup, configuration and maintenance transactions under the "admin:" prefix
+type AdminContract struct {
+	contractapi.Contract
+	core SmartContract
+}
+
+// NewAdminContract returns the admin contract, invoked with the "admin:" prefix
+func NewAdminContract() *AdminContract {
+	return &AdminContract{Contract: newContract("admin")}
+}
+
+// InitLedger adds the sample products to the ledger
+func (c *AdminContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+	return c.core.InitLedger(ctx)
+}
+
+// SetInvoiceTerms sets the tax rate and payment terms of new invoices
+func (c *AdminContract) SetInvoiceTerms(ctx contractapi.TransactionContextInterface, taxRateBasisPoints int, paymentTermsDays int) error {
+	return c.core.SetInvoiceTerms(ctx, taxRateBasisPoints, paymentTermsDays)
+}
+
+// GetInvoiceTerms returns the invoice terms in force
+func (c *AdminContract) GetInvoiceTerms(ctx contractapi.TransactionContextInterface) (*InvoiceTerms, error) {
+	return c.core.GetInvoiceTerms(ctx)
+}
+
+// SetSLATerms sets the default service levels of accepted orders
+func (c *AdminContract) SetSLATerms(ctx contractapi.TransactionContextInterface, shipWithinDays int, deliverWithinDays int) error {
+	return c.core.SetSLATerms(ctx, shipWithinDays, deliverWithinDays)
+}
+
+// GetSLATerms returns the service levels in force
+func (c *AdminContract) GetSLATerms(ctx contractapi.TransactionContextInterface) (*SLATerms, error) {
+	return c.core.GetSLATerms(ctx)
+}
+
+// SetPenaltyTerms sets the late delivery penalty
+func (c *AdminContract) SetPenaltyTerms(ctx contractapi.TransactionContextInterface, rateBasisPointsPerDay int, graceHours int, capBasisPoints int) error {
+	return c.core.SetPenaltyTerms(ctx, rateBasisPointsPerDay, graceHours, capBasisPoints)
+}
+
+// GetPenaltyTerms returns the late delivery penalty in force
+func (c *AdminContract) GetPenaltyTerms(ctx contractapi.TransactionContextInterface) (*PenaltyTerms, error) {
+	return c.core.GetPenaltyTerms(ctx)
+}
+
+// SetGeofencePolicy sets how delivery coordinates are checked against the destination
+func (c *AdminContract) SetGeofencePolicy(ctx contractapi.TransactionContextInterface, radiusMeters float64, mode string, requireLocation bool) error {
+	return c.core.SetGeofencePolicy(ctx, radiusMeters, mode, requireLocation)
+}
+
+// GetGeofencePolicy returns the delivery geofence policy in force
+func (c *AdminContract) GetGeofencePolicy(ctx contractapi.TransactionContextInterface) (*GeofencePolicy, error) {
+	return c.core.GetGeofencePolicy(ctx)
+}
+
+// SetConditionRange sets the allowed temperature and humidity of a product category
+func (c *AdminContract) SetConditionRange(ctx contractapi.TransactionContextInterface, category string, minTemperature float64, maxTemperature float64, minHumidity float64, maxHumidity float64) error {
+	return c.core.SetConditionRange(ctx, category, minTemperature, maxTemperature, minHumidity, maxHumidity)
+}
+
+// GetConditionRange returns the allowed conditions of a product category
+func (c *AdminContract) GetConditionRange(ctx contractapi.TransactionContextInterface, category string) (*ConditionRange, error) {
+	return c.core.GetConditionRange(ctx, category)
+}
+
+// SetIdentifierMode sets how product IDs are validated
+func (c *AdminContract) SetIdentifierMode(ctx contractapi.TransactionContextInterface, mode string) error {
+	return c.core.SetIdentifierMode(ctx, mode)
+}
+
+// GetIdentifierMode returns how product IDs are validated
+func (c *AdminContract) GetIdentifierMode(ctx contractapi.TransactionContextInterface) (string, error) {
+	return c.core.GetIdentifierMode(ctx)
+}
+
+// CompactStatusCounters folds the delta keys of the status counters
+func (c *AdminContract) CompactStatusCounters(ctx contractapi.TransactionContextInterface, manufacturer string) error {
+	return c.core.CompactStatusCounters(ctx, manufacturer)
+}
+
+// RebuildStatusCounters recounts every product from scratch
+func (c *AdminContract) RebuildStatusCounters(ctx contractapi.TransactionContextInterface) error {
+	return c.core.RebuildStatusCounters(ctx)
+}
+
+// GetStatusCounts returns how many products of a manufacturer are in each status
+func (c *AdminContract) GetStatusCounts(ctx contractapi.TransactionContextInterface, manufacturer string) (map[string]int, error) {
+	return c.core.GetStatusCounts(ctx, manufacturer)
+}
+
+// GetManufacturerMetrics returns the dashboard figures of a manufacturer
+func (c *AdminContract) GetManufacturerMetrics(ctx contractapi.TransactionContextInterface, manufacturer string, period string) (*ManufacturerMetrics, error) {
+	return c.core.GetManufacturerMetrics(ctx, manufacturer, period)
+}
+
+// RebuildOrderMetrics recomputes the order counters and invoice index from the product histories
+func (c *AdminContract) RebuildOrderMetrics(ctx contractapi.TransactionContextInterface) error {
+	return c.core.RebuildOrderMetrics(ctx)
+}
@@ -0,0 +1,49 @@
+package chaincode
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Prices are written in major currency units with at most two decimals, for example "12.50". Amounts
+// are handled internally as whole minor units (cents) so that arithmetic on them is exact.
+const amountDecimals = 2
+
+// minorUnitsPerUnit is the number of minor units in one major currency unit
+var minorUnitsPerUnit = int64(math.Pow10(amountDecimals))
+
+// parseAmount converts a price string into minor units
+func parseAmount(value string) (int64, error) {
+	whole, fraction := value, ""
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		whole, fraction = value[:i], value[i+1:]
+	}
+	if whole == "" || len(fraction) > amountDecimals || (strings.Contains(value, ".") && fraction == "") {
+		return 0, errValidation("invalid amount %q, expected a number with at most %d decimals", value, amountDecimals)
+	}
+	for _, part := range []string{whole, fraction} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return 0, errValidation("invalid amount %q, expected a number with at most %d decimals", value, amountDecimals)
+			}
+		}
+	}
+	fraction += strings.Repeat("0", amountDecimals-len(fraction))
+
+	minorUnits, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, errValidation("invalid amount %q: %v", value, err)
+	}
+	return minorUnits, nil
+}
+
+// formatAmount converts minor units back into a price string
+func formatAmount(minorUnits int64) string {
+	sign := ""
+	if minorUnits < 0 {
+		sign, minorUnits = "-", -minorUnits
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, minorUnits/minorUnitsPerUnit, amountDecimals, minorUnits%minorUnitsPerUnit)
+}
@@ -0,0 +1,74 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "0", want: 0},
+		{value: "12", want: 1200},
+		{value: "12.5", want: 1250},
+		{value: "12.50", want: 1250},
+		{value: "0.01", want: 1},
+		{value: "007.10", want: 710},
+		{value: "92233720368547758.07", want: 9223372036854775807},
+		{value: "", wantErr: true},
+		{value: ".50", wantErr: true},
+		{value: "12.", wantErr: true},
+		{value: "12.505", wantErr: true},
+		{value: "-1.00", wantErr: true},
+		{value: "+1.00", wantErr: true},
+		{value: "1,00", wantErr: true},
+		{value: "1.0.0", wantErr: true},
+		{value: " 1.00", wantErr: true},
+		{value: "92233720368547758.08", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseAmount(tt.value)
+			if tt.wantErr {
+				RequireContractError(t, err, CodeValidationFailed)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		minorUnits int64
+		want       string
+	}{
+		{minorUnits: 0, want: "0.00"},
+		{minorUnits: 1, want: "0.01"},
+		{minorUnits: 99, want: "0.99"},
+		{minorUnits: 1250, want: "12.50"},
+		{minorUnits: 100000, want: "1000.00"},
+		{minorUnits: -5, want: "-0.05"},
+		{minorUnits: -1250, want: "-12.50"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			require.Equal(t, tt.want, formatAmount(tt.minorUnits))
+		})
+	}
+}
+
+func TestFormatAmountRoundTrips(t *testing.T) {
+	for _, minorUnits := range []int64{0, 7, 10, 1234, 9223372036854775807} {
+		got, err := parseAmount(formatAmount(minorUnits))
+		require.NoError(t, err)
+		require.Equal(t, minorUnits, got)
+	}
+}
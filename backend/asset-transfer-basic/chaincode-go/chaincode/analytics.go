@@ -0,0 +1,425 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	PeriodDay  = "day"
+	PeriodWeek = "week"
+
+	// orderCountObjectType counts the orders placed with a manufacturer per day in delta keys
+	// [manufacturer, day, txID, productID], like the status counters
+	orderCountObjectType = "orderCount"
+	orderDayFormat       = "2006-01-02"
+
+	// invoiceSellerIndex keys the invoiceIndexEntry of every invoice by [seller, invoiceNumber]
+	invoiceSellerIndex = "seller~invoice"
+)
+
+// ManufacturerMetrics are the dashboard figures of a manufacturer. Lead times are averages in hours over
+// the orders that reached both ends of the interval; revenue is the invoiced amount of delivered orders.
+type ManufacturerMetrics struct {
+	Manufacturer          string         `json:"Manufacturer"`
+	ProductCount          int            `json:"ProductCount"`
+	StatusCounts          map[string]int `json:"StatusCounts"`
+	Period                string         `json:"Period"`
+	OrdersByPeriod        []*PeriodCount `json:"OrdersByPeriod"`
+	AvgOrderToShipHours   float64        `json:"AvgOrderToShipHours"`
+	AvgShipToDeliverHours float64        `json:"AvgShipToDeliverHours"`
+	DeliveredOrders       int            `json:"DeliveredOrders"`
+	Revenue               string         `json:"Revenue"`
+}
+
+// PeriodCount is the number of orders placed in a day ("2006-01-02") or ISO week ("2006-W01")
+type PeriodCount struct {
+	Period string `json:"Period"`
+	Orders int    `json:"Orders"`
+}
+
+// invoiceIndexEntry records the milestones of an invoiced order under its seller in the trade collection,
+// so that the metrics of a manufacturer are read with one range query instead of the history of every
+// product. The entry is written with the invoice and stamped when the order ships and is delivered.
+type invoiceIndexEntry struct {
+	InvoiceNumber string `json:"InvoiceNumber"`
+	ProductID     string `json:"ProductID"`
+	Subtotal      string `json:"Subtotal"`
+	OrderedDate   string `json:"OrderedDate"`
+	ShippedDate   string `json:"ShippedDate"`
+	DeliveredDate string `json:"DeliveredDate"`
+}
+
+// GetManufacturerMetrics returns the product counts by status, orders per day or week, lead times and
+// revenue of a manufacturer. The counts come from the status and order counters and the rest from the
+// seller's invoice index. Orders placed before the counters and the index existed are only included once
+// RebuildOrderMetrics has run.
+func (s *SmartContract) GetManufacturerMetrics(ctx contractapi.TransactionContextInterface, manufacturer string, period string) (*ManufacturerMetrics, error) {
+	if period != PeriodDay && period != PeriodWeek {
+		return nil, errValidation("unknown period %s, expected %s or %s", period, PeriodDay, PeriodWeek)
+	}
+
+	statusCounts, err := readStatusCounts(ctx, manufacturer)
+	if err != nil {
+		return nil, err
+	}
+	ordersByPeriod, err := readOrderCounts(ctx, manufacturer, period)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := readInvoiceIndex(ctx, manufacturer)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := &ManufacturerMetrics{
+		Manufacturer:   manufacturer,
+		StatusCounts:   statusCounts,
+		Period:         period,
+		OrdersByPeriod: []*PeriodCount{},
+	}
+	for _, count := range statusCounts {
+		metrics.ProductCount += count
+	}
+
+	var orderToShip, shipToDeliver time.Duration
+	var shippedOrders, deliveredOrders int
+	var revenue int64
+	for _, entry := range entries {
+		orderedAt, err := parseMilestone(entry.OrderedDate)
+		if err != nil {
+			return nil, err
+		}
+		shippedAt, err := parseMilestone(entry.ShippedDate)
+		if err != nil {
+			return nil, err
+		}
+		deliveredAt, err := parseMilestone(entry.DeliveredDate)
+		if err != nil {
+			return nil, err
+		}
+
+		if !orderedAt.IsZero() && !shippedAt.IsZero() {
+			orderToShip += shippedAt.Sub(orderedAt)
+			shippedOrders++
+		}
+		if deliveredAt.IsZero() {
+			continue
+		}
+		if !shippedAt.IsZero() {
+			shipToDeliver += deliveredAt.Sub(shippedAt)
+			deliveredOrders++
+		}
+		metrics.DeliveredOrders++
+		amount, err := parseAmount(entry.Subtotal)
+		if err != nil {
+			return nil, fmt.Errorf("the invoice %s has an invalid subtotal: %v", entry.InvoiceNumber, err)
+		}
+		revenue += amount
+	}
+
+	for key, orders := range ordersByPeriod {
+		metrics.OrdersByPeriod = append(metrics.OrdersByPeriod, &PeriodCount{Period: key, Orders: orders})
+	}
+	sort.Slice(metrics.OrdersByPeriod, func(i, j int) bool {
+		return metrics.OrdersByPeriod[i].Period < metrics.OrdersByPeriod[j].Period
+	})
+	if shippedOrders > 0 {
+		metrics.AvgOrderToShipHours = orderToShip.Hours() / float64(shippedOrders)
+	}
+	if deliveredOrders > 0 {
+		metrics.AvgShipToDeliverHours = shipToDeliver.Hours() / float64(deliveredOrders)
+	}
+	metrics.Revenue = formatAmount(revenue)
+
+	return metrics, nil
+}
+
+// RebuildOrderMetrics recomputes the order counters and the invoice index of every manufacturer from the
+// product histories, for example to include orders placed before they existed. The milestones of an order
+// are the history timestamps of the versions that placed, shipped and delivered it.
+func (s *SmartContract) RebuildOrderMetrics(ctx contractapi.TransactionContextInterface) error {
+	counterIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(orderCountObjectType, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to get state by partial composite key: %v", err)
+	}
+	defer counterIterator.Close()
+	for counterIterator.HasNext() {
+		queryResponse, err := counterIterator.Next()
+		if err != nil {
+			return fmt.Errorf("error iterating over query results: %v", err)
+		}
+		if err := ctx.GetStub().DelState(queryResponse.Key); err != nil {
+			return fmt.Errorf("failed to delete order counter: %v", err)
+		}
+	}
+
+	products, err := s.GetAllProducts(ctx)
+	if err != nil {
+		return err
+	}
+	type counter struct{ manufacturer, day string }
+	totals := make(map[counter]int64)
+	var order []counter
+	for _, product := range products {
+		history, err := readProductHistory(ctx, product.ID)
+		if err != nil {
+			return err
+		}
+
+		var previous *Product
+		var orderedDate string
+		var entry *invoiceIndexEntry
+		var seller string
+		for _, version := range history {
+			current := version.Product
+			if current == nil {
+				previous = nil
+				continue
+			}
+
+			if newOrder(previous, current) {
+				orderedDate = current.OrderedDate
+				if orderedDate == "" {
+					orderedDate = version.Timestamp
+				}
+				orderedAt, err := parseMilestone(orderedDate)
+				if err != nil {
+					return err
+				}
+				c := counter{current.Manufacturer, orderedAt.Format(orderDayFormat)}
+				if _, ok := totals[c]; !ok {
+					order = append(order, c)
+				}
+				totals[c]++
+			}
+
+			if current.InvoiceNumber != "" && (previous == nil || previous.InvoiceNumber != current.InvoiceNumber) {
+				if err := putRebuiltIndexEntry(ctx, seller, entry); err != nil {
+					return err
+				}
+				entry, seller = nil, ""
+				invoice, err := readInvoice(ctx, current.InvoiceNumber)
+				if err != nil {
+					return err
+				}
+				if invoice != nil {
+					entry = &invoiceIndexEntry{
+						InvoiceNumber: invoice.InvoiceNumber,
+						ProductID:     invoice.ProductID,
+						Subtotal:      invoice.Subtotal,
+						OrderedDate:   orderedDate,
+					}
+					seller = invoice.Seller
+				}
+			}
+
+			if entry != nil && current.InvoiceNumber == entry.InvoiceNumber && (previous == nil || previous.Status != current.Status) {
+				switch current.Status {
+				case "Shipped":
+					entry.ShippedDate = version.Timestamp
+				case "Delivered":
+					entry.DeliveredDate = version.Timestamp
+				}
+			}
+			previous = current
+		}
+		if err := putRebuiltIndexEntry(ctx, seller, entry); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range order {
+		if err := writeIntState(ctx, orderCountObjectType, []string{c.manufacturer, c.day, ctx.GetStub().GetTxID(), ""}, totals[c]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newOrder reports whether a product version places an order: it enters "Pending Order Request" or
+// replaces the pending order of another consumer
+func newOrder(previous *Product, current *Product) bool {
+	if current.Status != "Pending Order Request" {
+		return false
+	}
+	if previous == nil || previous.Status != current.Status {
+		return true
+	}
+	return previous.OrderedDate != current.OrderedDate || previous.ConsumerAccount != current.ConsumerAccount || previous.Consumer != current.Consumer
+}
+
+// putRebuiltIndexEntry writes an invoice index entry recomputed by RebuildOrderMetrics, if there is one
+func putRebuiltIndexEntry(ctx contractapi.TransactionContextInterface, seller string, entry *invoiceIndexEntry) error {
+	if entry == nil {
+		return nil
+	}
+	return putInvoiceIndexEntry(ctx, seller, entry)
+}
+
+// recordOrder dates a new order on the product and counts it for the day it was placed. ProductOrder and
+// AcceptQuote call it for every order they place, including one that replaces a pending order.
+func recordOrder(ctx contractapi.TransactionContextInterface, product *Product) error {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	product.OrderedDate = now.Format(time.RFC3339)
+	return writeIntState(ctx, orderCountObjectType, []string{product.Manufacturer, now.Format(orderDayFormat), ctx.GetStub().GetTxID(), product.ID}, 1)
+}
+
+// recordOrderMilestone dates shipping and delivery on the invoice index entry of the order of a product
+// changing status
+func recordOrderMilestone(ctx contractapi.TransactionContextInterface, product *Product) error {
+	if product.Status != "Shipped" && product.Status != "Delivered" {
+		return nil
+	}
+	if product.InvoiceNumber == "" {
+		return nil
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	entry, err := readInvoiceIndexEntry(ctx, product.Manufacturer, product.InvoiceNumber)
+	if err != nil || entry == nil {
+		return err
+	}
+	if product.Status == "Shipped" {
+		entry.ShippedDate = now.Format(time.RFC3339)
+	} else {
+		entry.DeliveredDate = now.Format(time.RFC3339)
+	}
+	return putInvoiceIndexEntry(ctx, product.Manufacturer, entry)
+}
+
+// indexInvoice adds a newly issued invoice to the index of its seller
+func indexInvoice(ctx contractapi.TransactionContextInterface, invoice *Invoice, product *Product) error {
+	return putInvoiceIndexEntry(ctx, invoice.Seller, &invoiceIndexEntry{
+		InvoiceNumber: invoice.InvoiceNumber,
+		ProductID:     invoice.ProductID,
+		Subtotal:      invoice.Subtotal,
+		OrderedDate:   product.OrderedDate,
+	})
+}
+
+// readOrderCounts sums the order counters of a manufacturer by day or ISO week
+func readOrderCounts(ctx contractapi.TransactionContextInterface, manufacturer string, period string) (map[string]int, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(orderCountObjectType, []string{manufacturer})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state by partial composite key: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	counts := make(map[string]int)
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over query results: %v", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+		day, err := time.Parse(orderDayFormat, keyParts[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse order counter day %s: %v", keyParts[1], err)
+		}
+		delta, err := strconv.ParseInt(string(queryResponse.Value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse order counter %s: %v", queryResponse.Key, err)
+		}
+		counts[periodOf(day, period)] += int(delta)
+	}
+
+	return counts, nil
+}
+
+func readInvoiceIndex(ctx contractapi.TransactionContextInterface, seller string) ([]*invoiceIndexEntry, error) {
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(tradeCollection, invoiceSellerIndex, []string{seller})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the invoice index from %s: %v", tradeCollection, err)
+	}
+	defer resultsIterator.Close()
+
+	var entries []*invoiceIndexEntry
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over query results: %v", err)
+		}
+
+		var entry invoiceIndexEntry
+		if err := json.Unmarshal(queryResponse.Value, &entry); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal invoice index JSON: %v", err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, nil
+}
+
+func readInvoiceIndexEntry(ctx contractapi.TransactionContextInterface, seller string, invoiceNumber string) (*invoiceIndexEntry, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(invoiceSellerIndex, []string{seller, invoiceNumber})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invoice index key: %v", err)
+	}
+	entryJSON, err := ctx.GetStub().GetPrivateData(tradeCollection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read invoice index from %s: %v", tradeCollection, err)
+	}
+	if entryJSON == nil {
+		return nil, nil
+	}
+
+	var entry invoiceIndexEntry
+	if err := json.Unmarshal(entryJSON, &entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal invoice index JSON: %v", err)
+	}
+	return &entry, nil
+}
+
+func putInvoiceIndexEntry(ctx contractapi.TransactionContextInterface, seller string, entry *invoiceIndexEntry) error {
+	key, err := ctx.GetStub().CreateCompositeKey(invoiceSellerIndex, []string{seller, entry.InvoiceNumber})
+	if err != nil {
+		return fmt.Errorf("failed to create invoice index key: %v", err)
+	}
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal invoice index JSON: %v", err)
+	}
+	if err := ctx.GetStub().PutPrivateData(tradeCollection, key, entryJSON); err != nil {
+		return fmt.Errorf("failed to put invoice index to %s: %v", tradeCollection, err)
+	}
+	return nil
+}
+
+// parseMilestone parses a date of an invoice index entry, returning the zero time when it is not set
+func parseMilestone(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse order date %s: %v", value, err)
+	}
+	return at, nil
+}
+
+// periodOf returns the day or ISO week a time falls in
+func periodOf(at time.Time, period string) string {
+	if period == PeriodWeek {
+		year, week := at.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return at.Format("2006-01-02")
+}
@@ -0,0 +1,96 @@
+package chaincode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fulfil ships and delivers the accepted order of p1, an hour apart
+func (f *escrowFixture) fulfil(t *testing.T) {
+	t.Helper()
+	f.submit(t, "ship", func() error { return f.contract.ProductShip(f.manufacturer, "p1", "x") })
+	f.submit(t, "deliver", func() error { return f.contract.ProductDeliver(f.manufacturer, "p1", "maker", "x") })
+}
+
+func (f *escrowFixture) metrics(t *testing.T, period string) *ManufacturerMetrics {
+	t.Helper()
+	metrics, err := f.contract.GetManufacturerMetrics(f.manufacturer, "maker", period)
+	require.NoError(t, err)
+	return metrics
+}
+
+func TestManufacturerMetrics(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.order(t, "order")
+	require.Equal(t, "2024-01-01T03:00:00Z", f.product(t).OrderedDate)
+	require.NoError(t, f.accept("accept"))
+	f.fulfil(t)
+
+	metrics := f.metrics(t, PeriodDay)
+	require.Equal(t, 1, metrics.ProductCount)
+	require.Equal(t, map[string]int{"Delivered": 1}, metrics.StatusCounts)
+	require.Equal(t, []*PeriodCount{{Period: "2024-01-01", Orders: 1}}, metrics.OrdersByPeriod)
+	require.Equal(t, 2.0, metrics.AvgOrderToShipHours)
+	require.Equal(t, 1.0, metrics.AvgShipToDeliverHours)
+	require.Equal(t, 1, metrics.DeliveredOrders)
+	require.Equal(t, "10.00", metrics.Revenue)
+
+	require.Equal(t, []*PeriodCount{{Period: "2024-W01", Orders: 1}}, f.metrics(t, PeriodWeek).OrdersByPeriod)
+
+	_, err := f.contract.GetManufacturerMetrics(f.manufacturer, "maker", "month")
+	RequireContractError(t, err, CodeValidationFailed)
+}
+
+func TestOrderReplacingPendingOrderIsCounted(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.order(t, "order")
+
+	other := newClientContext(f.stub, "Org2MSP", "other-client")
+	f.submit(t, "mint-other", func() error { return NewTokenContract().Mint(f.manufacturer, "other-client", 2500) })
+	f.submit(t, "order-other", func() error { return f.contract.ProductOrder(other, "p1", "carol", "x") })
+	require.Equal(t, "2024-01-01T05:00:00Z", f.product(t).OrderedDate)
+
+	// The status stays "Pending Order Request", but both orders were placed
+	require.Equal(t, []*PeriodCount{{Period: "2024-01-01", Orders: 2}}, f.metrics(t, PeriodDay).OrdersByPeriod)
+
+	require.NoError(t, f.accept("accept"))
+	f.fulfil(t)
+	// The lead time starts at the replacing order
+	require.Equal(t, 2.0, f.metrics(t, PeriodDay).AvgOrderToShipHours)
+}
+
+func TestRebuildOrderMetrics(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.submit(t, "mint-other", func() error { return NewTokenContract().Mint(f.manufacturer, "other-client", 2500) })
+	f.order(t, "order")
+	f.submit(t, "order-other", func() error {
+		return f.contract.ProductOrder(newClientContext(f.stub, "Org2MSP", "other-client"), "p1", "carol", "x")
+	})
+	require.NoError(t, f.accept("accept"))
+	f.fulfil(t)
+	want := f.metrics(t, PeriodDay)
+
+	// Drop the counters and the index, as on a ledger written before they existed
+	for key := range f.stub.state {
+		if strings.HasPrefix(key, "\x00"+orderCountObjectType+"\x00") {
+			delete(f.stub.state, key)
+		}
+	}
+	for key := range f.stub.privateData[tradeCollection] {
+		if strings.HasPrefix(key, "\x00"+invoiceSellerIndex+"\x00") {
+			delete(f.stub.privateData[tradeCollection], key)
+		}
+	}
+	metrics := f.metrics(t, PeriodDay)
+	require.Empty(t, metrics.OrdersByPeriod)
+	require.Equal(t, 0, metrics.DeliveredOrders)
+
+	f.submit(t, "rebuild", func() error { return f.contract.RebuildOrderMetrics(f.manufacturer) })
+	require.Equal(t, want, f.metrics(t, PeriodDay))
+
+	// Rebuilding again replaces the counters instead of adding to them
+	f.submit(t, "rebuild-again", func() error { return f.contract.RebuildOrderMetrics(f.manufacturer) })
+	require.Equal(t, want, f.metrics(t, PeriodDay))
+}
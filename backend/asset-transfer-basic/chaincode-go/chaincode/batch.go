@@ -0,0 +1,125 @@
+package chaincode
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxBatchSize caps the items of a batch so that a transaction stays well below the peer's message and
+// timeout limits. Larger imports are split into several batches.
+const maxBatchSize = 1000
+
+// ProductInput describes a product to create in CreateProductsBatch, with the parameters of CreateProduct
+type ProductInput struct {
+	ID           string `json:"ID"`
+	Name         string `json:"Name"`
+	Description  string `json:"Description,omitempty" metadata:",optional"`
+	Price        string `json:"Price,omitempty" metadata:",optional"`
+	Manufacturer string `json:"Manufacturer"`
+	CreatedDate  string `json:"CreatedDate"`
+}
+
+// CreateProductsBatch creates every product of a catalogue import or none of them. Each product is checked
+// against the rules of CreateProduct; when any is rejected, the error lists the reason for every rejected
+// product by its position in the batch. Private price terms cannot be passed to a batch.
+func (s *SmartContract) CreateProductsBatch(ctx contractapi.TransactionContextInterface, products []ProductInput) error {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("error getting transient: %v", err)
+	}
+	if _, ok := transientMap[transientPriceTerms]; ok {
+		return errValidation("%s cannot be passed to a batch, create products with private prices one by one", transientPriceTerms)
+	}
+
+	seen := make(map[string]bool)
+	return runBatch(ctx, "product", len(products), func(i int) error {
+		product := products[i]
+		if err := validateParameters("CreateProduct", []string{product.ID, product.Name, product.Description, product.Price, product.Manufacturer, product.CreatedDate}); err != nil {
+			return err
+		}
+		if seen[product.ID] {
+			return errValidation("the product %s is listed more than once", product.ID)
+		}
+		seen[product.ID] = true
+
+		return s.CreateProduct(ctx, product.ID, product.Name, product.Description, product.Price, product.Manufacturer, product.CreatedDate)
+	})
+}
+
+// ProductAcceptBatch accepts the orders of every listed product or none of them, as ProductAccept does for
+// one product
+func (s *SmartContract) ProductAcceptBatch(ctx contractapi.TransactionContextInterface, ids []string, manufacturer string, modifieddate string) error {
+	seen := make(map[string]bool)
+	return runBatch(ctx, "product", len(ids), func(i int) error {
+		if seen[ids[i]] {
+			return errValidation("the product %s is listed more than once", ids[i])
+		}
+		seen[ids[i]] = true
+
+		return s.ProductAccept(ctx, ids[i], manufacturer, modifieddate)
+	})
+}
+
+// ProductShipBatch ships every listed product or none of them, each in its own shipment as ProductShip does.
+// Use ShipProducts to ship several products together.
+func (s *SmartContract) ProductShipBatch(ctx contractapi.TransactionContextInterface, ids []string, modifieddate string) error {
+	seen := make(map[string]bool)
+	return runBatch(ctx, "product", len(ids), func(i int) error {
+		if seen[ids[i]] {
+			return errValidation("the product %s is listed more than once", ids[i])
+		}
+		seen[ids[i]] = true
+
+		return s.ProductShip(ctx, ids[i], modifieddate)
+	})
+}
+
+// runBatch applies item to every item of a batch and collects the errors a client can act on, so that one
+// attempt reports every rejected item. The writes of a rejected item are rolled back before the next item
+// runs, so that it is checked as if the rejected item were not in the batch. Any other error aborts the batch
+// at once.
+func runBatch(ctx contractapi.TransactionContextInterface, kind string, count int, item func(i int) error) error {
+	if count == 0 {
+		return errValidation("a batch must contain at least one %s", kind)
+	}
+	if count > maxBatchSize {
+		return errValidation("a batch must not contain more than %d items, got %d", maxBatchSize, count)
+	}
+
+	stub, _ := ctx.GetStub().(*pendingWritesStub)
+	var rejected []int
+	var errs []*ContractError
+	for i := 0; i < count; i++ {
+		savepoint := stub.savepoint()
+		err := item(i)
+		if err == nil {
+			continue
+		}
+		contractErr, ok := err.(*ContractError)
+		if !ok {
+			return fmt.Errorf("failed to process item %d of the batch: %v", i, err)
+		}
+		stub.rollback(savepoint)
+		rejected = append(rejected, i)
+		errs = append(errs, contractErr)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	// The batch fails with the code its items failed with, or VALIDATION_FAILED when they differ
+	code := errs[0].Code
+	for _, err := range errs {
+		if err.Code != code {
+			code = CodeValidationFailed
+		}
+	}
+	batchErr := newContractError(code, "%d of %d items of the batch were rejected, nothing was written", len(errs), count)
+	for i, err := range errs {
+		item := strconv.Itoa(rejected[i])
+		batchErr.withDetail(item, err.Message).withDetail(item+".code", err.Code)
+	}
+	return batchErr
+}
@@ -0,0 +1,87 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// tryHooked runs a transaction with the context the hooks create, which batches need to read their own
+// writes, and commits it when it succeeds
+func (f *escrowFixture) tryHooked(t *testing.T, txID string, mspID string, account string, transaction func(ctx *TransactionContext) error) error {
+	t.Helper()
+	f.stub.begin(txID)
+	ctx := newHookedContext(f.stub, mspID, account)
+	require.NoError(t, ctx.load())
+	if err := transaction(ctx); err != nil {
+		return err
+	}
+	f.stub.commit()
+	return nil
+}
+
+func TestRunBatchRollsBackRejectedItems(t *testing.T) {
+	stub := newLedgerStub()
+	stub.begin("batch")
+	ctx := newHookedContext(stub, "Org1MSP", manufacturerAccount)
+
+	err := runBatch(ctx, "item", 3, func(i int) error {
+		switch i {
+		case 0:
+			require.NoError(t, ctx.GetStub().PutState("k", []byte("rejected")))
+			return errValidation("item 0 is rejected after writing")
+		case 1:
+			// The rejected item's write is gone
+			value, err := ctx.GetStub().GetState("k")
+			require.NoError(t, err)
+			require.Nil(t, value)
+			return ctx.GetStub().PutState("k", []byte("accepted"))
+		default:
+			return errNotFound("thing", "t1")
+		}
+	})
+
+	RequireContractError(t, err, CodeValidationFailed)
+	details := err.(*ContractError).Details
+	require.Equal(t, "item 0 is rejected after writing", details["0"])
+	require.Equal(t, CodeNotFound, details["2.code"])
+	require.NotContains(t, details, "1")
+
+	value, err := ctx.GetStub().GetState("k")
+	require.NoError(t, err)
+	require.Equal(t, []byte("accepted"), value)
+}
+
+func TestProductAcceptBatchIsAllOrNothing(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.submit(t, "create-p2", func() error {
+		return f.contract.CreateProduct(f.manufacturer, "p2", "pear", "good", "20.00", "maker", "2024-01-01T00:00:00Z")
+	})
+	f.order(t, "order-p1")
+	f.submit(t, "order-p2", func() error { return f.contract.ProductOrder(f.consumer, "p2", "bob", "x") })
+
+	// 25.00 pays for p1 but not for both, so neither order is accepted
+	err := f.tryHooked(t, "accept-both", "Org1MSP", manufacturerAccount, func(ctx *TransactionContext) error {
+		return f.contract.ProductAcceptBatch(ctx, []string{"p1", "p2"}, "maker", "x")
+	})
+	RequireContractError(t, err, CodeInvalidState)
+	require.Contains(t, err.(*ContractError).Details, "1")
+	require.Equal(t, "Pending Order Request", f.product(t).Status)
+	require.Equal(t, int64(2500), f.balance(t, consumerAccount))
+
+	// Each item sees the balance the previous one left
+	f.submit(t, "mint", func() error { return NewTokenContract().Mint(f.manufacturer, consumerAccount, 500) })
+	require.NoError(t, f.tryHooked(t, "accept-both-again", "Org1MSP", manufacturerAccount, func(ctx *TransactionContext) error {
+		return f.contract.ProductAcceptBatch(ctx, []string{"p1", "p2"}, "maker", "x")
+	}))
+	require.Equal(t, "Accepted", f.product(t).Status)
+	require.Equal(t, int64(0), f.balance(t, consumerAccount))
+
+	err = f.tryHooked(t, "accept-duplicate", "Org1MSP", manufacturerAccount, func(ctx *TransactionContext) error {
+		return f.contract.ProductAcceptBatch(ctx, []string{"p1", "p1"}, "maker", "x")
+	})
+	// Items rejected with different codes fail the batch as a validation failure
+	RequireContractError(t, err, CodeValidationFailed)
+	require.Equal(t, CodeInvalidState, err.(*ContractError).Details["0.code"])
+	require.Equal(t, CodeValidationFailed, err.(*ContractError).Details["1.code"])
+}
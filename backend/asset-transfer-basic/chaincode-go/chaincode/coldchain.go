@@ -0,0 +1,292 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	sensorBatchObjectType = "sensorBatch"
+	conditionRangeConfig  = "conditionRange:"
+
+	FlagTemperatureExcursion = "Temperature Excursion"
+	FlagHumidityExcursion    = "Humidity Excursion"
+)
+
+// ConditionRange is the storage range allowed for a product category. A humidity range of 0 to 0 means
+// humidity is not monitored, and loggers without a humidity sensor report 0.
+type ConditionRange struct {
+	Category       string  `json:"Category"`
+	MinTemperature float64 `json:"MinTemperature"` // degrees Celsius
+	MaxTemperature float64 `json:"MaxTemperature"`
+	MinHumidity    float64 `json:"MinHumidity"` // percent relative humidity
+	MaxHumidity    float64 `json:"MaxHumidity"`
+}
+
+// SensorReading is one measurement taken by a data logger travelling with a shipment
+type SensorReading struct {
+	Timestamp   string  `json:"Timestamp"` // RFC 3339
+	Temperature float64 `json:"Temperature"`
+	Humidity    float64 `json:"Humidity"`
+}
+
+// SensorBatch summarises a batch of logger readings. The readings themselves stay off the world state;
+// ReadingsHash lets anyone holding them prove they are the batch that was submitted.
+type SensorBatch struct {
+	ShipmentID      string   `json:"ShipmentID"`
+	LoggerID        string   `json:"LoggerID"`
+	TxID            string   `json:"TxID"`
+	SubmittedByOrg  string   `json:"SubmittedByOrg"`
+	ReadingsHash    string   `json:"ReadingsHash"` // hex SHA-256 of the readings as JSON
+	ReadingCount    int      `json:"ReadingCount"`
+	FirstReadingAt  string   `json:"FirstReadingAt"`
+	LastReadingAt   string   `json:"LastReadingAt"`
+	MinTemperature  float64  `json:"MinTemperature"`
+	MaxTemperature  float64  `json:"MaxTemperature"`
+	AvgTemperature  float64  `json:"AvgTemperature"`
+	MinHumidity     float64  `json:"MinHumidity"`
+	MaxHumidity     float64  `json:"MaxHumidity"`
+	AvgHumidity     float64  `json:"AvgHumidity"`
+	FlaggedProducts []string `json:"FlaggedProducts"` // products of the shipment whose category range the batch broke
+}
+
+// SetConditionRange sets the temperature and humidity range allowed for a product category
+func (s *SmartContract) SetConditionRange(ctx contractapi.TransactionContextInterface, category string, minTemperature float64, maxTemperature float64, minHumidity float64, maxHumidity float64) error {
+	if category == "" {
+		return errValidation("the category must not be empty")
+	}
+	if minTemperature > maxTemperature || minHumidity > maxHumidity {
+		return errValidation("the minimum of a range must not be above its maximum")
+	}
+
+	return writeConfig(ctx, conditionRangeConfig+category, &ConditionRange{
+		Category:       category,
+		MinTemperature: minTemperature,
+		MaxTemperature: maxTemperature,
+		MinHumidity:    minHumidity,
+		MaxHumidity:    maxHumidity,
+	})
+}
+
+// GetConditionRange returns the range allowed for a product category
+func (s *SmartContract) GetConditionRange(ctx contractapi.TransactionContextInterface, category string) (*ConditionRange, error) {
+	conditionRange, err := readConditionRange(ctx, category)
+	if err != nil {
+		return nil, err
+	}
+	if conditionRange == nil {
+		return nil, newContractError(CodeNotFound, "no condition range is set for category %s", category)
+	}
+	return conditionRange, nil
+}
+
+// SetProductCategory sets the category that decides which condition range applies to a product. Only the
+// manufacturer of the product can set it.
+func (s *SmartContract) SetProductCategory(ctx contractapi.TransactionContextInterface, id string, manufacturer string, category string) error {
+	product, err := s.ReadProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+	if clientOrg != manufacturerOrg(product) || product.Manufacturer != manufacturer {
+		return errForbidden("You can only categorise your own products")
+	}
+	product.Category = category
+
+	return putProduct(ctx, product)
+}
+
+// SubmitSensorReadings records a batch of data logger readings for a shipment. Every product in the
+// shipment whose category range the readings leave is flagged with a "Temperature Excursion" or
+// "Humidity Excursion".
+func (s *SmartContract) SubmitSensorReadings(ctx contractapi.TransactionContextInterface, shipmentID string, loggerID string, readings []SensorReading) (*SensorBatch, error) {
+	shipment, err := s.ReadShipment(ctx, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if clientOrg != shipment.CarrierOrg && clientOrg != shipment.ShipperOrg {
+		return nil, errForbidden("Access denied: Only the carrier or shipper org can submit sensor readings")
+	}
+	if loggerID == "" {
+		return nil, errValidation("the logger ID must not be empty")
+	}
+
+	batch, err := summariseReadings(readings)
+	if err != nil {
+		return nil, err
+	}
+	batch.ShipmentID = shipmentID
+	batch.LoggerID = loggerID
+	batch.TxID = ctx.GetStub().GetTxID()
+	batch.SubmittedByOrg = clientOrg
+	batch.FlaggedProducts = []string{}
+
+	for _, id := range shipment.ProductIDs {
+		product, err := s.ReadProduct(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if product.Category == "" {
+			continue
+		}
+		conditionRange, err := readConditionRange(ctx, product.Category)
+		if err != nil {
+			return nil, err
+		}
+		if conditionRange == nil {
+			continue
+		}
+
+		var flags []string
+		if batch.MinTemperature < conditionRange.MinTemperature || batch.MaxTemperature > conditionRange.MaxTemperature {
+			flags = append(flags, FlagTemperatureExcursion)
+		}
+		monitorsHumidity := conditionRange.MinHumidity != 0 || conditionRange.MaxHumidity != 0
+		if monitorsHumidity && (batch.MinHumidity < conditionRange.MinHumidity || batch.MaxHumidity > conditionRange.MaxHumidity) {
+			flags = append(flags, FlagHumidityExcursion)
+		}
+		if len(flags) == 0 {
+			continue
+		}
+		batch.FlaggedProducts = append(batch.FlaggedProducts, product.ID)
+
+		// Only write products that gain a new flag
+		added := false
+		for _, flag := range flags {
+			added = addFlag(product, flag) || added
+		}
+		if added {
+			if err := putProduct(ctx, product); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(sensorBatchObjectType, []string{shipmentID, batch.TxID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sensor batch key: %v", err)
+	}
+	batchJSON, err := json.Marshal(batch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal sensor batch JSON: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, batchJSON); err != nil {
+		return nil, fmt.Errorf("failed to put to world state. %v", err)
+	}
+
+	return batch, nil
+}
+
+// GetShipmentSensorBatches returns the summaries of every sensor batch submitted for a shipment
+func (s *SmartContract) GetShipmentSensorBatches(ctx contractapi.TransactionContextInterface, shipmentID string) ([]*SensorBatch, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(sensorBatchObjectType, []string{shipmentID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state by partial composite key: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var batches []*SensorBatch
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over query results: %v", err)
+		}
+
+		var batch SensorBatch
+		if err := json.Unmarshal(queryResponse.Value, &batch); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal sensor batch JSON: %v", err)
+		}
+		batches = append(batches, &batch)
+	}
+
+	return batches, nil
+}
+
+// summariseReadings validates a batch of readings and computes its hash and statistics
+func summariseReadings(readings []SensorReading) (*SensorBatch, error) {
+	if len(readings) == 0 {
+		return nil, errValidation("a sensor batch must contain at least one reading")
+	}
+
+	readingsJSON, err := json.Marshal(readings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal sensor readings JSON: %v", err)
+	}
+	hash := sha256.Sum256(readingsJSON)
+	batch := &SensorBatch{
+		ReadingsHash: hex.EncodeToString(hash[:]),
+		ReadingCount: len(readings),
+	}
+
+	var first, last time.Time
+	var temperatureSum, humiditySum float64
+	for i, reading := range readings {
+		at, err := time.Parse(time.RFC3339, reading.Timestamp)
+		if err != nil {
+			return nil, errValidation("reading %d must have an RFC 3339 timestamp: %v", i, err)
+		}
+		if i == 0 || at.Before(first) {
+			first = at
+		}
+		if i == 0 || at.After(last) {
+			last = at
+		}
+
+		if i == 0 || reading.Temperature < batch.MinTemperature {
+			batch.MinTemperature = reading.Temperature
+		}
+		if i == 0 || reading.Temperature > batch.MaxTemperature {
+			batch.MaxTemperature = reading.Temperature
+		}
+		temperatureSum += reading.Temperature
+
+		if i == 0 || reading.Humidity < batch.MinHumidity {
+			batch.MinHumidity = reading.Humidity
+		}
+		if i == 0 || reading.Humidity > batch.MaxHumidity {
+			batch.MaxHumidity = reading.Humidity
+		}
+		humiditySum += reading.Humidity
+	}
+
+	batch.FirstReadingAt = first.UTC().Format(time.RFC3339)
+	batch.LastReadingAt = last.UTC().Format(time.RFC3339)
+	batch.AvgTemperature = temperatureSum / float64(len(readings))
+	batch.AvgHumidity = humiditySum / float64(len(readings))
+
+	return batch, nil
+}
+
+// addFlag adds a flag to a product unless it already carries it, reporting whether it was added
+func addFlag(product *Product, flag string) bool {
+	for _, existing := range product.Flags {
+		if existing == flag {
+			return false
+		}
+	}
+	product.Flags = append(product.Flags, flag)
+	return true
+}
+
+func readConditionRange(ctx contractapi.TransactionContextInterface, category string) (*ConditionRange, error) {
+	var conditionRange ConditionRange
+	found, err := readConfig(ctx, conditionRangeConfig+category, &conditionRange)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &conditionRange, nil
+}
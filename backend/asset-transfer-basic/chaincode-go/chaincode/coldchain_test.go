@@ -0,0 +1,66 @@
+package chaincode
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newColdChainFixture ships p1, a frozen product, and returns the fixture and the shipment ID
+func newColdChainFixture(t *testing.T) (*escrowFixture, string) {
+	f := newEscrowFixture(t)
+	f.submit(t, "range", func() error { return f.contract.SetConditionRange(f.manufacturer, "frozen", -25, -15, 0, 0) })
+	f.submit(t, "category", func() error { return f.contract.SetProductCategory(f.manufacturer, "p1", "maker", "frozen") })
+	return f, f.ship(t, false)
+}
+
+func (f *escrowFixture) submitReadings(t *testing.T, txID string, shipmentID string, temperatures ...float64) *SensorBatch {
+	t.Helper()
+	var readings []SensorReading
+	for i, temperature := range temperatures {
+		readings = append(readings, SensorReading{Timestamp: fmt.Sprintf("2024-01-02T%02d:00:00Z", i), Temperature: temperature, Humidity: 80})
+	}
+
+	var batch *SensorBatch
+	f.submit(t, txID, func() error {
+		var err error
+		batch, err = f.contract.SubmitSensorReadings(f.manufacturer, shipmentID, "logger-1", readings)
+		return err
+	})
+	return batch
+}
+
+func TestSensorReadingsFlagExcursions(t *testing.T) {
+	f, shipmentID := newColdChainFixture(t)
+
+	// Humidity is not monitored for the category, so the 80% readings do not count
+	batch := f.submitReadings(t, "in-range", shipmentID, -20, -18)
+	require.Empty(t, batch.FlaggedProducts)
+	require.Empty(t, f.product(t).Flags)
+
+	batch = f.submitReadings(t, "excursion", shipmentID, -20, -9, -19)
+	require.Equal(t, []string{"p1"}, batch.FlaggedProducts)
+	require.Equal(t, -20.0, batch.MinTemperature)
+	require.Equal(t, -9.0, batch.MaxTemperature)
+	require.Equal(t, []string{FlagTemperatureExcursion}, f.product(t).Flags)
+
+	// A second excursion does not flag the product twice
+	f.submitReadings(t, "excursion-again", shipmentID, -5)
+	require.Equal(t, []string{FlagTemperatureExcursion}, f.product(t).Flags)
+
+	batches, err := f.contract.GetShipmentSensorBatches(f.manufacturer, shipmentID)
+	require.NoError(t, err)
+	require.Len(t, batches, 3)
+}
+
+func TestSensorReadingsOnlyFromCarrierOrShipper(t *testing.T) {
+	f, shipmentID := newColdChainFixture(t)
+
+	err := f.try("readings", func() error {
+		_, err := f.contract.SubmitSensorReadings(f.consumer, shipmentID, "logger-1", []SensorReading{{Timestamp: "2024-01-02T00:00:00Z", Temperature: 5}})
+		return err
+	})
+	RequireContractError(t, err, CodeForbidden)
+	require.Empty(t, f.product(t).Flags)
+}
@@ -0,0 +1,47 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// configObjectType is the composite key namespace holding contract-wide settings. Composite keys are
+// skipped by the GetStateByRange("", "") scans that list products.
+const configObjectType = "config"
+
+// readConfig loads the named setting into value and reports whether it has been set
+func readConfig(ctx contractapi.TransactionContextInterface, name string, value interface{}) (bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(configObjectType, []string{name})
+	if err != nil {
+		return false, fmt.Errorf("failed to create config key: %v", err)
+	}
+	configJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if configJSON == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(configJSON, value); err != nil {
+		return false, fmt.Errorf("failed to unmarshal config %s: %v", name, err)
+	}
+	return true, nil
+}
+
+// writeConfig stores the named setting
+func writeConfig(ctx contractapi.TransactionContextInterface, name string, value interface{}) error {
+	key, err := ctx.GetStub().CreateCompositeKey(configObjectType, []string{name})
+	if err != nil {
+		return fmt.Errorf("failed to create config key: %v", err)
+	}
+	configJSON, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config %s: %v", name, err)
+	}
+	if err := ctx.GetStub().PutState(key, configJSON); err != nil {
+		return fmt.Errorf("failed to put to world state. %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package chaincode
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TransactionContext is the context every contract of this chaincode is invoked with. BeforeTransaction
+// loads the caller's identity and the transaction time into it once, so helpers such as getClientOrganization
+// do not parse the creator certificate again on every call. Its stub lets a transaction read its own
+// writes, which batches rely on when several items touch the same record, such as a consumer's balance.
+type TransactionContext struct {
+	contractapi.TransactionContext
+	function    string // the name the transaction was invoked with
+	transaction string // the name its permissions and parameter rules are declared under
+	clientID    string
+	clientOrg   string
+	txTime      time.Time
+	loaded      bool
+	sequences   map[string]int // see transactionSequence
+}
+
+// newContract returns the contractapi settings shared by the contracts of this chaincode: the custom
+// transaction context and the hooks that authorize and log every invocation
+func newContract(name string) contractapi.Contract {
+	return contractapi.Contract{
+		Name:                      name,
+		TransactionContextHandler: new(TransactionContext),
+		BeforeTransaction:         beforeTransaction,
+		AfterTransaction:          afterTransaction,
+	}
+}
+
+// beforeTransaction preloads the context, logs the invocation, checks the invoking org against
+// transactionPermissions and the arguments against parameterRules
+func beforeTransaction(ctx *TransactionContext) error {
+	if err := ctx.load(); err != nil {
+		return err
+	}
+
+	log.Printf("tx %s: %s invoked by %s", ctx.GetStub().GetTxID(), ctx.function, ctx.clientOrg)
+
+	if err := ctx.authorize(); err != nil {
+		log.Printf("tx %s: %s denied to %s", ctx.GetStub().GetTxID(), ctx.function, ctx.clientOrg)
+		return err
+	}
+
+	_, params := ctx.GetStub().GetFunctionAndParameters()
+	if err := validateParameters(ctx.transaction, params); err != nil {
+		log.Printf("tx %s: %s rejected: %v", ctx.GetStub().GetTxID(), ctx.function, errorMessage(err))
+		return err
+	}
+
+	return nil
+}
+
+// authorize checks the invoking org against transactionPermissions
+func (ctx *TransactionContext) authorize() error {
+	orgs, ok := transactionPermissions[ctx.transaction]
+	if !ok {
+		return nil
+	}
+	for _, org := range orgs {
+		if ctx.clientOrg == org {
+			return nil
+		}
+	}
+	return errForbidden("Access denied: Only peers in %s are allowed to execute %s", strings.Join(orgs, " or "), ctx.function)
+}
+
+// afterTransaction logs that an invocation completed. Failed invocations never reach it.
+func afterTransaction(ctx *TransactionContext) error {
+	log.Printf("tx %s: %s completed", ctx.GetStub().GetTxID(), ctx.function)
+	return nil
+}
+
+// load reads the invoked function, caller identity and transaction time from the stub
+func (ctx *TransactionContext) load() error {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	// Functions of the default contract can be invoked with or without its name
+	ctx.function = strings.TrimPrefix(function, defaultContractName+":")
+	ctx.transaction = transactionName(ctx.function)
+
+	clientOrg, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client identity: %v", err)
+	}
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	ctx.clientOrg = clientOrg
+	ctx.clientID = clientID
+	ctx.txTime = txTimestamp.AsTime().UTC()
+	ctx.sequences = make(map[string]int)
+	ctx.loaded = true
+
+	return nil
+}
+
+// loadedContext returns the preloaded context of a transaction, or nil when the context was not created by
+// contractapi with the hooks above, as in unit tests that pass their own context
+func loadedContext(ctx contractapi.TransactionContextInterface) *TransactionContext {
+	if txCtx, ok := ctx.(*TransactionContext); ok && txCtx.loaded {
+		return txCtx
+	}
+	return nil
+}
+
+// transactionSequence counts the calls made for kind within the transaction, starting at 1, so that
+// documents and IDs derived from the transaction ID stay unique when a batch derives several. Without the
+// hooks, as in unit tests, every call returns 1.
+func transactionSequence(ctx contractapi.TransactionContextInterface, kind string) int {
+	txCtx := loadedContext(ctx)
+	if txCtx == nil {
+		return 1
+	}
+	txCtx.sequences[kind]++
+	return txCtx.sequences[kind]
+}
+
+// SetStub wraps the stub contractapi sets so that reads see the writes of the same transaction
+func (ctx *TransactionContext) SetStub(stub shim.ChaincodeStubInterface) {
+	ctx.TransactionContext.SetStub(&pendingWritesStub{ChaincodeStubInterface: stub, writes: make(map[string][]byte)})
+}
+
+// pendingWritesStub answers GetState from the writes of the current transaction before falling back to the
+// world state, which Fabric only updates once the transaction commits. Range and composite key queries are
+// not affected and still see the world state only.
+type pendingWritesStub struct {
+	shim.ChaincodeStubInterface
+	writes map[string][]byte // nil marks a deleted key
+	undo   []pendingWrite
+}
+
+// pendingWrite remembers what a key held in writes before a write, so that the write can be rolled back
+type pendingWrite struct {
+	key      string
+	previous []byte
+	pending  bool
+}
+
+func (stub *pendingWritesStub) GetState(key string) ([]byte, error) {
+	if value, ok := stub.writes[key]; ok {
+		return value, nil
+	}
+	return stub.ChaincodeStubInterface.GetState(key)
+}
+
+func (stub *pendingWritesStub) PutState(key string, value []byte) error {
+	if err := stub.ChaincodeStubInterface.PutState(key, value); err != nil {
+		return err
+	}
+	stub.record(key, value)
+	return nil
+}
+
+func (stub *pendingWritesStub) DelState(key string) error {
+	if err := stub.ChaincodeStubInterface.DelState(key); err != nil {
+		return err
+	}
+	stub.record(key, nil)
+	return nil
+}
+
+func (stub *pendingWritesStub) record(key string, value []byte) {
+	previous, pending := stub.writes[key]
+	stub.undo = append(stub.undo, pendingWrite{key: key, previous: previous, pending: pending})
+	stub.writes[key] = value
+}
+
+// savepoint marks the pending writes so that later ones can be rolled back. It is safe to call on a nil
+// stub, which is what batches get when they run without the hooks, as in unit tests.
+func (stub *pendingWritesStub) savepoint() int {
+	if stub == nil {
+		return 0
+	}
+	return len(stub.undo)
+}
+
+// rollback forgets the writes made after savepoint, so that later reads no longer see them. The writes stay
+// in the transaction's write set, so rollback is only meant for transactions that go on to fail.
+func (stub *pendingWritesStub) rollback(savepoint int) {
+	if stub == nil {
+		return
+	}
+	for len(stub.undo) > savepoint {
+		last := stub.undo[len(stub.undo)-1]
+		stub.undo = stub.undo[:len(stub.undo)-1]
+		if last.pending {
+			stub.writes[last.key] = last.previous
+		} else {
+			delete(stub.writes, last.key)
+		}
+	}
+}
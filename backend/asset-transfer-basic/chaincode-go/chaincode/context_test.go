@@ -0,0 +1,69 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// hookContext returns the context contractapi would pass to beforeTransaction for a client of mspID
+// invoking function with params
+func hookContext(mspID string, function string, params ...string) *TransactionContext {
+	stub := newLedgerStub()
+	stub.begin("tx")
+	stub.GetFunctionAndParametersReturns(function, params)
+	return newHookedContext(stub, mspID, "client")
+}
+
+func TestBeforeTransactionEnforcesPermissions(t *testing.T) {
+	createProduct := []string{"p1", "apple", "", "1.00", "maker", "May 4th 2024, 3:04:05 pm"}
+	placeOrder := []string{"p1", "bob", "2024-05-04T15:04:05Z"}
+	tests := []struct {
+		name     string
+		mspID    string
+		function string
+		params   []string
+		wantCode string // empty when the transaction may go ahead
+	}{
+		{name: "manufacturer creates", mspID: "Org1MSP", function: "CreateProduct", params: createProduct},
+		{name: "consumer creates", mspID: "Org2MSP", function: "CreateProduct", params: createProduct, wantCode: CodeForbidden},
+		{name: "consumer creates with contract name", mspID: "Org2MSP", function: "SmartContract:CreateProduct", params: createProduct, wantCode: CodeForbidden},
+		{name: "consumer creates through alias", mspID: "Org2MSP", function: "product:Create", params: createProduct, wantCode: CodeForbidden},
+		{name: "unknown org orders", mspID: "Org3MSP", function: "order:Place", params: placeOrder, wantCode: CodeForbidden},
+		{name: "consumer orders", mspID: "Org2MSP", function: "order:Place", params: placeOrder},
+		{name: "consumer mints", mspID: "Org2MSP", function: "token:Mint", params: []string{"account", "100"}, wantCode: CodeForbidden},
+		{name: "open transaction", mspID: "Org3MSP", function: "ReadProduct", params: []string{"p1"}},
+		// Permissions are checked before the parameters
+		{name: "consumer creates with invalid parameters", mspID: "Org2MSP", function: "CreateProduct", params: []string{"", "", "", "", "", ""}, wantCode: CodeForbidden},
+		{name: "manufacturer creates with invalid parameters", mspID: "Org1MSP", function: "CreateProduct", params: []string{"", "", "", "", "", ""}, wantCode: CodeValidationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := beforeTransaction(hookContext(tt.mspID, tt.function, tt.params...))
+			if tt.wantCode == "" {
+				require.NoError(t, err)
+				return
+			}
+			RequireContractError(t, err, tt.wantCode)
+		})
+	}
+}
+
+func TestEveryPermissionNamesATransaction(t *testing.T) {
+	chaincodeMetadata := readContractMetadata(t)
+
+	transactions := make(map[string]bool)
+	for contractName, contract := range chaincodeMetadata.Contracts {
+		for _, transaction := range contract.Transactions {
+			function := transaction.Name
+			if contractName != defaultContractName {
+				function = contractName + ":" + function
+			}
+			transactions[function] = true
+		}
+	}
+	for name := range transactionPermissions {
+		require.True(t, transactions[name], "transactionPermissions lists %s, which is not a transaction", name)
+	}
+}
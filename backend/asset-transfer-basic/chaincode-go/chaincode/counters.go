@@ -0,0 +1,209 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// statusCountObjectType holds the product counts per manufacturer and status as delta keys
+// [manufacturer, status, txID, productID] with the change of the count as value. Every transaction writes
+// its own keys, so concurrent orders never conflict on a shared counter; readers sum the deltas and
+// CompactStatusCounters folds them back into one key per counter.
+const statusCountObjectType = "statusCount"
+
+// GetStatusCounts returns how many products of a manufacturer are in each status, or of all manufacturers
+// when manufacturer is empty, without scanning the products
+func (s *SmartContract) GetStatusCounts(ctx contractapi.TransactionContextInterface, manufacturer string) (map[string]int, error) {
+	return readStatusCounts(ctx, manufacturer)
+}
+
+// CompactStatusCounters replaces the delta keys of a manufacturer's status and order counters, or of every
+// manufacturer when manufacturer is empty, with a single key per counter. A compaction that races with a
+// status change fails validation and can simply be retried.
+//
+// Status changes never compact on their own, because reading the deltas would make every order conflict
+// with every other order of the manufacturer. An operator must schedule this transaction, for example
+// nightly, or the deltas and the cost of GetStatusCounts and GetManufacturerMetrics grow with every status
+// change.
+func (s *SmartContract) CompactStatusCounters(ctx contractapi.TransactionContextInterface, manufacturer string) error {
+	attributes := []string{}
+	if manufacturer != "" {
+		attributes = []string{manufacturer}
+	}
+	if err := compactCounters(ctx, statusCountObjectType, attributes); err != nil {
+		return err
+	}
+	return compactCounters(ctx, orderCountObjectType, attributes)
+}
+
+// RebuildStatusCounters recounts every product from scratch, for example to start counting products
+// written before the counters existed
+func (s *SmartContract) RebuildStatusCounters(ctx contractapi.TransactionContextInterface) error {
+	counterIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(statusCountObjectType, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to get state by partial composite key: %v", err)
+	}
+	defer counterIterator.Close()
+	for counterIterator.HasNext() {
+		queryResponse, err := counterIterator.Next()
+		if err != nil {
+			return fmt.Errorf("error iterating over query results: %v", err)
+		}
+		if err := ctx.GetStub().DelState(queryResponse.Key); err != nil {
+			return fmt.Errorf("failed to delete status counter: %v", err)
+		}
+	}
+
+	products, err := s.GetAllProducts(ctx)
+	if err != nil {
+		return err
+	}
+	type counter struct{ manufacturer, status string }
+	totals := make(map[counter]int64)
+	var order []counter
+	for _, product := range products {
+		c := counter{product.Manufacturer, product.Status}
+		if _, ok := totals[c]; !ok {
+			order = append(order, c)
+		}
+		totals[c]++
+	}
+	for _, c := range order {
+		if err := writeIntState(ctx, statusCountObjectType, []string{c.manufacturer, c.status, ctx.GetStub().GetTxID(), ""}, totals[c]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateStatusCounters writes the counter deltas for a product about to be written, comparing it with the
+// version it replaces, which may have been written earlier in this transaction
+func updateStatusCounters(ctx contractapi.TransactionContextInterface, product *Product) error {
+	previousJSON, err := ctx.GetStub().GetState(product.ID)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if previousJSON != nil {
+		var previous Product
+		if err := json.Unmarshal(previousJSON, &previous); err != nil {
+			return fmt.Errorf("failed to unmarshal product JSON: %v", err)
+		}
+		if previous.Manufacturer == product.Manufacturer && previous.Status == product.Status {
+			return nil
+		}
+		if err := writeStatusDelta(ctx, previous.Manufacturer, previous.Status, product.ID, -1); err != nil {
+			return err
+		}
+		if previous.Status != product.Status {
+			if err := recordOrderMilestone(ctx, product); err != nil {
+				return err
+			}
+		}
+	}
+
+	return writeStatusDelta(ctx, product.Manufacturer, product.Status, product.ID, 1)
+}
+
+// writeStatusDelta adds delta to the key of this transaction and product, which already holds a delta when
+// the product changed status more than once in the transaction
+func writeStatusDelta(ctx contractapi.TransactionContextInterface, manufacturer string, status string, productID string, delta int64) error {
+	attributes := []string{manufacturer, status, ctx.GetStub().GetTxID(), productID}
+	pending, err := readIntState(ctx, statusCountObjectType, attributes)
+	if err != nil {
+		return err
+	}
+	return writeIntState(ctx, statusCountObjectType, attributes, pending+delta)
+}
+
+// readStatusCounts sums the counter deltas of a manufacturer, or of all manufacturers, by status
+func readStatusCounts(ctx contractapi.TransactionContextInterface, manufacturer string) (map[string]int, error) {
+	attributes := []string{}
+	if manufacturer != "" {
+		attributes = []string{manufacturer}
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(statusCountObjectType, attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state by partial composite key: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	counts := make(map[string]int)
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over query results: %v", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+		delta, err := strconv.ParseInt(string(queryResponse.Value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse status counter %s: %v", queryResponse.Key, err)
+		}
+		counts[keyParts[1]] += int(delta)
+	}
+
+	// Drop statuses that no product is in any more
+	for status, count := range counts {
+		if count == 0 {
+			delete(counts, status)
+		}
+	}
+
+	return counts, nil
+}
+
+// compactCounters folds the delta keys [manufacturer, counter, txID, productID] of an object type into one
+// key per manufacturer and counter
+func compactCounters(ctx contractapi.TransactionContextInterface, objectType string, attributes []string) error {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, attributes)
+	if err != nil {
+		return fmt.Errorf("failed to get state by partial composite key: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	type counter struct{ manufacturer, name string }
+	totals := make(map[counter]int64)
+	var order []counter
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return fmt.Errorf("error iterating over query results: %v", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return fmt.Errorf("failed to split composite key: %v", err)
+		}
+		delta, err := strconv.ParseInt(string(queryResponse.Value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s counter %s: %v", objectType, queryResponse.Key, err)
+		}
+
+		c := counter{keyParts[0], keyParts[1]}
+		if _, ok := totals[c]; !ok {
+			order = append(order, c)
+		}
+		totals[c] += delta
+		if err := ctx.GetStub().DelState(queryResponse.Key); err != nil {
+			return fmt.Errorf("failed to delete %s counter: %v", objectType, err)
+		}
+	}
+
+	for _, c := range order {
+		if totals[c] == 0 {
+			continue
+		}
+		if err := writeIntState(ctx, objectType, []string{c.manufacturer, c.name, ctx.GetStub().GetTxID(), ""}, totals[c]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
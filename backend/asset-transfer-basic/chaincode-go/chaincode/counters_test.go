@@ -0,0 +1,61 @@
+package chaincode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (f *escrowFixture) statusCounts(t *testing.T, manufacturer string) map[string]int {
+	t.Helper()
+	counts, err := f.contract.GetStatusCounts(f.manufacturer, manufacturer)
+	require.NoError(t, err)
+	return counts
+}
+
+// counterKeys returns how many keys the counters of an object type take in the world state
+func (f *escrowFixture) counterKeys(objectType string) int {
+	count := 0
+	for key := range f.stub.state {
+		if strings.HasPrefix(key, "\x00"+objectType+"\x00") {
+			count++
+		}
+	}
+	return count
+}
+
+func TestStatusCountersSumDeltasAndCompact(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.submit(t, "create-other", func() error {
+		return f.contract.CreateProduct(f.manufacturer, "p2", "pear", "good", "5.00", "other", "2024-01-01T00:00:00Z")
+	})
+	f.order(t, "order")
+	require.NoError(t, f.accept("accept"))
+	f.submit(t, "cancel", func() error { return f.contract.CancelOrder(f.consumer, "p1", "x") })
+	f.order(t, "order-again")
+
+	want := map[string]int{"Pending Order Request": 1}
+	require.Equal(t, want, f.statusCounts(t, "maker"))
+	require.Equal(t, map[string]int{"Pending Order Request": 1, "Pending": 1}, f.statusCounts(t, ""))
+	// Every status change wrote its own delta keys
+	require.Greater(t, f.counterKeys(statusCountObjectType), 3)
+
+	f.submit(t, "compact", func() error { return f.contract.CompactStatusCounters(f.manufacturer, "maker") })
+	require.Equal(t, want, f.statusCounts(t, "maker"))
+	require.Equal(t, []*PeriodCount{{Period: "2024-01-01", Orders: 2}}, f.metrics(t, PeriodDay).OrdersByPeriod)
+	// One key for the remaining status of maker, one for the order counter and one for other
+	require.Equal(t, 1, f.counterKeys(orderCountObjectType))
+	require.Equal(t, 2, f.counterKeys(statusCountObjectType))
+
+	// Deltas written after a compaction add to the compacted counter
+	require.NoError(t, f.accept("accept-again"))
+	require.Equal(t, map[string]int{"Accepted": 1}, f.statusCounts(t, "maker"))
+
+	f.submit(t, "compact-all", func() error { return f.contract.CompactStatusCounters(f.manufacturer, "") })
+	require.Equal(t, map[string]int{"Accepted": 1, "Pending": 1}, f.statusCounts(t, ""))
+	require.Equal(t, 2, f.counterKeys(statusCountObjectType))
+
+	f.submit(t, "rebuild", func() error { return f.contract.RebuildStatusCounters(f.manufacturer) })
+	require.Equal(t, map[string]int{"Accepted": 1, "Pending": 1}, f.statusCounts(t, ""))
+}
@@ -0,0 +1,236 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	deliveryDetailsObjectType = "deliveryDetails"
+	// deliveryDestinationObjectType keys the coordinates of an order's delivery address in tradeCollection.
+	// They are kept apart from the details because every org that endorses ProductDeliver must be able to
+	// read them, while the details stay in the manufacturer's implicit collection.
+	deliveryDestinationObjectType = "deliveryDestination"
+	transientDeliveryDetails      = "delivery_details"
+
+	// defaultManufacturerOrg owns products written before ManufacturerOrg was recorded
+	defaultManufacturerOrg = "Org1MSP"
+)
+
+// DeliveryDetails are the consumer's shipping details for an order. They are kept in the manufacturer
+// org's implicit private data collection and only their salted hash is recorded on the product. Their
+// Location is also shared with both trading orgs in tradeCollection, for the delivery geofence.
+type DeliveryDetails struct {
+	ProductID    string    `json:"ProductID"`
+	Address      string    `json:"Address"`
+	Phone        string    `json:"Phone"`
+	Instructions string    `json:"Instructions"`
+	Location     *GeoPoint `json:"Location,omitempty" metadata:",optional"` // coordinates of the address, for the delivery geofence
+	Salt         string    `json:"Salt"`
+}
+
+// ReadDeliveryDetails returns the delivery details of an order to the manufacturer org
+func (s *SmartContract) ReadDeliveryDetails(ctx contractapi.TransactionContextInterface, id string) (*DeliveryDetails, error) {
+	product, err := s.ReadProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if clientOrg != manufacturerOrg(product) {
+		return nil, errForbidden("Access denied: Only the manufacturer org can read delivery details")
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(deliveryDetailsObjectType, []string{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create delivery details key: %v", err)
+	}
+	detailsJSON, err := ctx.GetStub().GetPrivateData(implicitCollection(manufacturerOrg(product)), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delivery details: %v", err)
+	}
+	if detailsJSON == nil {
+		return nil, newContractError(CodeNotFound, "the product %s has no delivery details", id)
+	}
+
+	var details DeliveryDetails
+	if err := json.Unmarshal(detailsJSON, &details); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal delivery details JSON: %v", err)
+	}
+
+	return &details, nil
+}
+
+// PurgeDeliveryDetails permanently removes the delivery details of a delivered order from the manufacturer's
+// private data once the delivery is confirmed. The salted hash stays on the product as proof of what was
+// shipped to.
+func (s *SmartContract) PurgeDeliveryDetails(ctx contractapi.TransactionContextInterface, id string) error {
+	product, err := s.ReadProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+	if clientOrg != manufacturerOrg(product) {
+		return errForbidden("Access denied: Only the manufacturer org can purge delivery details")
+	}
+	if product.Status != "Delivered" {
+		return errInvalidState("the product %s has not been delivered yet", id)
+	}
+	// The details stay available until the consumer confirmed receipt or the payment was released
+	if product.ConfirmedDate == "" {
+		escrow, err := readEscrow(ctx, id)
+		if err != nil {
+			return err
+		}
+		if escrow == nil || escrow.Status != EscrowReleased {
+			return errInvalidState("the delivery of product %s has not been confirmed yet", id)
+		}
+	}
+	if product.DeliveryDetailsHash == "" {
+		return newContractError(CodeNotFound, "the product %s has no delivery details", id)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(deliveryDetailsObjectType, []string{id})
+	if err != nil {
+		return fmt.Errorf("failed to create delivery details key: %v", err)
+	}
+	if err := ctx.GetStub().PurgePrivateData(implicitCollection(manufacturerOrg(product)), key); err != nil {
+		return fmt.Errorf("failed to purge delivery details: %v", err)
+	}
+	destinationKey, err := ctx.GetStub().CreateCompositeKey(deliveryDestinationObjectType, []string{id})
+	if err != nil {
+		return fmt.Errorf("failed to create delivery destination key: %v", err)
+	}
+	if err := ctx.GetStub().PurgePrivateData(tradeCollection, destinationKey); err != nil {
+		return fmt.Errorf("failed to purge delivery destination: %v", err)
+	}
+
+	return nil
+}
+
+// storeDeliveryDetails saves the "delivery_details" transient field of an order into the manufacturer's
+// implicit collection and records their hash on the product. Details left over from an earlier order of
+// the same product are removed when the new order carries none.
+func storeDeliveryDetails(ctx contractapi.TransactionContextInterface, product *Product) error {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("error getting transient: %v", err)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(deliveryDetailsObjectType, []string{product.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create delivery details key: %v", err)
+	}
+	collection := implicitCollection(manufacturerOrg(product))
+
+	detailsJSON, ok := transientMap[transientDeliveryDetails]
+	if !ok {
+		if product.DeliveryDetailsHash != "" {
+			if err := ctx.GetStub().DelPrivateData(collection, key); err != nil {
+				return fmt.Errorf("failed to delete delivery details: %v", err)
+			}
+			product.DeliveryDetailsHash = ""
+		}
+		return putDeliveryDestination(ctx, product.ID, nil)
+	}
+
+	var details DeliveryDetails
+	if err := json.Unmarshal(detailsJSON, &details); err != nil {
+		return fmt.Errorf("failed to unmarshal %s JSON: %v", transientDeliveryDetails, err)
+	}
+	if details.Address == "" {
+		return errValidation("%s must include an Address", transientDeliveryDetails)
+	}
+	if details.Location != nil {
+		if err := details.Location.validate(); err != nil {
+			return errValidation("%s has an invalid Location: %v", transientDeliveryDetails, errorMessage(err))
+		}
+	}
+	if len(details.Salt) < minSaltLength {
+		return errValidation("%s must include a Salt of at least %d characters", transientDeliveryDetails, minSaltLength)
+	}
+	details.ProductID = product.ID
+
+	// Re-marshal so that the stored bytes, and therefore the hash, do not depend on the client's formatting
+	detailsJSON, err = json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to marshal delivery details JSON: %v", err)
+	}
+	if err := ctx.GetStub().PutPrivateData(collection, key, detailsJSON); err != nil {
+		return fmt.Errorf("failed to put delivery details to %s: %v", collection, err)
+	}
+
+	hash := sha256.Sum256(detailsJSON)
+	product.DeliveryDetailsHash = hex.EncodeToString(hash[:])
+
+	return putDeliveryDestination(ctx, product.ID, details.Location)
+}
+
+// putDeliveryDestination shares the coordinates of an order's delivery address with both trading orgs, or
+// deletes them when the order has none
+func putDeliveryDestination(ctx contractapi.TransactionContextInterface, id string, location *GeoPoint) error {
+	key, err := ctx.GetStub().CreateCompositeKey(deliveryDestinationObjectType, []string{id})
+	if err != nil {
+		return fmt.Errorf("failed to create delivery destination key: %v", err)
+	}
+	if location == nil {
+		if err := ctx.GetStub().DelPrivateData(tradeCollection, key); err != nil {
+			return fmt.Errorf("failed to delete delivery destination: %v", err)
+		}
+		return nil
+	}
+
+	locationJSON, err := json.Marshal(location)
+	if err != nil {
+		return fmt.Errorf("failed to marshal delivery destination JSON: %v", err)
+	}
+	if err := ctx.GetStub().PutPrivateData(tradeCollection, key, locationJSON); err != nil {
+		return fmt.Errorf("failed to put delivery destination to %s: %v", tradeCollection, err)
+	}
+	return nil
+}
+
+// readDeliveryDestination returns the coordinates of an order's delivery address, or nil when none were given
+func readDeliveryDestination(ctx contractapi.TransactionContextInterface, id string) (*GeoPoint, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(deliveryDestinationObjectType, []string{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create delivery destination key: %v", err)
+	}
+	locationJSON, err := ctx.GetStub().GetPrivateData(tradeCollection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delivery destination from %s: %v", tradeCollection, err)
+	}
+	if locationJSON == nil {
+		return nil, nil
+	}
+
+	var location GeoPoint
+	if err := json.Unmarshal(locationJSON, &location); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal delivery destination JSON: %v", err)
+	}
+	return &location, nil
+}
+
+// manufacturerOrg returns the MSP ID of the org that created a product
+func manufacturerOrg(product *Product) string {
+	if product.ManufacturerOrg == "" {
+		return defaultManufacturerOrg
+	}
+	return product.ManufacturerOrg
+}
+
+// implicitCollection returns the name of an org's implicit private data collection
+func implicitCollection(mspID string) string {
+	return "_implicit_org_" + mspID
+}
@@ -0,0 +1,235 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	epcisContext       = "https://ref.gs1.org/standards/epcis/2.0.0/epcis-context.jsonld"
+	epcisSchemaVersion = "2.0"
+	epcisTimeFormat    = "2006-01-02T15:04:05.000Z07:00"
+
+	// cbvBizStepPrefix and cbvDispositionPrefix expand bare CBV values into the URIs they are hashed as
+	cbvBizStepPrefix     = "https://ref.gs1.org/cbv/BizStep-"
+	cbvDispositionPrefix = "https://ref.gs1.org/cbv/Disp-"
+)
+
+// EPCISDocument is a GS1 EPCIS 2.0 JSON-LD document carrying the events of one or more products
+type EPCISDocument struct {
+	Context       []string  `json:"@context"`
+	Type          string    `json:"type"`
+	SchemaVersion string    `json:"schemaVersion"`
+	CreationDate  string    `json:"creationDate"`
+	EPCISBody     EPCISBody `json:"epcisBody"`
+}
+
+// EPCISBody holds the event list of an EPCIS document
+type EPCISBody struct {
+	EventList []*EPCISObjectEvent `json:"eventList"`
+}
+
+// EPCISObjectEvent is an EPCIS ObjectEvent describing what happened to a product at a point in time
+type EPCISObjectEvent struct {
+	Type                string          `json:"type"`
+	EventID             string          `json:"eventID"`
+	EventTime           string          `json:"eventTime"`
+	EventTimeZoneOffset string          `json:"eventTimeZoneOffset"`
+	EPCList             []string        `json:"epcList"`
+	QuantityList        []EPCISQuantity `json:"quantityList,omitempty" metadata:",optional"`
+	Action              string          `json:"action"`
+	BizStep             string          `json:"bizStep"`
+	Disposition         string          `json:"disposition"`
+	ReadPoint           *EPCISLocation  `json:"readPoint,omitempty" metadata:",optional"`
+}
+
+// EPCISQuantity identifies a class of objects (a GTIN without serial number) and how many were observed
+type EPCISQuantity struct {
+	EPCClass string  `json:"epcClass"`
+	Quantity float64 `json:"quantity"`
+}
+
+// EPCISLocation identifies a read point or business location
+type EPCISLocation struct {
+	ID string `json:"id"`
+}
+
+// epcisStep is the EPCIS action, CBV business step and CBV disposition recorded for a lifecycle event
+type epcisStep struct {
+	action      string
+	bizStep     string
+	disposition string
+}
+
+var (
+	epcisCommissioning   = epcisStep{action: "ADD", bizStep: "commissioning", disposition: "active"}
+	epcisDecommissioning = epcisStep{action: "DELETE", bizStep: "decommissioning", disposition: "inactive"}
+
+	// epcisStatusSteps maps the product status a transaction moved to onto the EPCIS event it represents.
+	// Placing or cancelling an order does not touch the product, so those statuses have no event.
+	epcisStatusSteps = map[string]epcisStep{
+		"Accepted":  {action: "OBSERVE", bizStep: "staging_outbound", disposition: "in_progress"},
+		"Shipped":   {action: "OBSERVE", bizStep: "shipping", disposition: "in_transit"},
+		"Delivered": {action: "OBSERVE", bizStep: "receiving", disposition: "in_progress"},
+	}
+)
+
+// ExportProductEPCIS returns the lifecycle of a product as an EPCIS 2.0 document
+func (s *SmartContract) ExportProductEPCIS(ctx contractapi.TransactionContextInterface, id string) (*EPCISDocument, error) {
+	return s.ExportEPCISDocument(ctx, []string{id})
+}
+
+// ExportEPCISDocument returns the lifecycles of a batch of products as a single EPCIS 2.0 document
+func (s *SmartContract) ExportEPCISDocument(ctx contractapi.TransactionContextInterface, ids []string) (*EPCISDocument, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	document := &EPCISDocument{
+		Context:       []string{epcisContext},
+		Type:          "EPCISDocument",
+		SchemaVersion: epcisSchemaVersion,
+		CreationDate:  txTimestamp.AsTime().UTC().Format(epcisTimeFormat),
+		EPCISBody:     EPCISBody{EventList: []*EPCISObjectEvent{}},
+	}
+
+	for _, id := range ids {
+		exists, err := s.ProductExists(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, errNotFound("product", id)
+		}
+
+		events, err := productEPCISEvents(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		document.EPCISBody.EventList = append(document.EPCISBody.EventList, events...)
+	}
+
+	return document, nil
+}
+
+// productEPCISEvents turns the ledger history of a product into EPCIS events. Only creation, status
+// transitions and deletion produce events; edits that leave the status unchanged are not observable
+// supply chain events.
+func productEPCISEvents(ctx contractapi.TransactionContextInterface, id string) ([]*EPCISObjectEvent, error) {
+	history, err := readProductHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*EPCISObjectEvent
+	var previous *Product
+	for _, entry := range history {
+		var step epcisStep
+		var ok bool
+		switch {
+		case entry.IsDelete:
+			step, ok = epcisDecommissioning, previous != nil
+		case previous == nil:
+			step, ok = epcisCommissioning, true
+		case entry.Product.Status != previous.Status:
+			step, ok = epcisStatusSteps[entry.Product.Status]
+		}
+		current := entry.Product
+		if current == nil {
+			current = previous
+		}
+		previous = entry.Product
+		if !ok {
+			continue
+		}
+
+		eventTime, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse history timestamp %s: %v", entry.Timestamp, err)
+		}
+
+		event := &EPCISObjectEvent{
+			Type:                "ObjectEvent",
+			EventTime:           eventTime.UTC().Format(epcisTimeFormat),
+			EventTimeZoneOffset: "+00:00",
+			EPCList:             []string{},
+			Action:              step.action,
+			BizStep:             step.bizStep,
+			Disposition:         step.disposition,
+		}
+		// A plain GTIN identifies a product class rather than an instance, so it goes in the quantity list
+		if identifier, err := parseGS1Identifier(current.ID); err == nil && identifier.Serial == "" {
+			event.QuantityList = []EPCISQuantity{{EPCClass: gs1DigitalLink(identifier), Quantity: 1}}
+		} else {
+			event.EPCList = []string{productEPC(current)}
+		}
+		// The only place the ledger knows is where a delivery was recorded
+		if !entry.IsDelete && current.Status == "Delivered" && current.ProofOfDelivery != nil {
+			event.ReadPoint = &EPCISLocation{ID: geoURI(current.ProofOfDelivery.Location)}
+		}
+		event.EventID = epcisEventHashID(event)
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// productEPC returns the URI identifying a product instance in EPCIS events. Serialised GS1 products use
+// their GS1 Digital Link; other IDs fall back to a URN in the supply chain namespace.
+func productEPC(product *Product) string {
+	if identifier, err := parseGS1Identifier(product.ID); err == nil {
+		return gs1DigitalLink(identifier)
+	}
+	return fmt.Sprintf("urn:supplychain:product:%s", url.PathEscape(product.ID))
+}
+
+// epcisEventHashID returns the CBV 2.0 event hash ID of an event: the SHA-256 of its pre-hash string, which
+// lists the event's fields in the canonical order with CBV values expanded to URIs. Exporting the same
+// history again yields the same IDs.
+func epcisEventHashID(event *EPCISObjectEvent) string {
+	lines := []string{
+		"eventType=" + event.Type,
+		"eventTime=" + event.EventTime,
+		"eventTimeZoneOffset=" + event.EventTimeZoneOffset,
+	}
+	if len(event.EPCList) > 0 {
+		epcs := append([]string{}, event.EPCList...)
+		sort.Strings(epcs)
+		lines = append(lines, "epcList")
+		for _, epc := range epcs {
+			lines = append(lines, "epc="+epc)
+		}
+	}
+	if len(event.QuantityList) > 0 {
+		lines = append(lines, "quantityList")
+		for _, quantity := range event.QuantityList {
+			lines = append(lines, "quantityElement", "epcClass="+quantity.EPCClass, "quantity="+strconv.FormatFloat(quantity.Quantity, 'f', -1, 64))
+		}
+	}
+	lines = append(lines,
+		"action="+event.Action,
+		"bizStep="+cbvBizStepPrefix+event.BizStep,
+		"disposition="+cbvDispositionPrefix+event.Disposition,
+	)
+	if event.ReadPoint != nil {
+		lines = append(lines, "readPoint", "id="+event.ReadPoint.ID)
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return "ni:///sha-256;" + hex.EncodeToString(hash[:]) + "?ver=CBV2.0"
+}
+
+// geoURI returns the RFC 5870 geo URI of a point, which EPCIS accepts as a read point
+func geoURI(point GeoPoint) string {
+	return "geo:" + strconv.FormatFloat(point.Latitude, 'f', -1, 64) + "," + strconv.FormatFloat(point.Longitude, 'f', -1, 64)
+}
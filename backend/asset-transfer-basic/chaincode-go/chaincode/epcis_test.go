@@ -0,0 +1,101 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var eventHashIDPattern = regexp.MustCompile(`^ni:///sha-256;[0-9a-f]{64}\?ver=CBV2\.0$`)
+
+// validateEPCIS validates a document against testdata/epcis-object-event.schema.json
+func validateEPCIS(t *testing.T, document *EPCISDocument) *gojsonschema.Result {
+	t.Helper()
+	schemaPath, err := filepath.Abs(filepath.Join("testdata", "epcis-object-event.schema.json"))
+	require.NoError(t, err)
+	documentJSON, err := json.Marshal(document)
+	require.NoError(t, err)
+
+	result, err := gojsonschema.Validate(gojsonschema.NewReferenceLoader("file://"+filepath.ToSlash(schemaPath)), gojsonschema.NewBytesLoader(documentJSON))
+	require.NoError(t, err)
+	return result
+}
+
+func TestExportEPCISDocument(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.submit(t, "create-class", func() error {
+		return f.contract.CreateProduct(f.manufacturer, "(01)10614141000415", "pears", "good", "5.00", "maker", "2024-01-01T00:00:00Z")
+	})
+	f.ship(t, true)
+	require.NoError(t, f.deliverAt(berlin))
+
+	var document *EPCISDocument
+	f.submit(t, "export", func() error {
+		var err error
+		document, err = f.contract.ExportEPCISDocument(f.manufacturer, []string{"p1", "(01)10614141000415"})
+		return err
+	})
+	result := validateEPCIS(t, document)
+	require.True(t, result.Valid(), "%v", result.Errors())
+
+	events := document.EPCISBody.EventList
+	var bizSteps []string
+	for _, event := range events {
+		bizSteps = append(bizSteps, event.BizStep)
+	}
+	// Placing the order does not touch the product and has no event
+	require.Equal(t, []string{"commissioning", "staging_outbound", "shipping", "receiving", "commissioning"}, bizSteps)
+
+	ids := make(map[string]bool)
+	for _, event := range events {
+		require.Regexp(t, eventHashIDPattern, event.EventID)
+		require.False(t, ids[event.EventID], "duplicate event ID %s", event.EventID)
+		ids[event.EventID] = true
+	}
+
+	// Only the delivery has a known place
+	for _, event := range events[:3] {
+		require.Nil(t, event.ReadPoint)
+	}
+	require.Equal(t, &EPCISLocation{ID: "geo:52.52,13.405"}, events[3].ReadPoint)
+
+	require.Equal(t, []string{"urn:supplychain:product:p1"}, events[0].EPCList)
+	require.Equal(t, []EPCISQuantity{{EPCClass: "https://id.gs1.org/01/10614141000415", Quantity: 1}}, events[4].QuantityList)
+
+	// Exporting again yields the same event IDs
+	var again *EPCISDocument
+	f.submit(t, "export-again", func() error {
+		var err error
+		again, err = f.contract.ExportEPCISDocument(f.manufacturer, []string{"p1", "(01)10614141000415"})
+		return err
+	})
+	require.Equal(t, events, again.EPCISBody.EventList)
+
+	// The schema catches values EPCIS does not define
+	again.EPCISBody.EventList[0].Action = "MOVE"
+	require.False(t, validateEPCIS(t, again).Valid())
+}
+
+func TestEPCISEventHashID(t *testing.T) {
+	event := &EPCISObjectEvent{
+		Type:                "ObjectEvent",
+		EventTime:           "2024-01-01T02:00:00.000Z",
+		EventTimeZoneOffset: "+00:00",
+		EPCList:             []string{"https://id.gs1.org/01/10614141000415/21/b", "https://id.gs1.org/01/10614141000415/21/a"},
+		Action:              "OBSERVE",
+		BizStep:             "shipping",
+		Disposition:         "in_transit",
+	}
+	id := epcisEventHashID(event)
+	require.Regexp(t, eventHashIDPattern, id)
+
+	// The order of the EPCs does not matter, every other field does
+	event.EPCList = []string{event.EPCList[1], event.EPCList[0]}
+	require.Equal(t, id, epcisEventHashID(event))
+	event.Disposition = "in_progress"
+	require.NotEqual(t, id, epcisEventHashID(event))
+}
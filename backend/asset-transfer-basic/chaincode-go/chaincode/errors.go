@@ -0,0 +1,79 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Error codes returned to clients in ContractError. They are part of the chaincode API: clients map them to
+// HTTP statuses and localized messages, so existing codes must not change.
+const (
+	CodeNotFound         = "NOT_FOUND"
+	CodeAlreadyExists    = "ALREADY_EXISTS"
+	CodeForbidden        = "FORBIDDEN"
+	CodeInvalidState     = "INVALID_STATE"
+	CodeValidationFailed = "VALIDATION_FAILED"
+	CodeInternal         = "INTERNAL"
+)
+
+// ContractError is an error a client can act on. It reaches the client serialized as JSON, for example
+// {"code":"NOT_FOUND","message":"the product 42 does not exist","details":{"kind":"product","id":"42"}}.
+// Errors that are not ContractErrors, such as world state failures, should be treated as INTERNAL.
+type ContractError struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details"`
+}
+
+func (e *ContractError) Error() string {
+	errorJSON, err := json.Marshal(e)
+	if err != nil {
+		return e.Message
+	}
+	return string(errorJSON)
+}
+
+// withDetail adds a machine-readable detail to the error
+func (e *ContractError) withDetail(key string, value string) *ContractError {
+	e.Details[key] = value
+	return e
+}
+
+func newContractError(code string, format string, args ...interface{}) *ContractError {
+	return &ContractError{Code: code, Message: fmt.Sprintf(format, args...), Details: map[string]string{}}
+}
+
+// errNotFound reports a missing record, such as a product or an invoice
+func errNotFound(kind string, id string) *ContractError {
+	return newContractError(CodeNotFound, "the %s %s does not exist", kind, id).withDetail("kind", kind).withDetail("id", id)
+}
+
+// errAlreadyExists reports a record that cannot be created because its ID is taken
+func errAlreadyExists(kind string, id string) *ContractError {
+	return newContractError(CodeAlreadyExists, "the %s %s already exists", kind, id).withDetail("kind", kind).withDetail("id", id)
+}
+
+// errForbidden reports a caller that may not perform the transaction
+func errForbidden(format string, args ...interface{}) *ContractError {
+	return newContractError(CodeForbidden, format, args...)
+}
+
+// errInvalidState reports a transaction that is not allowed in the current state of a record, such as
+// shipping a product that was already delivered
+func errInvalidState(format string, args ...interface{}) *ContractError {
+	return newContractError(CodeInvalidState, format, args...)
+}
+
+// errValidation reports an invalid argument or transient field
+func errValidation(format string, args ...interface{}) *ContractError {
+	return newContractError(CodeValidationFailed, format, args...)
+}
+
+// errorMessage returns the message of err without the JSON envelope of a ContractError, for wrapping it in
+// another error
+func errorMessage(err error) string {
+	if contractErr, ok := err.(*ContractError); ok {
+		return contractErr.Message
+	}
+	return err.Error()
+}
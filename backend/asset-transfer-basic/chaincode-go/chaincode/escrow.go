@@ -0,0 +1,247 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	escrowObjectType = "escrow"
+
+	EscrowLocked   = "Locked"
+	EscrowReleased = "Released"
+	EscrowRefunded = "Refunded"
+)
+
+// Escrow holds the buyer's settlement tokens for an accepted order until delivery is confirmed
+type Escrow struct {
+	ProductID   string `json:"ProductID"`
+	Payer       string `json:"Payer"` // consumer account debited when the order was accepted
+	Payee       string `json:"Payee"` // manufacturer account credited when delivery is confirmed
+	Amount      int64  `json:"Amount"`
+	Penalty     int64  `json:"Penalty"` // part of Amount refunded to the payer on release, see CreditNote
+	Status      string `json:"Status"`
+	LockedTxID  string `json:"LockedTxID"`
+	SettledTxID string `json:"SettledTxID"`
+}
+
+// GetEscrow returns the escrow of the latest accepted order of a product
+func (s *SmartContract) GetEscrow(ctx contractapi.TransactionContextInterface, id string) (*Escrow, error) {
+	escrow, err := readEscrow(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if escrow == nil {
+		return nil, newContractError(CodeNotFound, "the product %s has no escrow", id)
+	}
+	return escrow, nil
+}
+
+// ConfirmDelivery lets the consumer who placed the order confirm receipt of a delivered product, which
+// releases the escrowed payment to the manufacturer
+func (s *SmartContract) ConfirmDelivery(ctx contractapi.TransactionContextInterface, id string, confirmeddate string) error {
+	product, err := s.ReadProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := checkOrderingConsumer(ctx, product); err != nil {
+		return err
+	}
+	if product.Status != "Delivered" {
+		return errInvalidState("the product %s has not been delivered yet", id)
+	}
+	if product.ConfirmedDate != "" {
+		return errInvalidState("the delivery of product %s is already confirmed", id)
+	}
+
+	if err := settleEscrow(ctx, product, EscrowReleased); err != nil {
+		return err
+	}
+
+	product.ConfirmedDate = confirmeddate
+	product.ModifiedDate = confirmeddate
+
+	return putProduct(ctx, product)
+}
+
+// CancelOrder lets the consumer withdraw an order that has not been shipped yet. Escrowed funds are refunded.
+func (s *SmartContract) CancelOrder(ctx contractapi.TransactionContextInterface, id string, modifieddate string) error {
+	product, err := s.ReadProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := checkOrderingConsumer(ctx, product); err != nil {
+		return err
+	}
+	if product.Status != "Pending Order Request" && product.Status != "Accepted" {
+		return errInvalidState("the order for product %s cannot be cancelled in status %s", id, product.Status)
+	}
+
+	if err := settleEscrow(ctx, product, EscrowRefunded); err != nil {
+		return err
+	}
+
+	product.Status = "Cancelled"
+	product.ModifiedDate = modifieddate
+	// The consumer is no longer party to an order of the product and must not be debited again
+	product.ConsumerAccount = ""
+
+	return putProduct(ctx, product)
+}
+
+// ProductReject lets the manufacturer turn down an order that has not been shipped yet. Escrowed funds are
+// refunded.
+func (s *SmartContract) ProductReject(ctx contractapi.TransactionContextInterface, id string, manufacturer string, modifieddate string) error {
+	product, err := s.ReadProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if product.Manufacturer != manufacturer {
+		return errForbidden("You can only reject orders for your own products")
+	}
+	if product.Status != "Pending Order Request" && product.Status != "Accepted" {
+		return errInvalidState("the order for product %s cannot be rejected in status %s", id, product.Status)
+	}
+
+	if err := settleEscrow(ctx, product, EscrowRefunded); err != nil {
+		return err
+	}
+
+	product.Status = "Rejected"
+	product.ModifiedDate = modifieddate
+	product.ConsumerAccount = ""
+
+	return putProduct(ctx, product)
+}
+
+// lockEscrow debits the price of an order from the consumer's account into escrow, payable to the invoking
+// manufacturer. Orders placed before consumer accounts were recorded are not escrowed.
+func lockEscrow(ctx contractapi.TransactionContextInterface, product *Product) error {
+	if product.ConsumerAccount == "" {
+		return nil
+	}
+	existing, err := readEscrow(ctx, product.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Status == EscrowLocked {
+		return errInvalidState("the product %s already has funds locked in escrow", product.ID)
+	}
+
+	price, err := productPrice(ctx, product)
+	if err != nil {
+		return err
+	}
+	amount, err := parseAmount(price)
+	if err != nil {
+		return errInvalidState("the price of product %s cannot be escrowed: %v", product.ID, errorMessage(err))
+	}
+	payee, err := getClientID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := addBalance(ctx, product.ConsumerAccount, -amount); err != nil {
+		return err
+	}
+
+	return putEscrow(ctx, &Escrow{
+		ProductID:  product.ID,
+		Payer:      product.ConsumerAccount,
+		Payee:      payee,
+		Amount:     amount,
+		Status:     EscrowLocked,
+		LockedTxID: ctx.GetStub().GetTxID(),
+	})
+}
+
+// settleEscrow pays a locked escrow out to the manufacturer (EscrowReleased) or back to the consumer
+// (EscrowRefunded). Products without a locked escrow are left alone.
+func settleEscrow(ctx contractapi.TransactionContextInterface, product *Product, outcome string) error {
+	escrow, err := readEscrow(ctx, product.ID)
+	if err != nil {
+		return err
+	}
+	if escrow == nil || escrow.Status != EscrowLocked {
+		return nil
+	}
+
+	if outcome == EscrowRefunded {
+		if err := addBalance(ctx, escrow.Payer, escrow.Amount); err != nil {
+			return err
+		}
+	} else {
+		// A late delivery penalty is paid back to the consumer out of the escrowed amount
+		penalty, err := applyCreditNote(ctx, product, escrow.Amount)
+		if err != nil {
+			return err
+		}
+		if err := addBalance(ctx, escrow.Payee, escrow.Amount-penalty); err != nil {
+			return err
+		}
+		if penalty > 0 {
+			if err := addBalance(ctx, escrow.Payer, penalty); err != nil {
+				return err
+			}
+		}
+		escrow.Penalty = penalty
+	}
+
+	escrow.Status = outcome
+	escrow.SettledTxID = ctx.GetStub().GetTxID()
+
+	return putEscrow(ctx, escrow)
+}
+
+// checkOrderingConsumer ensures the invoking client placed the current order of a product. The consumer org
+// itself is checked by beforeTransaction.
+func checkOrderingConsumer(ctx contractapi.TransactionContextInterface, product *Product) error {
+	if product.ConsumerAccount == "" {
+		return nil
+	}
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return err
+	}
+	if clientID != product.ConsumerAccount {
+		return errForbidden("You can only act on orders that you placed")
+	}
+	return nil
+}
+
+func readEscrow(ctx contractapi.TransactionContextInterface, id string) (*Escrow, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(escrowObjectType, []string{id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create escrow key: %v", err)
+	}
+	escrowJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if escrowJSON == nil {
+		return nil, nil
+	}
+
+	var escrow Escrow
+	if err := json.Unmarshal(escrowJSON, &escrow); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal escrow JSON: %v", err)
+	}
+	return &escrow, nil
+}
+
+func putEscrow(ctx contractapi.TransactionContextInterface, escrow *Escrow) error {
+	key, err := ctx.GetStub().CreateCompositeKey(escrowObjectType, []string{escrow.ProductID})
+	if err != nil {
+		return fmt.Errorf("failed to create escrow key: %v", err)
+	}
+	escrowJSON, err := json.Marshal(escrow)
+	if err != nil {
+		return fmt.Errorf("failed to marshal escrow JSON: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, escrowJSON); err != nil {
+		return fmt.Errorf("failed to put to world state. %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	manufacturerAccount = "manufacturer-client"
+	consumerAccount     = "consumer-client"
+)
+
+// escrowFixture is a ledger with a product priced at 10.00 and a consumer holding 25.00 in tokens
+type escrowFixture struct {
+	stub         *ledgerStub
+	contract     *SmartContract
+	manufacturer *mocks.TransactionContext
+	consumer     *mocks.TransactionContext
+}
+
+func newEscrowFixture(t *testing.T) *escrowFixture {
+	stub := newLedgerStub()
+	f := &escrowFixture{
+		stub:         stub,
+		contract:     &SmartContract{},
+		manufacturer: newClientContext(stub, "Org1MSP", manufacturerAccount),
+		consumer:     newClientContext(stub, "Org2MSP", consumerAccount),
+	}
+
+	f.submit(t, "mint", func() error { return NewTokenContract().Mint(f.manufacturer, consumerAccount, 2500) })
+	f.submit(t, "create", func() error {
+		return f.contract.CreateProduct(f.manufacturer, "p1", "apple", "good", "10.00", "maker", "2024-01-01T00:00:00Z")
+	})
+	return f
+}
+
+// submit runs a transaction that must succeed and commits it
+func (f *escrowFixture) submit(t *testing.T, txID string, transaction func() error) {
+	t.Helper()
+	require.NoError(t, f.try(txID, transaction))
+}
+
+// try runs a transaction and commits it when it succeeds
+func (f *escrowFixture) try(txID string, transaction func() error) error {
+	f.stub.begin(txID)
+	if err := transaction(); err != nil {
+		return err
+	}
+	f.stub.commit()
+	return nil
+}
+
+func (f *escrowFixture) order(t *testing.T, txID string) {
+	f.submit(t, txID, func() error { return f.contract.ProductOrder(f.consumer, "p1", "bob", "x") })
+}
+
+func (f *escrowFixture) accept(txID string) error {
+	return f.try(txID, func() error { return f.contract.ProductAccept(f.manufacturer, "p1", "maker", "x") })
+}
+
+func (f *escrowFixture) balance(t *testing.T, account string) int64 {
+	t.Helper()
+	balance, err := readBalance(f.manufacturer, account)
+	require.NoError(t, err)
+	return balance
+}
+
+func (f *escrowFixture) escrow(t *testing.T) *Escrow {
+	t.Helper()
+	escrow, err := f.contract.GetEscrow(f.manufacturer, "p1")
+	require.NoError(t, err)
+	return escrow
+}
+
+func (f *escrowFixture) product(t *testing.T) *Product {
+	t.Helper()
+	product, err := f.contract.ReadProduct(f.manufacturer, "p1")
+	require.NoError(t, err)
+	return product
+}
+
+func TestEscrowReleasedOnConfirmedDelivery(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.order(t, "order")
+	require.NoError(t, f.accept("accept"))
+
+	escrow := f.escrow(t)
+	require.Equal(t, EscrowLocked, escrow.Status)
+	require.Equal(t, int64(1000), escrow.Amount)
+	require.Equal(t, consumerAccount, escrow.Payer)
+	require.Equal(t, manufacturerAccount, escrow.Payee)
+	require.Equal(t, int64(1500), f.balance(t, consumerAccount))
+
+	f.submit(t, "ship", func() error { return f.contract.ProductShip(f.manufacturer, "p1", "x") })
+	f.submit(t, "deliver", func() error { return f.contract.ProductDeliver(f.manufacturer, "p1", "maker", "x") })
+	require.Equal(t, EscrowLocked, f.escrow(t).Status)
+	require.Equal(t, int64(0), f.balance(t, manufacturerAccount))
+
+	f.submit(t, "confirm", func() error { return f.contract.ConfirmDelivery(f.consumer, "p1", "x") })
+	require.Equal(t, EscrowReleased, f.escrow(t).Status)
+	require.Equal(t, int64(1000), f.balance(t, manufacturerAccount))
+	require.Equal(t, int64(1500), f.balance(t, consumerAccount))
+
+	err := f.try("confirm-again", func() error { return f.contract.ConfirmDelivery(f.consumer, "p1", "x") })
+	RequireContractError(t, err, CodeInvalidState)
+	require.Equal(t, int64(1000), f.balance(t, manufacturerAccount))
+}
+
+func TestAcceptRequiresPendingOrder(t *testing.T) {
+	f := newEscrowFixture(t)
+
+	RequireContractError(t, f.accept("accept-unordered"), CodeInvalidState)
+
+	f.order(t, "order")
+	require.NoError(t, f.accept("accept"))
+	require.Equal(t, int64(1500), f.balance(t, consumerAccount))
+
+	// Accepting again must not debit the consumer a second time
+	RequireContractError(t, f.accept("accept-again"), CodeInvalidState)
+	require.Equal(t, int64(1500), f.balance(t, consumerAccount))
+
+	// Nor may a cancelled order be accepted
+	f.submit(t, "cancel", func() error { return f.contract.CancelOrder(f.consumer, "p1", "x") })
+	RequireContractError(t, f.accept("accept-cancelled"), CodeInvalidState)
+	require.Equal(t, int64(2500), f.balance(t, consumerAccount))
+}
+
+func TestOrderRefusedWhileEscrowLocked(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.order(t, "order")
+	require.NoError(t, f.accept("accept"))
+
+	err := f.try("order-again", func() error { return f.contract.ProductOrder(f.consumer, "p1", "bob", "x") })
+	RequireContractError(t, err, CodeInvalidState)
+	require.Equal(t, consumerAccount, f.product(t).ConsumerAccount)
+	require.Equal(t, EscrowLocked, f.escrow(t).Status)
+}
+
+func TestLockEscrowRefusesSecondLock(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.order(t, "order")
+	require.NoError(t, f.accept("accept"))
+
+	f.stub.begin("lock-again")
+	RequireContractError(t, lockEscrow(f.manufacturer, f.product(t)), CodeInvalidState)
+}
+
+func TestEscrowRefundedOnCancelAndReject(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		settle func(f *escrowFixture) error
+	}{
+		{
+			name:   "cancelled by the consumer",
+			status: "Cancelled",
+			settle: func(f *escrowFixture) error { return f.contract.CancelOrder(f.consumer, "p1", "x") },
+		},
+		{
+			name:   "rejected by the manufacturer",
+			status: "Rejected",
+			settle: func(f *escrowFixture) error { return f.contract.ProductReject(f.manufacturer, "p1", "maker", "x") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newEscrowFixture(t)
+			f.order(t, "order")
+			require.NoError(t, f.accept("accept"))
+
+			f.submit(t, "settle", func() error { return tt.settle(f) })
+			require.Equal(t, EscrowRefunded, f.escrow(t).Status)
+			require.Equal(t, int64(2500), f.balance(t, consumerAccount))
+			product := f.product(t)
+			require.Equal(t, tt.status, product.Status)
+			require.Empty(t, product.ConsumerAccount)
+
+			// A new order locks a new escrow for its own consumer
+			f.order(t, "reorder")
+			require.NoError(t, f.accept("reaccept"))
+			require.Equal(t, EscrowLocked, f.escrow(t).Status)
+			require.Equal(t, int64(1500), f.balance(t, consumerAccount))
+		})
+	}
+}
+
+func TestAcceptFailsOnInsufficientFunds(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.submit(t, "reprice", func() error {
+		return f.contract.UpdateProduct(f.manufacturer, "p1", "apple", "good", "30.00", "maker", "x")
+	})
+	f.order(t, "order")
+
+	RequireContractError(t, f.accept("accept"), CodeInvalidState)
+	require.Equal(t, "Pending Order Request", f.product(t).Status)
+	require.Equal(t, int64(2500), f.balance(t, consumerAccount))
+}
+
+func TestShipRequiresAcceptedOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		setup  func(t *testing.T, f *escrowFixture)
+	}{
+		{name: "not ordered", status: "Pending", setup: func(t *testing.T, f *escrowFixture) {}},
+		{name: "pending", status: "Pending Order Request", setup: func(t *testing.T, f *escrowFixture) { f.order(t, "order") }},
+		{name: "cancelled", status: "Cancelled", setup: func(t *testing.T, f *escrowFixture) {
+			f.order(t, "order")
+			f.submit(t, "cancel", func() error { return f.contract.CancelOrder(f.consumer, "p1", "x") })
+		}},
+		{name: "rejected", status: "Rejected", setup: func(t *testing.T, f *escrowFixture) {
+			f.order(t, "order")
+			f.submit(t, "reject", func() error { return f.contract.ProductReject(f.manufacturer, "p1", "maker", "x") })
+		}},
+		{name: "already shipped", status: "Shipped", setup: func(t *testing.T, f *escrowFixture) {
+			f.order(t, "order")
+			require.NoError(t, f.accept("accept"))
+			f.submit(t, "ship", func() error { return f.contract.ProductShip(f.manufacturer, "p1", "x") })
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newEscrowFixture(t)
+			tt.setup(t, f)
+
+			err := f.try("ship-refused", func() error { return f.contract.ProductShip(f.manufacturer, "p1", "x") })
+			RequireContractError(t, err, CodeInvalidState)
+			_, err = f.contract.ShipProducts(f.manufacturer, []string{"p1"}, "carrier", "", "T1", "here", "there", "x")
+			RequireContractError(t, err, CodeInvalidState)
+
+			// Nor can the product be delivered without an escrow and an invoice
+			err = f.try("deliver-refused", func() error { return f.contract.ProductDeliver(f.manufacturer, "p1", "maker", "x") })
+			if tt.status == "Shipped" {
+				require.NoError(t, err)
+				return
+			}
+			RequireContractError(t, err, CodeInvalidState)
+			require.Equal(t, tt.status, f.product(t).Status)
+		})
+	}
+}
+
+func TestDeliverOnlyOnce(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.order(t, "order")
+	require.NoError(t, f.accept("accept"))
+	f.submit(t, "ship", func() error { return f.contract.ProductShip(f.manufacturer, "p1", "x") })
+	f.submit(t, "deliver", func() error { return f.contract.ProductDeliver(f.manufacturer, "p1", "maker", "delivered") })
+
+	err := f.try("deliver-again", func() error { return f.contract.ProductDeliver(f.manufacturer, "p1", "maker", "again") })
+	RequireContractError(t, err, CodeInvalidState)
+
+	f.submit(t, "confirm", func() error { return f.contract.ConfirmDelivery(f.consumer, "p1", "x") })
+	err = f.try("deliver-confirmed", func() error { return f.contract.ProductDeliver(f.manufacturer, "p1", "maker", "again") })
+	RequireContractError(t, err, CodeInvalidState)
+	require.Equal(t, "delivered", f.product(t).DeliveredDate)
+}
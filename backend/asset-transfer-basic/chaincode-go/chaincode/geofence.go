@@ -0,0 +1,197 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	geofencePolicyConfig = "geofencePolicy"
+
+	// GeofenceFlag records deliveries outside the radius on the product, GeofenceReject refuses them
+	GeofenceFlag   = "flag"
+	GeofenceReject = "reject"
+
+	defaultGeofenceRadiusMeters = 500
+
+	ProofWithinGeofence  = "Within Geofence"
+	ProofOutsideGeofence = "Outside Geofence"
+	ProofUnverified      = "Unverified" // no destination coordinates were known
+
+	FlagOutsideGeofence = "Delivered Outside Geofence"
+
+	earthRadiusMeters = 6371008.8
+)
+
+// GeoPoint is a WGS 84 position in decimal degrees
+type GeoPoint struct {
+	Latitude  float64 `json:"Latitude"`
+	Longitude float64 `json:"Longitude"`
+}
+
+// GeofencePolicy decides how far from its destination a delivery may be recorded and what happens when
+// it is further away. With RequireLocation set, ProductDeliver without coordinates is refused.
+type GeofencePolicy struct {
+	RadiusMeters    float64 `json:"RadiusMeters"`
+	Mode            string  `json:"Mode"`
+	RequireLocation bool    `json:"RequireLocation"`
+}
+
+// ProofOfDelivery is where a delivery was recorded and how it compared with the destination
+type ProofOfDelivery struct {
+	Location       GeoPoint `json:"Location"`
+	DistanceMeters float64  `json:"DistanceMeters"` // whole meters from the destination, 0 when Unverified
+	RadiusMeters   float64  `json:"RadiusMeters"`
+	Status         string   `json:"Status"`
+}
+
+// SetGeofencePolicy sets the delivery geofence radius, whether deliveries outside it are flagged or
+// rejected, and whether deliveries must be recorded with coordinates
+func (s *SmartContract) SetGeofencePolicy(ctx contractapi.TransactionContextInterface, radiusMeters float64, mode string, requireLocation bool) error {
+	if radiusMeters <= 0 {
+		return errValidation("the geofence radius must be positive")
+	}
+	if mode != GeofenceFlag && mode != GeofenceReject {
+		return errValidation("unknown geofence mode %s, expected %s or %s", mode, GeofenceFlag, GeofenceReject)
+	}
+
+	return writeConfig(ctx, geofencePolicyConfig, &GeofencePolicy{
+		RadiusMeters:    radiusMeters,
+		Mode:            mode,
+		RequireLocation: requireLocation,
+	})
+}
+
+// GetGeofencePolicy returns the delivery geofence policy
+func (s *SmartContract) GetGeofencePolicy(ctx contractapi.TransactionContextInterface) (*GeofencePolicy, error) {
+	return geofencePolicy(ctx)
+}
+
+// ProductDeliverWithLocation marks a product as delivered at the given GPS coordinates. They are compared
+// with the location in the order's delivery details, or else the destination of the product's shipment.
+func (s *SmartContract) ProductDeliverWithLocation(ctx contractapi.TransactionContextInterface, id string, manufacturer string, delivereddate string, latitude float64, longitude float64) error {
+	return s.deliverProduct(ctx, id, manufacturer, delivereddate, &GeoPoint{Latitude: latitude, Longitude: longitude})
+}
+
+// recordProofOfDelivery checks a delivery location against the destination of the order and records the
+// result on the product, rejecting or flagging deliveries outside the geofence as the policy says
+func recordProofOfDelivery(ctx contractapi.TransactionContextInterface, product *Product, location *GeoPoint) error {
+	policy, err := geofencePolicy(ctx)
+	if err != nil {
+		return err
+	}
+
+	product.ProofOfDelivery = nil
+	if location == nil {
+		if policy.RequireLocation {
+			return errInvalidState("the delivery must be recorded with its location, use ProductDeliverWithLocation")
+		}
+		return nil
+	}
+	if err := location.validate(); err != nil {
+		return err
+	}
+
+	proof := &ProofOfDelivery{
+		Location:     *location,
+		RadiusMeters: policy.RadiusMeters,
+		Status:       ProofUnverified,
+	}
+	destination, err := destinationOf(ctx, product)
+	if err != nil {
+		return err
+	}
+	if destination != nil {
+		// Rounded so that peers on different architectures agree on the value
+		proof.DistanceMeters = math.Round(distanceMeters(*location, *destination))
+		proof.Status = ProofWithinGeofence
+		if proof.DistanceMeters > policy.RadiusMeters {
+			proof.Status = ProofOutsideGeofence
+		}
+	}
+
+	if proof.Status == ProofOutsideGeofence {
+		if policy.Mode == GeofenceReject {
+			return errInvalidState("the delivery location is %.0f meters from the destination of product %s, more than the %.0f meters allowed",
+				proof.DistanceMeters, product.ID, policy.RadiusMeters)
+		}
+		addFlag(product, FlagOutsideGeofence)
+	}
+	product.ProofOfDelivery = proof
+
+	return nil
+}
+
+// destinationOf returns the destination coordinates of an order, or nil when none were given. The consumer's
+// own delivery details come first; the shipper's shipment destination only stands in when they carry no
+// location. It only reads data that every endorsing org holds, so that their peers reach the same proof of
+// delivery.
+func destinationOf(ctx contractapi.TransactionContextInterface, product *Product) (*GeoPoint, error) {
+	destination, err := orderDestination(ctx, product)
+	if err != nil || destination != nil {
+		return destination, err
+	}
+
+	if product.ShipmentID != "" {
+		key, err := ctx.GetStub().CreateCompositeKey(shipmentObjectType, []string{product.ShipmentID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create shipment key: %v", err)
+		}
+		shipmentJSON, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if shipmentJSON != nil {
+			var shipment Shipment
+			if err := json.Unmarshal(shipmentJSON, &shipment); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal shipment JSON: %v", err)
+			}
+			return shipment.DestinationLocation, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// orderDestination returns the location in the delivery details of the current order, or nil when there is
+// none. It reads tradeCollection rather than the manufacturer's implicit collection, which the consumer
+// org's endorsing peers do not hold.
+func orderDestination(ctx contractapi.TransactionContextInterface, product *Product) (*GeoPoint, error) {
+	if product.DeliveryDetailsHash == "" {
+		return nil, nil
+	}
+	return readDeliveryDestination(ctx, product.ID)
+}
+
+// distanceMeters returns the great-circle distance between two points using the haversine formula
+func distanceMeters(from GeoPoint, to GeoPoint) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+
+	deltaLatitude := toRadians(to.Latitude - from.Latitude)
+	deltaLongitude := toRadians(to.Longitude - from.Longitude)
+	a := math.Sin(deltaLatitude/2)*math.Sin(deltaLatitude/2) +
+		math.Cos(toRadians(from.Latitude))*math.Cos(toRadians(to.Latitude))*math.Sin(deltaLongitude/2)*math.Sin(deltaLongitude/2)
+
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+func (p *GeoPoint) validate() error {
+	if p.Latitude < -90 || p.Latitude > 90 {
+		return errValidation("latitude %v is outside -90 to 90 degrees", p.Latitude)
+	}
+	if p.Longitude < -180 || p.Longitude > 180 {
+		return errValidation("longitude %v is outside -180 to 180 degrees", p.Longitude)
+	}
+	return nil
+}
+
+func geofencePolicy(ctx contractapi.TransactionContextInterface) (*GeofencePolicy, error) {
+	policy := &GeofencePolicy{RadiusMeters: defaultGeofenceRadiusMeters, Mode: GeofenceFlag}
+	if _, err := readConfig(ctx, geofencePolicyConfig, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
@@ -0,0 +1,127 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Berlin is where testDeliveryDetails deliver to, Potsdam is some 27 km away
+var (
+	berlin  = GeoPoint{Latitude: 52.52, Longitude: 13.405}
+	potsdam = GeoPoint{Latitude: 52.39, Longitude: 13.065}
+)
+
+// ship orders p1, with testDeliveryDetails when withDetails is set, accepts and ships it and returns the
+// shipment ID
+func (f *escrowFixture) ship(t *testing.T, withDetails bool) string {
+	t.Helper()
+	f.submit(t, "order", func() error {
+		if withDetails {
+			f.stub.transient[transientDeliveryDetails] = []byte(testDeliveryDetails)
+		}
+		return f.contract.ProductOrder(f.consumer, "p1", "bob", "x")
+	})
+	require.NoError(t, f.accept("accept"))
+
+	var shipmentID string
+	f.submit(t, "ship", func() error {
+		var err error
+		shipmentID, err = f.contract.ShipProducts(f.manufacturer, []string{"p1"}, "carrier", "", "T1", "factory", "customer", "x")
+		return err
+	})
+	return shipmentID
+}
+
+func (f *escrowFixture) deliverAt(location GeoPoint) error {
+	return f.try("deliver", func() error {
+		return f.contract.ProductDeliverWithLocation(f.manufacturer, "p1", "maker", "x", location.Latitude, location.Longitude)
+	})
+}
+
+func TestDeliveryCheckedAgainstOrderDestination(t *testing.T) {
+	f := newEscrowFixture(t)
+	shipmentID := f.ship(t, true)
+
+	// The shipper cannot move the destination the consumer gave
+	err := f.try("move", func() error {
+		return f.contract.SetShipmentDestination(f.manufacturer, shipmentID, potsdam.Latitude, potsdam.Longitude)
+	})
+	RequireContractError(t, err, CodeInvalidState)
+
+	require.NoError(t, f.deliverAt(GeoPoint{Latitude: 52.521, Longitude: 13.406}))
+	proof := f.product(t).ProofOfDelivery
+	require.Equal(t, ProofWithinGeofence, proof.Status)
+	require.Equal(t, float64(130), proof.DistanceMeters)
+	require.NotContains(t, f.product(t).Flags, FlagOutsideGeofence)
+}
+
+func TestDeliveryOutsideGeofence(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{mode: GeofenceFlag},
+		{mode: GeofenceReject, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			f := newEscrowFixture(t)
+			f.submit(t, "policy", func() error { return f.contract.SetGeofencePolicy(f.manufacturer, 1000, tt.mode, false) })
+			f.ship(t, true)
+
+			err := f.deliverAt(potsdam)
+			if tt.wantErr {
+				RequireContractError(t, err, CodeInvalidState)
+				require.Equal(t, "Shipped", f.product(t).Status)
+				return
+			}
+			require.NoError(t, err)
+			product := f.product(t)
+			require.Equal(t, "Delivered", product.Status)
+			require.Equal(t, ProofOutsideGeofence, product.ProofOfDelivery.Status)
+			require.Contains(t, product.Flags, FlagOutsideGeofence)
+		})
+	}
+}
+
+func TestShipmentDestinationStandsInForOrdersWithout(t *testing.T) {
+	f := newEscrowFixture(t)
+	shipmentID := f.ship(t, false)
+
+	consumerErr := f.try("set-consumer", func() error {
+		return f.contract.SetShipmentDestination(f.consumer, shipmentID, berlin.Latitude, berlin.Longitude)
+	})
+	RequireContractError(t, consumerErr, CodeForbidden)
+	f.submit(t, "set", func() error {
+		return f.contract.SetShipmentDestination(f.manufacturer, shipmentID, berlin.Latitude, berlin.Longitude)
+	})
+
+	require.NoError(t, f.deliverAt(berlin))
+	require.Equal(t, ProofWithinGeofence, f.product(t).ProofOfDelivery.Status)
+
+	// Once delivered, the destination is final
+	err := f.try("move", func() error {
+		return f.contract.SetShipmentDestination(f.manufacturer, shipmentID, potsdam.Latitude, potsdam.Longitude)
+	})
+	RequireContractError(t, err, CodeInvalidState)
+}
+
+func TestDeliveryWithoutDestinationIsUnverified(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.ship(t, false)
+
+	require.NoError(t, f.deliverAt(potsdam))
+	require.Equal(t, ProofUnverified, f.product(t).ProofOfDelivery.Status)
+}
+
+func TestDeliveryLocationRequiredByPolicy(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.submit(t, "policy", func() error { return f.contract.SetGeofencePolicy(f.manufacturer, 500, GeofenceFlag, true) })
+	f.ship(t, true)
+
+	err := f.try("deliver", func() error { return f.contract.ProductDeliver(f.manufacturer, "p1", "maker", "x") })
+	RequireContractError(t, err, CodeInvalidState)
+	require.NoError(t, f.deliverAt(berlin))
+}
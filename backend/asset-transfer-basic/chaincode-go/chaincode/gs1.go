@@ -0,0 +1,245 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// IdentifierModeAny accepts any non-empty product ID
+	IdentifierModeAny = "any"
+	// IdentifierModeGS1 requires product IDs to be a GTIN-14 or an SGTIN element string
+	IdentifierModeGS1 = "gs1"
+
+	identifierModeConfig = "identifierMode"
+	gtinIndex            = "gtin~id"
+	maxSerialLength      = 20
+)
+
+// GS1Identifier is a product ID broken into its GS1 parts. Serial is empty for a plain GTIN-14.
+type GS1Identifier struct {
+	GTIN   string `json:"GTIN"`
+	Serial string `json:"Serial"`
+}
+
+// SetIdentifierMode selects how CreateProduct validates product IDs, either "any" or "gs1"
+func (s *SmartContract) SetIdentifierMode(ctx contractapi.TransactionContextInterface, mode string) error {
+	if mode != IdentifierModeAny && mode != IdentifierModeGS1 {
+		return errValidation("unknown identifier mode %s, expected %s or %s", mode, IdentifierModeAny, IdentifierModeGS1)
+	}
+
+	return writeConfig(ctx, identifierModeConfig, mode)
+}
+
+// GetIdentifierMode returns the identifier mode currently enforced by CreateProduct
+func (s *SmartContract) GetIdentifierMode(ctx contractapi.TransactionContextInterface) (string, error) {
+	return identifierMode(ctx)
+}
+
+// GetProductsByGTIN returns every serialised product (and the plain GTIN-14 product, if any) sharing a GTIN
+func (s *SmartContract) GetProductsByGTIN(ctx contractapi.TransactionContextInterface, gtin string) ([]*Product, error) {
+	if err := validateGTIN(gtin); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(gtinIndex, []string{gtin})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state by partial composite key: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var products []*Product
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over query results: %v", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+
+		product, err := s.ReadProduct(ctx, keyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+
+	return products, nil
+}
+
+// ParseGS1Identifier splits a product ID into its GTIN and serial number, validating the check digit
+func (s *SmartContract) ParseGS1Identifier(ctx contractapi.TransactionContextInterface, id string) (*GS1Identifier, error) {
+	return parseGS1Identifier(id)
+}
+
+func identifierMode(ctx contractapi.TransactionContextInterface) (string, error) {
+	var mode string
+	found, err := readConfig(ctx, identifierModeConfig, &mode)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return IdentifierModeAny, nil
+	}
+	return mode, nil
+}
+
+// canonicalProductID checks a new product ID against the configured identifier mode and returns the ID the
+// product is stored under. In GS1 mode that is the element string, so that a bare GTIN-14 and "(01)GTIN" name
+// the same product.
+func canonicalProductID(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	if id == "" {
+		return "", errValidation("the product ID must not be empty")
+	}
+
+	mode, err := identifierMode(ctx)
+	if err != nil {
+		return "", err
+	}
+	if mode == IdentifierModeGS1 {
+		identifier, err := parseGS1Identifier(id)
+		if err != nil {
+			return "", err
+		}
+		return identifier.elementString(), nil
+	}
+
+	return id, nil
+}
+
+// generateProductID derives a product ID from the transaction ID, so that every endorser derives the same ID
+// and no two transactions derive the same one. In GS1 mode prefix must be a GTIN-14 and the ID is an SGTIN
+// with a generated serial; otherwise the optional prefix is followed by the generated serial.
+func generateProductID(ctx contractapi.TransactionContextInterface, prefix string) (string, error) {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", ctx.GetStub().GetTxID(), transactionSequence(ctx, "productID"))))
+	serial := hex.EncodeToString(hash[:])[:maxSerialLength]
+
+	mode, err := identifierMode(ctx)
+	if err != nil {
+		return "", err
+	}
+	if mode == IdentifierModeGS1 {
+		if err := validateGTIN(prefix); err != nil {
+			return "", errValidation("in %s mode the ID prefix must be a GTIN-14: %v", IdentifierModeGS1, errorMessage(err))
+		}
+		return (&GS1Identifier{GTIN: prefix, Serial: serial}).elementString(), nil
+	}
+
+	return prefix + serial, nil
+}
+
+// indexProductGTIN records a GS1 product under its GTIN so that every serial of the GTIN can be listed.
+// IDs that are not GS1 identifiers are left unindexed.
+func indexProductGTIN(ctx contractapi.TransactionContextInterface, id string) error {
+	identifier, err := parseGS1Identifier(id)
+	if err != nil {
+		return nil
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(gtinIndex, []string{identifier.GTIN, id})
+	if err != nil {
+		return fmt.Errorf("failed to create GTIN index key: %v", err)
+	}
+
+	// Only the key is needed, the value is a placeholder as in the Fabric marbles index pattern
+	return ctx.GetStub().PutState(indexKey, []byte{0x00})
+}
+
+// parseGS1Identifier accepts either a bare GTIN-14 or an SGTIN written as the GS1 element string
+// "(01)<GTIN-14>(21)<serial>", as printed under GS1-128 and DataMatrix barcodes
+func parseGS1Identifier(id string) (*GS1Identifier, error) {
+	if !strings.HasPrefix(id, "(01)") {
+		if err := validateGTIN(id); err != nil {
+			return nil, errValidation("the product ID %s is not a GTIN-14 or (01)GTIN(21)serial identifier: %v", id, errorMessage(err))
+		}
+		return &GS1Identifier{GTIN: id}, nil
+	}
+
+	rest := strings.TrimPrefix(id, "(01)")
+	if len(rest) < 14 {
+		return nil, errValidation("the product ID %s has a truncated GTIN", id)
+	}
+	gtin, rest := rest[:14], rest[14:]
+	if err := validateGTIN(gtin); err != nil {
+		return nil, errValidation("the product ID %s has an invalid GTIN: %v", id, errorMessage(err))
+	}
+	if rest == "" {
+		return &GS1Identifier{GTIN: gtin}, nil
+	}
+	if !strings.HasPrefix(rest, "(21)") {
+		return nil, errValidation("the product ID %s must continue with (21) and a serial number after the GTIN", id)
+	}
+	serial := strings.TrimPrefix(rest, "(21)")
+	if err := validateSerial(serial); err != nil {
+		return nil, errValidation("the product ID %s has an invalid serial number: %v", id, errorMessage(err))
+	}
+
+	return &GS1Identifier{GTIN: gtin, Serial: serial}, nil
+}
+
+// validateGTIN checks that gtin is fourteen digits ending in a correct GS1 mod-10 check digit
+func validateGTIN(gtin string) error {
+	if len(gtin) != 14 {
+		return errValidation("a GTIN-14 must have 14 digits, got %d characters", len(gtin))
+	}
+	sum := 0
+	for i := 0; i < 13; i++ {
+		digit := gtin[i]
+		if digit < '0' || digit > '9' {
+			return errValidation("a GTIN-14 may only contain digits, found %q", digit)
+		}
+		// Weights alternate 3, 1, 3, ... starting from the digit next to the check digit
+		weight := 1
+		if i%2 == 0 {
+			weight = 3
+		}
+		sum += int(digit-'0') * weight
+	}
+	if gtin[13] < '0' || gtin[13] > '9' {
+		return errValidation("a GTIN-14 may only contain digits, found %q", gtin[13])
+	}
+	checkDigit := (10 - sum%10) % 10
+	if int(gtin[13]-'0') != checkDigit {
+		return errValidation("check digit of GTIN %s should be %d", gtin, checkDigit)
+	}
+	return nil
+}
+
+// validateSerial checks an AI (21) serial number: 1 to 20 characters from GS1 character set 82
+func validateSerial(serial string) error {
+	if serial == "" || len(serial) > maxSerialLength {
+		return errValidation("a serial number must have between 1 and %d characters", maxSerialLength)
+	}
+	for _, r := range serial {
+		if r > 0x7e || !strings.ContainsRune(gs1CharacterSet82, r) {
+			return errValidation("character %q is not allowed in a GS1 serial number", r)
+		}
+	}
+	return nil
+}
+
+const gs1CharacterSet82 = "!\"%&'()*+,-./0123456789:;<=>?ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz"
+
+// elementString writes an identifier as the GS1 element string "(01)<GTIN-14>" or "(01)<GTIN-14>(21)<serial>"
+func (identifier *GS1Identifier) elementString() string {
+	if identifier.Serial == "" {
+		return "(01)" + identifier.GTIN
+	}
+	return fmt.Sprintf("(01)%s(21)%s", identifier.GTIN, identifier.Serial)
+}
+
+// gs1DigitalLink returns the GS1 Digital Link URI of an identifier, the form EPCIS 2.0 uses for EPCs
+func gs1DigitalLink(identifier *GS1Identifier) string {
+	if identifier.Serial == "" {
+		return fmt.Sprintf("https://id.gs1.org/01/%s", identifier.GTIN)
+	}
+	return fmt.Sprintf("https://id.gs1.org/01/%s/21/%s", identifier.GTIN, url.PathEscape(identifier.Serial))
+}
@@ -0,0 +1,167 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateGTIN(t *testing.T) {
+	tests := []struct {
+		gtin    string
+		wantErr bool
+	}{
+		{gtin: "04006381333931"},
+		{gtin: "00036000291452"},
+		{gtin: "10614141000415"},
+		{gtin: "95012345678903"},
+		{gtin: "00000000000000"},
+		{gtin: "04006381333932", wantErr: true}, // wrong check digit
+		{gtin: "10614141000410", wantErr: true}, // wrong check digit
+		{gtin: "4006381333931", wantErr: true},  // GTIN-13 without padding
+		{gtin: "040063813339310", wantErr: true},
+		{gtin: "0400638133393A", wantErr: true},
+		{gtin: "04006381A33931", wantErr: true},
+		{gtin: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.gtin, func(t *testing.T) {
+			err := validateGTIN(tt.gtin)
+			if tt.wantErr {
+				RequireContractError(t, err, CodeValidationFailed)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestParseGS1Identifier(t *testing.T) {
+	tests := []struct {
+		id      string
+		want    *GS1Identifier
+		wantErr bool
+	}{
+		{id: "10614141000415", want: &GS1Identifier{GTIN: "10614141000415"}},
+		{id: "(01)10614141000415", want: &GS1Identifier{GTIN: "10614141000415"}},
+		{id: "(01)10614141000415(21)ABC-123", want: &GS1Identifier{GTIN: "10614141000415", Serial: "ABC-123"}},
+		{id: "(01)10614141000415(21)12345678901234567890", want: &GS1Identifier{GTIN: "10614141000415", Serial: "12345678901234567890"}},
+		{id: "(01)10614141000410(21)ABC", wantErr: true},                   // wrong check digit
+		{id: "(01)1061414100041", wantErr: true},                           // truncated GTIN
+		{id: "(01)10614141000415(10)LOT1", wantErr: true},                  // batch instead of serial
+		{id: "(01)10614141000415(21)", wantErr: true},                      // empty serial
+		{id: "(01)10614141000415(21)123456789012345678901", wantErr: true}, // serial too long
+		{id: "(01)10614141000415(21)AB C", wantErr: true},                  // space outside set 82
+		{id: "(01)10614141000415(21)AB#", wantErr: true},                   // # outside set 82
+		{id: "apple", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			got, err := parseGS1Identifier(tt.id)
+			if tt.wantErr {
+				RequireContractError(t, err, CodeValidationFailed)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGS1DigitalLink(t *testing.T) {
+	require.Equal(t, "https://id.gs1.org/01/10614141000415", gs1DigitalLink(&GS1Identifier{GTIN: "10614141000415"}))
+	require.Equal(t, "https://id.gs1.org/01/10614141000415/21/A%2FB", gs1DigitalLink(&GS1Identifier{GTIN: "10614141000415", Serial: "A/B"}))
+}
+
+func TestGenerateProductIDInGS1Mode(t *testing.T) {
+	stub := newLedgerStub()
+	ctx := newClientContext(stub, "Org1MSP", "maker")
+	stub.begin("mode")
+	require.NoError(t, (&SmartContract{}).SetIdentifierMode(ctx, IdentifierModeGS1))
+	stub.commit()
+
+	stub.begin("generate")
+	id, err := generateProductID(ctx, "10614141000415")
+	require.NoError(t, err)
+	identifier, err := parseGS1Identifier(id)
+	require.NoError(t, err)
+	require.Equal(t, "10614141000415", identifier.GTIN)
+	require.Len(t, identifier.Serial, maxSerialLength)
+
+	_, err = generateProductID(ctx, "10614141000410")
+	RequireContractError(t, err, CodeValidationFailed)
+}
+
+func TestCreateProductNormalisesGS1Identifiers(t *testing.T) {
+	stub := newLedgerStub()
+	ctx := newClientContext(stub, "Org1MSP", "maker")
+	contract := &SmartContract{}
+	stub.begin("mode")
+	require.NoError(t, contract.SetIdentifierMode(ctx, IdentifierModeGS1))
+	stub.commit()
+
+	stub.begin("create")
+	require.NoError(t, contract.CreateProduct(ctx, "10614141000415", "apple", "good", "1.00", "maker", ""))
+	stub.commit()
+	product, err := contract.ReadProduct(ctx, "(01)10614141000415")
+	require.NoError(t, err)
+	require.Equal(t, "(01)10614141000415", product.ID)
+
+	// The element string of the same trade item is the same product
+	stub.begin("create-again")
+	err = contract.CreateProduct(ctx, "(01)10614141000415", "apple", "good", "1.00", "maker", "")
+	RequireContractError(t, err, CodeAlreadyExists)
+
+	products, err := contract.GetProductsByGTIN(ctx, "10614141000415")
+	require.NoError(t, err)
+	require.Len(t, products, 1)
+}
+
+func TestGeneratedIDsAreDeterministicAndUniqueWithinATransaction(t *testing.T) {
+	stub := newLedgerStub()
+	generate := func(txID string) []string {
+		stub.begin(txID)
+		ctx := newHookedContext(stub, "Org1MSP", "maker")
+		require.NoError(t, ctx.load())
+		var ids []string
+		for i := 0; i < 3; i++ {
+			id, err := generateProductID(ctx, "SKU-")
+			require.NoError(t, err)
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	ids := generate("tx1")
+	require.Len(t, ids, 3)
+	require.NotEqual(t, ids[0], ids[1])
+	require.NotEqual(t, ids[1], ids[2])
+	require.NotEqual(t, ids[0], ids[2])
+	for _, id := range ids {
+		require.Regexp(t, `^SKU-[0-9a-f]{20}$`, id)
+	}
+
+	// Every endorser of the transaction derives the same IDs, another transaction derives others
+	require.Equal(t, ids, generate("tx1"))
+	require.NotContains(t, generate("tx2"), ids[0])
+}
+
+func TestBatchDerivesUniqueDocumentNumbers(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.submit(t, "create-p2", func() error {
+		return f.contract.CreateProduct(f.manufacturer, "p2", "pear", "good", "5.00", "maker", "2024-01-01T00:00:00Z")
+	})
+	f.order(t, "order-p1")
+	f.submit(t, "order-p2", func() error { return f.contract.ProductOrder(f.consumer, "p2", "bob", "x") })
+
+	require.NoError(t, f.tryHooked(t, "accept-both", "Org1MSP", manufacturerAccount, func(ctx *TransactionContext) error {
+		return f.contract.ProductAcceptBatch(ctx, []string{"p1", "p2"}, "maker", "x")
+	}))
+
+	p2, err := f.contract.ReadProduct(f.manufacturer, "p2")
+	require.NoError(t, err)
+	require.NotEmpty(t, p2.InvoiceNumber)
+	require.NotEqual(t, f.product(t).InvoiceNumber, p2.InvoiceNumber)
+}
@@ -0,0 +1,30 @@
+package chaincode
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Helpers shared by the tests of package chaincode and of the external chaincode_test package
+
+// ClientIdentity is a client of an org with a fixed ID
+type ClientIdentity struct {
+	MSPID string
+	ID    string
+}
+
+func (c *ClientIdentity) GetID() (string, error)                         { return c.ID, nil }
+func (c *ClientIdentity) GetMSPID() (string, error)                      { return c.MSPID, nil }
+func (c *ClientIdentity) GetAttributeValue(string) (string, bool, error) { return "", false, nil }
+func (c *ClientIdentity) AssertAttributeValue(string, string) error      { return nil }
+func (c *ClientIdentity) GetX509Certificate() (*x509.Certificate, error) { return nil, nil }
+
+// RequireContractError asserts that err is a ContractError with code
+func RequireContractError(t *testing.T, err error, code string) {
+	t.Helper()
+	var contractErr *ContractError
+	require.ErrorAs(t, err, &contractErr)
+	require.Equal(t, code, contractErr.Code, contractErr.Message)
+}
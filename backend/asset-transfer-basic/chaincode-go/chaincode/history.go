@@ -0,0 +1,336 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ProductHistoryEntry describes one write to a product key as recorded by the ledger history
+type ProductHistoryEntry struct {
+	TxID          string        `json:"TxID"`
+	Timestamp     string        `json:"Timestamp"` // timestamp the client put in the transaction proposal, RFC 3339 in UTC
+	IsDelete      bool          `json:"IsDelete"`
+	ModifiedBy    string        `json:"ModifiedBy"`
+	ModifiedByOrg string        `json:"ModifiedByOrg"`
+	Product       *Product      `json:"Product,omitempty" metadata:",optional"` // nil for delete markers
+	Changes       []FieldChange `json:"Changes"`
+}
+
+// FieldChange describes a single product field that differs from the previous version
+type FieldChange struct {
+	Field    string `json:"Field"`
+	Previous string `json:"Previous"`
+	Current  string `json:"Current"`
+}
+
+// ProductHistoryPage is one page of a filtered product history query
+type ProductHistoryPage struct {
+	Entries             []*ProductHistoryEntry `json:"Entries"`
+	FetchedRecordsCount int32                  `json:"FetchedRecordsCount"`
+	Bookmark            string                 `json:"Bookmark"` // pass back to fetch the next page, empty when there is none
+}
+
+// maxHistoryPageSize bounds the number of entries returned by a single history page
+const maxHistoryPageSize = 100
+
+// diffIgnoredFields are bookkeeping fields that change on every write and are reported on the entry itself
+var diffIgnoredFields = map[string]bool{
+	"ModifiedBy":    true,
+	"ModifiedByOrg": true,
+}
+
+// historyReader walks the history of a product key newest first, as the ledger returns it in commit order.
+// It reads one version ahead so that every entry can be diffed against the version committed before it.
+// Timestamps are chosen by the clients and may be skewed, so they are not used for ordering.
+type historyReader struct {
+	results shim.HistoryQueryIteratorInterface
+	older   *ProductHistoryEntry
+}
+
+func newHistoryReader(ctx contractapi.TransactionContextInterface, id string) (*historyReader, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read product history: %v", err)
+	}
+	return &historyReader{results: resultsIterator}, nil
+}
+
+func (r *historyReader) Close() error {
+	return r.results.Close()
+}
+
+// next returns the next older entry with its changes, or nil once the creation of the product was read
+func (r *historyReader) next() (*ProductHistoryEntry, error) {
+	entry := r.older
+	if entry == nil {
+		var err error
+		if entry, err = r.read(); err != nil || entry == nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if r.older, err = r.read(); err != nil {
+		return nil, err
+	}
+	var previous *Product
+	if r.older != nil {
+		previous = r.older.Product
+	}
+	if entry.Changes, err = diffProducts(previous, entry.Product); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// read decodes the next result of the iterator without its changes
+func (r *historyReader) read() (*ProductHistoryEntry, error) {
+	if !r.results.HasNext() {
+		return nil, nil
+	}
+	response, err := r.results.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to iterate history results: %v", err)
+	}
+
+	var timestamp time.Time
+	if response.Timestamp != nil {
+		timestamp = response.Timestamp.AsTime().UTC()
+	}
+
+	entry := &ProductHistoryEntry{
+		TxID:      response.TxId,
+		Timestamp: timestamp.Format(time.RFC3339Nano),
+		IsDelete:  response.IsDelete,
+		Changes:   []FieldChange{},
+	}
+
+	// Delete markers carry no value, so there is no product to unmarshal
+	if !response.IsDelete && len(response.Value) > 0 {
+		var product Product
+		if err := json.Unmarshal(response.Value, &product); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal product JSON: %v", err)
+		}
+		entry.Product = &product
+		entry.ModifiedBy = product.ModifiedBy
+		entry.ModifiedByOrg = product.ModifiedByOrg
+	}
+
+	return entry, nil
+}
+
+// readProductHistory reads the full history of a product key, oldest first
+func readProductHistory(ctx contractapi.TransactionContextInterface, id string) ([]*ProductHistoryEntry, error) {
+	reader, err := newHistoryReader(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var history []*ProductHistoryEntry
+	for {
+		entry, err := reader.next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		history = append(history, entry)
+	}
+	reverseHistory(history)
+
+	return history, nil
+}
+
+// GetProductHistoryPage returns the history of a product between fromTime and toTime (RFC 3339, either
+// may be empty for an open range), at most pageSize entries at a time, up to 100. The bookmark returned with
+// a page continues the same query when passed back with identical filters.
+//
+// The ledger returns the history newest first, so newest first pages stop reading once they are full, while
+// oldest first pages read the versions committed after the bookmark, or all of them for the first page.
+func (s *SmartContract) GetProductHistoryPage(ctx contractapi.TransactionContextInterface, id string, fromTime string, toTime string, pageSize int32, bookmark string, newestFirst bool) (*ProductHistoryPage, error) {
+	from, err := parseOptionalTime(fromTime)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseOptionalTime(toTime)
+	if err != nil {
+		return nil, err
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return nil, errValidation("toTime %s is before fromTime %s", toTime, fromTime)
+	}
+	if pageSize <= 0 {
+		return nil, errValidation("the page size must be positive, got %d", pageSize)
+	}
+	if pageSize > maxHistoryPageSize {
+		pageSize = maxHistoryPageSize
+	}
+
+	reader, err := newHistoryReader(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	inRange := func(entry *ProductHistoryEntry) (bool, error) {
+		timestamp, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse history timestamp %s: %v", entry.Timestamp, err)
+		}
+		return (from.IsZero() || !timestamp.Before(from)) && (to.IsZero() || !timestamp.After(to)), nil
+	}
+
+	// The bookmark is the transaction ID of the last entry of the previous page. Newest first, the page
+	// follows it in the history; oldest first, it is made of the entries read before it. One entry more
+	// than the page is kept to tell whether another page follows.
+	var window []*ProductHistoryEntry
+	foundBookmark := bookmark == ""
+	for {
+		entry, err := reader.next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+
+		if entry.TxID == bookmark {
+			foundBookmark = true
+			if newestFirst {
+				continue
+			}
+			break
+		}
+		if newestFirst && !foundBookmark {
+			continue
+		}
+		matches, err := inRange(entry)
+		if err != nil {
+			return nil, err
+		}
+		if !matches {
+			continue
+		}
+
+		window = append(window, entry)
+		if newestFirst && len(window) > int(pageSize) {
+			break
+		}
+		// Oldest first, only the entries closest to the bookmark, or to the creation, are kept
+		if len(window) > int(pageSize)+1 {
+			window = window[1:]
+		}
+	}
+	if !foundBookmark {
+		return nil, errValidation("the bookmark %s does not match the history of product %s", bookmark, id)
+	}
+
+	page := &ProductHistoryPage{}
+	if !newestFirst {
+		reverseHistory(window)
+	}
+	if len(window) > int(pageSize) {
+		window = window[:pageSize]
+		page.Bookmark = window[pageSize-1].TxID
+	}
+	page.Entries = append([]*ProductHistoryEntry{}, window...)
+	page.FetchedRecordsCount = int32(len(window))
+
+	return page, nil
+}
+
+// reverseHistory reverses history entries in place
+func reverseHistory(history []*ProductHistoryEntry) {
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+}
+
+func parseOptionalTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errValidation("invalid time %q, expected RFC 3339: %v", value, err)
+	}
+	return parsed, nil
+}
+
+// diffProducts lists the fields whose values differ between two versions of a product. A nil version
+// (before creation or after deletion) is treated as having every field empty.
+func diffProducts(previous *Product, current *Product) ([]FieldChange, error) {
+	previousFields, err := productFields(previous)
+	if err != nil {
+		return nil, err
+	}
+	currentFields, err := productFields(current)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for name := range previousFields {
+		names[name] = true
+	}
+	for name := range currentFields {
+		names[name] = true
+	}
+	sortedNames := make([]string, 0, len(names))
+	for name := range names {
+		if !diffIgnoredFields[name] {
+			sortedNames = append(sortedNames, name)
+		}
+	}
+	sort.Strings(sortedNames)
+
+	changes := []FieldChange{}
+	for _, name := range sortedNames {
+		if bytes.Equal(previousFields[name], currentFields[name]) {
+			continue
+		}
+		changes = append(changes, FieldChange{
+			Field:    name,
+			Previous: fieldValue(previousFields[name]),
+			Current:  fieldValue(currentFields[name]),
+		})
+	}
+
+	return changes, nil
+}
+
+func productFields(product *Product) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if product == nil {
+		return fields, nil
+	}
+	productJSON, err := json.Marshal(product)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal product JSON: %v", err)
+	}
+	if err := json.Unmarshal(productJSON, &fields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal product JSON: %v", err)
+	}
+	return fields, nil
+}
+
+// fieldValue renders a raw JSON value for display, unquoting plain strings
+func fieldValue(raw json.RawMessage) string {
+	if raw == nil {
+		return ""
+	}
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		return str
+	}
+	return string(raw)
+}
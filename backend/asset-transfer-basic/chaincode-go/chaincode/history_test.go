@@ -0,0 +1,166 @@
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+// newHistoryFixture creates p1 and renames it twice. The client of the first rename has a clock a day
+// behind, so its timestamp is older than the one of the creation.
+func newHistoryFixture(t *testing.T) (*ledgerStub, *SmartContract, *mocks.TransactionContext) {
+	stub := newLedgerStub()
+	ctx := newClientContext(stub, "Org1MSP", "maker")
+	contract := &SmartContract{}
+
+	stub.begin("create")
+	require.NoError(t, contract.CreateProduct(ctx, "p1", "apple", "good", "1.00", "maker", ""))
+	stub.commit()
+
+	stub.begin("rename-skewed")
+	stub.txTime = stub.txTime.Add(-24 * time.Hour)
+	require.NoError(t, contract.UpdateProduct(ctx, "p1", "pear", "good", "1.00", "maker", "x"))
+	stub.commit()
+	stub.txTime = stub.txTime.Add(24 * time.Hour)
+
+	stub.begin("rename")
+	require.NoError(t, contract.UpdateProduct(ctx, "p1", "plum", "good", "1.00", "maker", "x"))
+	stub.commit()
+
+	return stub, contract, ctx
+}
+
+func TestProductHistoryKeepsCommitOrder(t *testing.T) {
+	_, contract, ctx := newHistoryFixture(t)
+
+	history, err := contract.TrackProductHistory(ctx, "p1")
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	require.Equal(t, []string{"create", "rename-skewed", "rename"}, []string{history[0].TxID, history[1].TxID, history[2].TxID})
+	require.Equal(t, "2024-01-01T01:00:00Z", history[0].Timestamp)
+	require.Equal(t, "2023-12-31T02:00:00Z", history[1].Timestamp)
+
+	// Every version is diffed against the one committed before it, whatever its timestamp
+	require.Contains(t, history[1].Changes, FieldChange{Field: "Name", Previous: "apple", Current: "pear"})
+	require.Contains(t, history[2].Changes, FieldChange{Field: "Name", Previous: "pear", Current: "plum"})
+	require.Equal(t, "maker", history[0].ModifiedBy)
+}
+
+// newVersionedProduct creates p1 as version v0 and updates it to v5, an hour apart from 01:00 on
+func newVersionedProduct(t *testing.T) (*ledgerStub, *SmartContract, *mocks.TransactionContext) {
+	stub := newLedgerStub()
+	ctx := newClientContext(stub, "Org1MSP", "maker")
+	contract := &SmartContract{}
+
+	stub.begin("v0")
+	require.NoError(t, contract.CreateProduct(ctx, "p1", "v0", "good", "1.00", "maker", ""))
+	stub.commit()
+	for _, version := range []string{"v1", "v2", "v3", "v4", "v5"} {
+		stub.begin(version)
+		require.NoError(t, contract.UpdateProduct(ctx, "p1", version, "good", "1.00", "maker", "x"))
+		stub.commit()
+	}
+
+	return stub, contract, ctx
+}
+
+func TestProductHistoryPages(t *testing.T) {
+	tests := []struct {
+		name        string
+		fromTime    string
+		toTime      string
+		pageSize    int32
+		newestFirst bool
+		want        [][]string
+	}{
+		{name: "oldest first", pageSize: 4, want: [][]string{{"v0", "v1", "v2", "v3"}, {"v4", "v5"}}},
+		{name: "newest first", pageSize: 2, newestFirst: true, want: [][]string{{"v5", "v4"}, {"v3", "v2"}, {"v1", "v0"}}},
+		{name: "single page", pageSize: 6, want: [][]string{{"v0", "v1", "v2", "v3", "v4", "v5"}}},
+		{name: "page size above the maximum", pageSize: maxHistoryPageSize + 1, newestFirst: true, want: [][]string{{"v5", "v4", "v3", "v2", "v1", "v0"}}},
+		{
+			name:     "time range",
+			fromTime: "2024-01-01T03:00:00Z",
+			toTime:   "2024-01-01T05:00:00Z",
+			pageSize: 2,
+			want:     [][]string{{"v2", "v3"}, {"v4"}},
+		},
+		{
+			name:        "time range newest first",
+			fromTime:    "2024-01-01T03:00:00Z",
+			toTime:      "2024-01-01T05:00:00Z",
+			pageSize:    2,
+			newestFirst: true,
+			want:        [][]string{{"v4", "v3"}, {"v2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, contract, ctx := newVersionedProduct(t)
+
+			bookmark := ""
+			for i, want := range tt.want {
+				page, err := contract.GetProductHistoryPage(ctx, "p1", tt.fromTime, tt.toTime, tt.pageSize, bookmark, tt.newestFirst)
+				require.NoError(t, err)
+
+				var got []string
+				for _, entry := range page.Entries {
+					got = append(got, entry.TxID)
+					require.Equal(t, entry.TxID, entry.Product.Name)
+				}
+				require.Equal(t, want, got, "page %d", i)
+				require.Equal(t, int32(len(want)), page.FetchedRecordsCount)
+
+				bookmark = page.Bookmark
+				if i == len(tt.want)-1 {
+					require.Empty(t, bookmark)
+				} else {
+					require.Equal(t, want[len(want)-1], bookmark)
+				}
+			}
+		})
+	}
+}
+
+func TestProductHistoryPageDiffsAgainstPreviousVersion(t *testing.T) {
+	_, contract, ctx := newVersionedProduct(t)
+
+	page, err := contract.GetProductHistoryPage(ctx, "p1", "", "", 1, "v3", false)
+	require.NoError(t, err)
+	require.Equal(t, "v4", page.Entries[0].TxID)
+	require.Contains(t, page.Entries[0].Changes, FieldChange{Field: "Name", Previous: "v3", Current: "v4"})
+
+	page, err = contract.GetProductHistoryPage(ctx, "p1", "", "", 1, "v3", true)
+	require.NoError(t, err)
+	require.Equal(t, "v2", page.Entries[0].TxID)
+	require.Contains(t, page.Entries[0].Changes, FieldChange{Field: "Name", Previous: "v1", Current: "v2"})
+}
+
+func TestProductHistoryPageStopsReadingWhenFull(t *testing.T) {
+	stub, contract, ctx := newVersionedProduct(t)
+
+	page, err := contract.GetProductHistoryPage(ctx, "p1", "", "", 2, "", true)
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 2)
+	// The page, one entry to tell that another page follows and the version that entry is diffed against
+	require.Equal(t, 4, stub.historyRead)
+}
+
+func TestProductHistoryPageRefusesInvalidQueries(t *testing.T) {
+	_, contract, ctx := newVersionedProduct(t)
+
+	for _, pageSize := range []int32{0, -1} {
+		_, err := contract.GetProductHistoryPage(ctx, "p1", "", "", pageSize, "", false)
+		RequireContractError(t, err, CodeValidationFailed)
+	}
+
+	_, err := contract.GetProductHistoryPage(ctx, "p1", "", "", 2, "unknown", false)
+	RequireContractError(t, err, CodeValidationFailed)
+	_, err = contract.GetProductHistoryPage(ctx, "p1", "", "", 2, "unknown", true)
+	RequireContractError(t, err, CodeValidationFailed)
+
+	_, err = contract.GetProductHistoryPage(ctx, "p1", "2024-01-02T00:00:00Z", "2024-01-01T00:00:00Z", 2, "", false)
+	RequireContractError(t, err, CodeValidationFailed)
+}
@@ -0,0 +1,274 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	invoiceObjectType  = "invoice"
+	invoiceTermsConfig = "invoiceTerms"
+	invoiceDateFormat  = "2006-01-02"
+
+	InvoiceUnpaid = "Unpaid"
+	InvoicePaid   = "Paid"
+
+	defaultPaymentTermsDays = 30
+	maxTaxRateBasisPoints   = 10000
+)
+
+// Invoice bills the consumer for an accepted order. Invoices are kept in the trade collection so that both
+// the manufacturer and consumer orgs can read them, and the accounting system can use them as its ledger.
+type Invoice struct {
+	InvoiceNumber      string             `json:"InvoiceNumber"`
+	ProductID          string             `json:"ProductID"`
+	Seller             string             `json:"Seller"`
+	SellerOrg          string             `json:"SellerOrg"`
+	Buyer              string             `json:"Buyer"`
+	BuyerAccount       string             `json:"BuyerAccount"`
+	LineItems          []*InvoiceLineItem `json:"LineItems"`
+	Subtotal           string             `json:"Subtotal"`
+	TaxRateBasisPoints int                `json:"TaxRateBasisPoints"` // 1250 is a 12.5% tax rate
+	Tax                string             `json:"Tax"`
+	Total              string             `json:"Total"`
+	IssueDate          string             `json:"IssueDate"`
+	DueDate            string             `json:"DueDate"`
+	PaymentStatus      string             `json:"PaymentStatus"`
+	PaidDate           string             `json:"PaidDate"`
+	PaymentReference   string             `json:"PaymentReference"`
+	IssuedTxID         string             `json:"IssuedTxID"`
+	PaidTxID           string             `json:"PaidTxID"`
+}
+
+// InvoiceLineItem is one billed line of an invoice
+type InvoiceLineItem struct {
+	Description string `json:"Description"`
+	Quantity    int    `json:"Quantity"`
+	UnitPrice   string `json:"UnitPrice"`
+	Amount      string `json:"Amount"`
+}
+
+// InvoiceTerms are the tax rate and payment terms applied to new invoices
+type InvoiceTerms struct {
+	TaxRateBasisPoints int `json:"TaxRateBasisPoints"`
+	PaymentTermsDays   int `json:"PaymentTermsDays"`
+}
+
+// SetInvoiceTerms sets the tax rate, in basis points, and the number of days after acceptance an invoice is
+// due. Invoices already issued keep the terms they were issued with.
+func (s *SmartContract) SetInvoiceTerms(ctx contractapi.TransactionContextInterface, taxRateBasisPoints int, paymentTermsDays int) error {
+	if taxRateBasisPoints < 0 || taxRateBasisPoints > maxTaxRateBasisPoints {
+		return errValidation("the tax rate must be between 0 and %d basis points", maxTaxRateBasisPoints)
+	}
+	if paymentTermsDays < 0 {
+		return errValidation("the payment terms must not be negative")
+	}
+
+	return writeConfig(ctx, invoiceTermsConfig, &InvoiceTerms{
+		TaxRateBasisPoints: taxRateBasisPoints,
+		PaymentTermsDays:   paymentTermsDays,
+	})
+}
+
+// GetInvoiceTerms returns the tax rate and payment terms applied to new invoices
+func (s *SmartContract) GetInvoiceTerms(ctx contractapi.TransactionContextInterface) (*InvoiceTerms, error) {
+	return invoiceTerms(ctx)
+}
+
+// ReadInvoice returns an invoice by its number
+func (s *SmartContract) ReadInvoice(ctx contractapi.TransactionContextInterface, invoiceNumber string) (*Invoice, error) {
+	invoice, err := readInvoice(ctx, invoiceNumber)
+	if err != nil {
+		return nil, err
+	}
+	if invoice == nil {
+		return nil, errNotFound("invoice", invoiceNumber)
+	}
+	return invoice, nil
+}
+
+// GetAllInvoices returns every invoice in the trade collection
+func (s *SmartContract) GetAllInvoices(ctx contractapi.TransactionContextInterface) ([]*Invoice, error) {
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(tradeCollection, invoiceObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read invoices from %s: %v", tradeCollection, err)
+	}
+	defer resultsIterator.Close()
+
+	var invoices []*Invoice
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over query results: %v", err)
+		}
+
+		var invoice Invoice
+		if err := json.Unmarshal(queryResponse.Value, &invoice); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal invoice JSON: %v", err)
+		}
+		invoices = append(invoices, &invoice)
+	}
+
+	return invoices, nil
+}
+
+// MarkInvoicePaid records that the seller received payment for an invoice
+func (s *SmartContract) MarkInvoicePaid(ctx contractapi.TransactionContextInterface, invoiceNumber string, paymentReference string) error {
+	// Check the invoking client's organization
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+
+	invoice, err := s.ReadInvoice(ctx, invoiceNumber)
+	if err != nil {
+		return err
+	}
+
+	// Only the seller can confirm it has been paid
+	if clientOrg != invoice.SellerOrg {
+		return errForbidden("Access denied: Only the seller org can mark an invoice paid")
+	}
+	if invoice.PaymentStatus == InvoicePaid {
+		return errInvalidState("the invoice %s is already paid", invoiceNumber)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	invoice.PaymentStatus = InvoicePaid
+	invoice.PaidDate = now.Format(time.RFC3339)
+	invoice.PaymentReference = paymentReference
+	invoice.PaidTxID = ctx.GetStub().GetTxID()
+
+	return putInvoice(ctx, invoice)
+}
+
+// issueInvoice bills the consumer for an order being accepted by the invoking manufacturer and records the
+// invoice number on the product
+func issueInvoice(ctx contractapi.TransactionContextInterface, product *Product) error {
+	price, err := productPrice(ctx, product)
+	if err != nil {
+		return err
+	}
+	unitPrice, err := parseAmount(price)
+	if err != nil {
+		return errInvalidState("the price of product %s cannot be invoiced: %v", product.ID, errorMessage(err))
+	}
+	terms, err := invoiceTerms(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+
+	const quantity = 1
+	subtotal := unitPrice * quantity
+	// Round half up to the nearest minor unit
+	tax := (subtotal*int64(terms.TaxRateBasisPoints) + 5000) / 10000
+
+	txID := ctx.GetStub().GetTxID()
+	invoiceNumber := documentNumber(ctx, "INV", now)
+
+	invoice := &Invoice{
+		InvoiceNumber: invoiceNumber,
+		ProductID:     product.ID,
+		Seller:        product.Manufacturer,
+		SellerOrg:     clientOrg,
+		Buyer:         product.Consumer,
+		BuyerAccount:  product.ConsumerAccount,
+		LineItems: []*InvoiceLineItem{{
+			Description: product.Name,
+			Quantity:    quantity,
+			UnitPrice:   formatAmount(unitPrice),
+			Amount:      formatAmount(subtotal),
+		}},
+		Subtotal:           formatAmount(subtotal),
+		TaxRateBasisPoints: terms.TaxRateBasisPoints,
+		Tax:                formatAmount(tax),
+		Total:              formatAmount(subtotal + tax),
+		IssueDate:          now.Format(invoiceDateFormat),
+		DueDate:            now.AddDate(0, 0, terms.PaymentTermsDays).Format(invoiceDateFormat),
+		PaymentStatus:      InvoiceUnpaid,
+		IssuedTxID:         txID,
+	}
+	if err := putInvoice(ctx, invoice); err != nil {
+		return err
+	}
+	if err := indexInvoice(ctx, invoice, product); err != nil {
+		return err
+	}
+
+	product.InvoiceNumber = invoiceNumber
+	return nil
+}
+
+// documentNumber numbers an invoice, credit note or shipment after the transaction issuing it, so that
+// numbers are unique without a shared counter. When a transaction issues several documents of a kind, as
+// batches do, the second and later ones get a sequence suffix.
+func documentNumber(ctx contractapi.TransactionContextInterface, prefix string, issued time.Time) string {
+	txID := ctx.GetStub().GetTxID()
+	if len(txID) > 12 {
+		txID = txID[:12]
+	}
+	number := fmt.Sprintf("%s-%s-%s", prefix, issued.Format("20060102"), txID)
+
+	if sequence := transactionSequence(ctx, prefix); sequence > 1 {
+		number = fmt.Sprintf("%s-%d", number, sequence)
+	}
+	return number
+}
+
+func invoiceTerms(ctx contractapi.TransactionContextInterface) (*InvoiceTerms, error) {
+	terms := &InvoiceTerms{PaymentTermsDays: defaultPaymentTermsDays}
+	if _, err := readConfig(ctx, invoiceTermsConfig, terms); err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
+func readInvoice(ctx contractapi.TransactionContextInterface, invoiceNumber string) (*Invoice, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(invoiceObjectType, []string{invoiceNumber})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invoice key: %v", err)
+	}
+	invoiceJSON, err := ctx.GetStub().GetPrivateData(tradeCollection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read invoice from %s: %v", tradeCollection, err)
+	}
+	if invoiceJSON == nil {
+		return nil, nil
+	}
+
+	var invoice Invoice
+	if err := json.Unmarshal(invoiceJSON, &invoice); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal invoice JSON: %v", err)
+	}
+	return &invoice, nil
+}
+
+func putInvoice(ctx contractapi.TransactionContextInterface, invoice *Invoice) error {
+	key, err := ctx.GetStub().CreateCompositeKey(invoiceObjectType, []string{invoice.InvoiceNumber})
+	if err != nil {
+		return fmt.Errorf("failed to create invoice key: %v", err)
+	}
+	invoiceJSON, err := json.Marshal(invoice)
+	if err != nil {
+		return fmt.Errorf("failed to marshal invoice JSON: %v", err)
+	}
+	if err := ctx.GetStub().PutPrivateData(tradeCollection, key, invoiceJSON); err != nil {
+		return fmt.Errorf("failed to put invoice to %s: %v", tradeCollection, err)
+	}
+	return nil
+}
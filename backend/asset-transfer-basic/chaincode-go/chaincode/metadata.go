@@ -0,0 +1,98 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// getMetadataFunction is the system transaction clients read the contract metadata with
+const getMetadataFunction = contractapi.SystemContractName + ":GetMetadata"
+
+// Chaincode is the contractapi chaincode of this package with parameterRules published in its metadata.
+// contractapi derives parameter schemas from the Go types only, so the metadata returned by
+// org.hyperledger.fabric:GetMetadata is extended with the name, length, pattern and format of every string
+// parameter that has a rule.
+type Chaincode struct {
+	*contractapi.ContractChaincode
+}
+
+// NewChaincode creates the chaincode from its contracts, the first of which is the default contract
+func NewChaincode(contracts ...contractapi.ContractInterface) (*Chaincode, error) {
+	cc, err := contractapi.NewChaincode(contracts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Chaincode{ContractChaincode: cc}, nil
+}
+
+// Start starts the chaincode in the peer
+func (cc *Chaincode) Start() error {
+	return shim.Start(cc)
+}
+
+// Invoke runs a transaction, adding parameterRules to the metadata when it is requested
+func (cc *Chaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	response := cc.ContractChaincode.Invoke(stub)
+
+	function, _ := stub.GetFunctionAndParameters()
+	if function != getMetadataFunction || response.Status != shim.OK {
+		return response
+	}
+
+	metadataJSON, err := withParameterRules(response.Payload)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(metadataJSON)
+}
+
+// withParameterRules adds the rules of every transaction to the schemas of its parameters
+func withParameterRules(metadataJSON []byte) ([]byte, error) {
+	var chaincodeMetadata metadata.ContractChaincodeMetadata
+	if err := json.Unmarshal(metadataJSON, &chaincodeMetadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal contract metadata JSON: %v", err)
+	}
+
+	for contractName, contract := range chaincodeMetadata.Contracts {
+		for _, transaction := range contract.Transactions {
+			function := transaction.Name
+			if contractName != defaultContractName {
+				function = contractName + ":" + function
+			}
+			rules, ok := parameterRules[transactionName(function)]
+			if !ok || len(rules) != len(transaction.Parameters) {
+				continue
+			}
+			for i := range transaction.Parameters {
+				rules[i].apply(&transaction.Parameters[i])
+			}
+		}
+	}
+
+	return json.Marshal(chaincodeMetadata)
+}
+
+// apply names a parameter after its rule and adds the rule to the parameter's JSON schema
+func (rule ParameterRule) apply(parameter *metadata.ParameterMetadata) {
+	parameter.Name = rule.Name
+	schema := parameter.Schema
+	if schema == nil || !schema.Type.Contains("string") {
+		return
+	}
+
+	if rule.Required {
+		minLength := int64(1)
+		schema.MinLength = &minLength
+	}
+	if rule.MaxLength > 0 {
+		maxLength := int64(rule.MaxLength)
+		schema.MaxLength = &maxLength
+	}
+	schema.Pattern = rule.Pattern
+	schema.Format = rule.Format
+}
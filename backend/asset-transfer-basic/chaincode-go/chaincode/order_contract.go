@@ -0,0 +1,167 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// OrderContract groups the order lifecycle transactions, from placing an order to rating it, under the
+// "order:" prefix
+type OrderContract struct {
+	contractapi.Contract
+	core SmartContract
+}
+
+// NewOrderContract returns the order contract, invoked with the "order:" prefix
+func NewOrderContract() *OrderContract {
+	return &OrderContract{Contract: newContract("order")}
+}
+
+// Place orders a product for a consumer
+func (c *OrderContract) Place(ctx contractapi.TransactionContextInterface, id string, newOwner string, modifieddate string) error {
+	return c.core.ProductOrder(ctx, id, newOwner, modifieddate)
+}
+
+// Accept accepts a pending order with the default service levels
+func (c *OrderContract) Accept(ctx contractapi.TransactionContextInterface, id string, manufacturer string, modifieddate string) error {
+	return c.core.ProductAccept(ctx, id, manufacturer, modifieddate)
+}
+
+// AcceptBatch accepts the pending orders of every listed product or none of them
+func (c *OrderContract) AcceptBatch(ctx contractapi.TransactionContextInterface, ids []string, manufacturer string, modifieddate string) error {
+	return c.core.ProductAcceptBatch(ctx, ids, manufacturer, modifieddate)
+}
+
+// AcceptWithSLA accepts a pending order promising explicit ship-by and deliver-by dates
+func (c *OrderContract) AcceptWithSLA(ctx contractapi.TransactionContextInterface, id string, manufacturer string, modifieddate string, shipByDate string, deliverByDate string) error {
+	return c.core.ProductAcceptWithSLA(ctx, id, manufacturer, modifieddate, shipByDate, deliverByDate)
+}
+
+// Reject turns down an order that has not been shipped yet
+func (c *OrderContract) Reject(ctx contractapi.TransactionContextInterface, id string, manufacturer string, modifieddate string) error {
+	return c.core.ProductReject(ctx, id, manufacturer, modifieddate)
+}
+
+// Cancel withdraws an order that has not been shipped yet
+func (c *OrderContract) Cancel(ctx contractapi.TransactionContextInterface, id string, modifieddate string) error {
+	return c.core.CancelOrder(ctx, id, modifieddate)
+}
+
+// Deliver marks a shipped order as delivered
+func (c *OrderContract) Deliver(ctx contractapi.TransactionContextInterface, id string, manufacturer string, delivereddate string) error {
+	return c.core.ProductDeliver(ctx, id, manufacturer, delivereddate)
+}
+
+// DeliverWithLocation marks a shipped order as delivered at the given coordinates
+func (c *OrderContract) DeliverWithLocation(ctx contractapi.TransactionContextInterface, id string, manufacturer string, delivereddate string, latitude float64, longitude float64) error {
+	return c.core.ProductDeliverWithLocation(ctx, id, manufacturer, delivereddate, latitude, longitude)
+}
+
+// ConfirmDelivery confirms receipt of a delivered order, releasing its escrow
+func (c *OrderContract) ConfirmDelivery(ctx contractapi.TransactionContextInterface, id string, confirmeddate string) error {
+	return c.core.ConfirmDelivery(ctx, id, confirmeddate)
+}
+
+// Rate rates a delivered order and its manufacturer
+func (c *OrderContract) Rate(ctx contractapi.TransactionContextInterface, id string, productScore int, manufacturerScore int, commentHash string) error {
+	return c.core.RateOrder(ctx, id, productScore, manufacturerScore, commentHash)
+}
+
+// GetRequested returns the products of a manufacturer with a pending order request
+func (c *OrderContract) GetRequested(ctx contractapi.TransactionContextInterface, userName string) ([]*Product, error) {
+	return c.core.GetOrderRequestedProductList(ctx, userName)
+}
+
+// GetByConsumer returns the products ordered by a consumer
+func (c *OrderContract) GetByConsumer(ctx contractapi.TransactionContextInterface, userName string) ([]*Product, error) {
+	return c.core.GetConsumerOrderedProductList(ctx, userName)
+}
+
+// GetOverdue returns the open orders of a manufacturer that missed a promised date
+func (c *OrderContract) GetOverdue(ctx contractapi.TransactionContextInterface, manufacturer string) ([]*OverdueOrder, error) {
+	return c.core.GetOverdueOrders(ctx, manufacturer)
+}
+
+// GetEscrow returns the escrow of the latest accepted order of a product
+func (c *OrderContract) GetEscrow(ctx contractapi.TransactionContextInterface, id string) (*Escrow, error) {
+	return c.core.GetEscrow(ctx, id)
+}
+
+// ReadInvoice returns an invoice to the members of the trade collection
+func (c *OrderContract) ReadInvoice(ctx contractapi.TransactionContextInterface, invoiceNumber string) (*Invoice, error) {
+	return c.core.ReadInvoice(ctx, invoiceNumber)
+}
+
+// MarkInvoicePaid records the payment of an invoice
+func (c *OrderContract) MarkInvoicePaid(ctx contractapi.TransactionContextInterface, invoiceNumber string, paymentReference string) error {
+	return c.core.MarkInvoicePaid(ctx, invoiceNumber, paymentReference)
+}
+
+// ReadDeliveryDetails returns the delivery details of an order to the manufacturer org
+func (c *OrderContract) ReadDeliveryDetails(ctx contractapi.TransactionContextInterface, id string) (*DeliveryDetails, error) {
+	return c.core.ReadDeliveryDetails(ctx, id)
+}
+
+// RequestQuote opens a quote negotiation for a product
+func (c *OrderContract) RequestQuote(ctx contractapi.TransactionContextInterface, quoteID string) error {
+	return c.core.RequestQuote(ctx, quoteID)
+}
+
+// OfferQuote answers a quote request with the manufacturer's terms
+func (c *OrderContract) OfferQuote(ctx contractapi.TransactionContextInterface, quoteID string) error {
+	return c.core.OfferQuote(ctx, quoteID)
+}
+
+// CounterQuote answers the other party's latest offer with new terms
+func (c *OrderContract) CounterQuote(ctx contractapi.TransactionContextInterface, quoteID string) error {
+	return c.core.CounterQuote(ctx, quoteID)
+}
+
+// AcceptQuote accepts the open terms of a quote, turning it into an accepted order
+func (c *OrderContract) AcceptQuote(ctx contractapi.TransactionContextInterface, quoteID string, modifieddate string) error {
+	return c.core.AcceptQuote(ctx, quoteID, modifieddate)
+}
+
+// RejectQuote ends a quote negotiation without an order
+func (c *OrderContract) RejectQuote(ctx contractapi.TransactionContextInterface, quoteID string) error {
+	return c.core.RejectQuote(ctx, quoteID)
+}
+
+// ReadQuote returns a quote to the parties negotiating it
+func (c *OrderContract) ReadQuote(ctx contractapi.TransactionContextInterface, quoteID string) (*Quote, error) {
+	return c.core.ReadQuote(ctx, quoteID)
+}
+
+// GetAllInvoices returns every invoice in the trade collection
+func (c *OrderContract) GetAllInvoices(ctx contractapi.TransactionContextInterface) ([]*Invoice, error) {
+	return c.core.GetAllInvoices(ctx)
+}
+
+// ReadCreditNote returns a late delivery credit note
+func (c *OrderContract) ReadCreditNote(ctx contractapi.TransactionContextInterface, creditNoteNumber string) (*CreditNote, error) {
+	return c.core.ReadCreditNote(ctx, creditNoteNumber)
+}
+
+// GetAllCreditNotes returns every credit note in the trade collection
+func (c *OrderContract) GetAllCreditNotes(ctx contractapi.TransactionContextInterface) ([]*CreditNote, error) {
+	return c.core.GetAllCreditNotes(ctx)
+}
+
+// PurgeDeliveryDetails removes the delivery details of a confirmed delivery from private data
+func (c *OrderContract) PurgeDeliveryDetails(ctx contractapi.TransactionContextInterface, id string) error {
+	return c.core.PurgeDeliveryDetails(ctx, id)
+}
+
+// GetManufacturerRatings returns the ratings of every order of a manufacturer
+func (c *OrderContract) GetManufacturerRatings(ctx contractapi.TransactionContextInterface, manufacturer string) ([]*Rating, error) {
+	return c.core.GetManufacturerRatings(ctx, manufacturer)
+}
+
+// GetManufacturerReputation returns the average rating of a manufacturer
+func (c *OrderContract) GetManufacturerReputation(ctx contractapi.TransactionContextInterface, manufacturer string) (*Reputation, error) {
+	return c.core.GetManufacturerReputation(ctx, manufacturer)
+}
+
+// GetDeliveryPerformance returns how many orders of a manufacturer met their service levels
+func (c *OrderContract) GetDeliveryPerformance(ctx contractapi.TransactionContextInterface, manufacturer string) (*DeliveryPerformance, error) {
+	return c.core.GetDeliveryPerformance(ctx, manufacturer)
+}
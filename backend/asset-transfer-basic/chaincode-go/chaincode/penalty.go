@@ -0,0 +1,281 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	creditNoteObjectType = "creditNote"
+	penaltyTermsConfig   = "penaltyTerms"
+
+	CreditNoteIssued  = "Issued"
+	CreditNoteApplied = "Applied" // deducted from the escrowed payment when it was released
+)
+
+// PenaltyTerms are the contractual penalties for late delivery. For every started day a delivery is late
+// after the grace period, RateBasisPointsPerDay of the invoiced amount is credited to the consumer, up to
+// CapBasisPoints of it. A zero rate disables penalties; a positive rate needs a positive cap.
+type PenaltyTerms struct {
+	RateBasisPointsPerDay int `json:"RateBasisPointsPerDay"`
+	GraceHours            int `json:"GraceHours"`
+	CapBasisPoints        int `json:"CapBasisPoints"`
+}
+
+// CreditNote credits the consumer with the penalty for a late delivery. Like invoices, credit notes are
+// kept in the trade collection.
+type CreditNote struct {
+	CreditNoteNumber      string `json:"CreditNoteNumber"`
+	ProductID             string `json:"ProductID"`
+	InvoiceNumber         string `json:"InvoiceNumber"`
+	Seller                string `json:"Seller"`
+	SellerOrg             string `json:"SellerOrg"`
+	Buyer                 string `json:"Buyer"`
+	BuyerAccount          string `json:"BuyerAccount"`
+	DeliverByDate         string `json:"DeliverByDate"`
+	DeliveredAt           string `json:"DeliveredAt"`
+	DaysLate              int    `json:"DaysLate"`
+	RateBasisPointsPerDay int    `json:"RateBasisPointsPerDay"`
+	GraceHours            int    `json:"GraceHours"`
+	CapBasisPoints        int    `json:"CapBasisPoints"`
+	OrderAmount           string `json:"OrderAmount"`
+	Amount                string `json:"Amount"`
+	IssueDate             string `json:"IssueDate"`
+	Status                string `json:"Status"`
+	IssuedTxID            string `json:"IssuedTxID"`
+	AppliedTxID           string `json:"AppliedTxID"`
+}
+
+// SetPenaltyTerms sets the late delivery penalty rate and cap, in basis points of the invoiced amount, and
+// the grace period in hours. They apply to deliveries made from then on.
+func (s *SmartContract) SetPenaltyTerms(ctx contractapi.TransactionContextInterface, rateBasisPointsPerDay int, graceHours int, capBasisPoints int) error {
+	if rateBasisPointsPerDay < 0 || graceHours < 0 {
+		return errValidation("the penalty rate and grace period must not be negative")
+	}
+	if capBasisPoints < 0 || capBasisPoints > 10000 {
+		return errValidation("the penalty cap must be between 0 and 10000 basis points")
+	}
+	if rateBasisPointsPerDay > 0 && capBasisPoints == 0 {
+		return errValidation("a penalty rate needs a cap above 0 basis points, a zero cap would turn every penalty into 0")
+	}
+
+	return writeConfig(ctx, penaltyTermsConfig, &PenaltyTerms{
+		RateBasisPointsPerDay: rateBasisPointsPerDay,
+		GraceHours:            graceHours,
+		CapBasisPoints:        capBasisPoints,
+	})
+}
+
+// GetPenaltyTerms returns the late delivery penalty terms
+func (s *SmartContract) GetPenaltyTerms(ctx contractapi.TransactionContextInterface) (*PenaltyTerms, error) {
+	return penaltyTerms(ctx)
+}
+
+// ReadCreditNote returns a credit note by its number
+func (s *SmartContract) ReadCreditNote(ctx contractapi.TransactionContextInterface, creditNoteNumber string) (*CreditNote, error) {
+	creditNote, err := readCreditNote(ctx, creditNoteNumber)
+	if err != nil {
+		return nil, err
+	}
+	if creditNote == nil {
+		return nil, errNotFound("credit note", creditNoteNumber)
+	}
+	return creditNote, nil
+}
+
+// GetAllCreditNotes returns every credit note in the trade collection
+func (s *SmartContract) GetAllCreditNotes(ctx contractapi.TransactionContextInterface) ([]*CreditNote, error) {
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(tradeCollection, creditNoteObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credit notes from %s: %v", tradeCollection, err)
+	}
+	defer resultsIterator.Close()
+
+	var creditNotes []*CreditNote
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over query results: %v", err)
+		}
+
+		var creditNote CreditNote
+		if err := json.Unmarshal(queryResponse.Value, &creditNote); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal credit note JSON: %v", err)
+		}
+		creditNotes = append(creditNotes, &creditNote)
+	}
+
+	return creditNotes, nil
+}
+
+// issueLatePenalty issues a credit note to the consumer when an order is delivered after its promised
+// deliver-by date and grace period. The delivery transaction's time counts, not the consumer's
+// confirmation, so a slow confirmation does not add to the penalty.
+func issueLatePenalty(ctx contractapi.TransactionContextInterface, product *Product) error {
+	if product.CreditNoteNumber != "" || product.DeliverByDate == "" || product.InvoiceNumber == "" {
+		return nil
+	}
+
+	terms, err := penaltyTerms(ctx)
+	if err != nil {
+		return err
+	}
+	if terms.RateBasisPointsPerDay == 0 {
+		return nil
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	deliverBy, err := time.Parse(time.RFC3339, product.DeliverByDate)
+	if err != nil {
+		return fmt.Errorf("the product %s has an invalid %s: %v", product.ID, MissedDeliverBy, err)
+	}
+	days := daysLate(terms, now.Sub(deliverBy))
+	if days == 0 {
+		return nil
+	}
+
+	invoice, err := readInvoice(ctx, product.InvoiceNumber)
+	if err != nil {
+		return err
+	}
+	if invoice == nil {
+		return errNotFound("invoice", product.InvoiceNumber)
+	}
+	orderAmount, err := parseAmount(invoice.Subtotal)
+	if err != nil {
+		return err
+	}
+
+	penalty := penaltyAmount(terms, orderAmount, days)
+	if penalty == 0 {
+		return nil
+	}
+
+	txID := ctx.GetStub().GetTxID()
+	creditNote := &CreditNote{
+		CreditNoteNumber:      documentNumber(ctx, "CN", now),
+		ProductID:             product.ID,
+		InvoiceNumber:         invoice.InvoiceNumber,
+		Seller:                invoice.Seller,
+		SellerOrg:             invoice.SellerOrg,
+		Buyer:                 invoice.Buyer,
+		BuyerAccount:          invoice.BuyerAccount,
+		DeliverByDate:         product.DeliverByDate,
+		DeliveredAt:           now.Format(time.RFC3339),
+		DaysLate:              days,
+		RateBasisPointsPerDay: terms.RateBasisPointsPerDay,
+		GraceHours:            terms.GraceHours,
+		CapBasisPoints:        terms.CapBasisPoints,
+		OrderAmount:           formatAmount(orderAmount),
+		Amount:                formatAmount(penalty),
+		IssueDate:             now.Format(invoiceDateFormat),
+		Status:                CreditNoteIssued,
+		IssuedTxID:            txID,
+	}
+	if err := putCreditNote(ctx, creditNote); err != nil {
+		return err
+	}
+
+	product.CreditNoteNumber = creditNote.CreditNoteNumber
+	return nil
+}
+
+// daysLate returns how many days a delivery made late past its deliver-by date is penalised for: every
+// started day after the grace period counts
+func daysLate(terms *PenaltyTerms, late time.Duration) int {
+	late -= time.Duration(terms.GraceHours) * time.Hour
+	if late <= 0 {
+		return 0
+	}
+	return int((late + 24*time.Hour - 1) / (24 * time.Hour))
+}
+
+// penaltyAmount returns the penalty on an order amount for a number of days late, rounded half up to the
+// nearest minor unit and capped at CapBasisPoints of the amount
+func penaltyAmount(terms *PenaltyTerms, orderAmount int64, days int) int64 {
+	penalty := (orderAmount*int64(terms.RateBasisPointsPerDay)*int64(days) + 5000) / 10000
+	if maxPenalty := orderAmount * int64(terms.CapBasisPoints) / 10000; penalty > maxPenalty {
+		penalty = maxPenalty
+	}
+	return penalty
+}
+
+// applyCreditNote marks the credit note of an order as deducted from an escrowed payment and returns the
+// amount to deduct, which never exceeds the escrowed amount
+func applyCreditNote(ctx contractapi.TransactionContextInterface, product *Product, escrowAmount int64) (int64, error) {
+	if product.CreditNoteNumber == "" {
+		return 0, nil
+	}
+	creditNote, err := readCreditNote(ctx, product.CreditNoteNumber)
+	if err != nil {
+		return 0, err
+	}
+	if creditNote == nil || creditNote.Status != CreditNoteIssued {
+		return 0, nil
+	}
+
+	amount, err := parseAmount(creditNote.Amount)
+	if err != nil {
+		return 0, err
+	}
+	if amount > escrowAmount {
+		amount = escrowAmount
+	}
+
+	creditNote.Status = CreditNoteApplied
+	creditNote.AppliedTxID = ctx.GetStub().GetTxID()
+	if err := putCreditNote(ctx, creditNote); err != nil {
+		return 0, err
+	}
+
+	return amount, nil
+}
+
+func penaltyTerms(ctx contractapi.TransactionContextInterface) (*PenaltyTerms, error) {
+	terms := &PenaltyTerms{}
+	if _, err := readConfig(ctx, penaltyTermsConfig, terms); err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
+func readCreditNote(ctx contractapi.TransactionContextInterface, creditNoteNumber string) (*CreditNote, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(creditNoteObjectType, []string{creditNoteNumber})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create credit note key: %v", err)
+	}
+	creditNoteJSON, err := ctx.GetStub().GetPrivateData(tradeCollection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credit note from %s: %v", tradeCollection, err)
+	}
+	if creditNoteJSON == nil {
+		return nil, nil
+	}
+
+	var creditNote CreditNote
+	if err := json.Unmarshal(creditNoteJSON, &creditNote); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal credit note JSON: %v", err)
+	}
+	return &creditNote, nil
+}
+
+func putCreditNote(ctx contractapi.TransactionContextInterface, creditNote *CreditNote) error {
+	key, err := ctx.GetStub().CreateCompositeKey(creditNoteObjectType, []string{creditNote.CreditNoteNumber})
+	if err != nil {
+		return fmt.Errorf("failed to create credit note key: %v", err)
+	}
+	creditNoteJSON, err := json.Marshal(creditNote)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credit note JSON: %v", err)
+	}
+	if err := ctx.GetStub().PutPrivateData(tradeCollection, key, creditNoteJSON); err != nil {
+		return fmt.Errorf("failed to put credit note to %s: %v", tradeCollection, err)
+	}
+	return nil
+}
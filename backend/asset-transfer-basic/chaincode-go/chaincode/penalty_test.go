@@ -0,0 +1,128 @@
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaysLate(t *testing.T) {
+	tests := []struct {
+		name       string
+		graceHours int
+		late       time.Duration
+		want       int
+	}{
+		{name: "early", late: -time.Hour, want: 0},
+		{name: "on time", late: 0, want: 0},
+		{name: "one second late", late: time.Second, want: 1},
+		{name: "exactly one day late", late: 24 * time.Hour, want: 1},
+		{name: "just over one day late", late: 24*time.Hour + time.Second, want: 2},
+		{name: "within the grace period", graceHours: 12, late: 12 * time.Hour, want: 0},
+		{name: "just after the grace period", graceHours: 12, late: 12*time.Hour + time.Minute, want: 1},
+		{name: "days counted after the grace period", graceHours: 24, late: 72 * time.Hour, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, daysLate(&PenaltyTerms{GraceHours: tt.graceHours}, tt.late))
+		})
+	}
+}
+
+func TestPenaltyAmount(t *testing.T) {
+	tests := []struct {
+		name        string
+		rate        int
+		cap         int
+		orderAmount int64
+		days        int
+		want        int64
+	}{
+		{name: "one day", rate: 100, cap: 10000, orderAmount: 10000, days: 1, want: 100},
+		{name: "several days", rate: 150, cap: 10000, orderAmount: 10000, days: 3, want: 450},
+		{name: "rounds half up", rate: 50, cap: 10000, orderAmount: 101, days: 1, want: 1},
+		{name: "rounds down below half", rate: 40, cap: 10000, orderAmount: 101, days: 1, want: 0},
+		{name: "capped", rate: 500, cap: 1000, orderAmount: 10000, days: 5, want: 1000},
+		{name: "below the cap", rate: 500, cap: 1000, orderAmount: 10000, days: 1, want: 500},
+		{name: "zero cap", rate: 500, cap: 0, orderAmount: 10000, days: 1, want: 0},
+		{name: "never more than the order", rate: 10000, cap: 10000, orderAmount: 999, days: 3, want: 999},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			terms := &PenaltyTerms{RateBasisPointsPerDay: tt.rate, CapBasisPoints: tt.cap}
+			require.Equal(t, tt.want, penaltyAmount(terms, tt.orderAmount, tt.days))
+		})
+	}
+}
+
+func TestSetPenaltyTerms(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    int
+		grace   int
+		cap     int
+		wantErr bool
+	}{
+		{name: "capped rate", rate: 100, cap: 1000},
+		{name: "disabled", rate: 0, cap: 0},
+		{name: "full cap", rate: 100, cap: 10000},
+		{name: "rate without a cap", rate: 100, cap: 0, wantErr: true},
+		{name: "negative rate", rate: -1, cap: 1000, wantErr: true},
+		{name: "negative grace period", rate: 100, grace: -1, cap: 1000, wantErr: true},
+		{name: "cap above the order", rate: 100, cap: 10001, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newLedgerStub()
+			ctx := newClientContext(stub, "Org1MSP", "maker")
+			stub.begin("terms")
+			err := (&SmartContract{}).SetPenaltyTerms(ctx, tt.rate, tt.grace, tt.cap)
+			if tt.wantErr {
+				RequireContractError(t, err, CodeValidationFailed)
+				return
+			}
+			require.NoError(t, err)
+			stub.commit()
+
+			terms, err := (&SmartContract{}).GetPenaltyTerms(ctx)
+			require.NoError(t, err)
+			require.Equal(t, &PenaltyTerms{RateBasisPointsPerDay: tt.rate, GraceHours: tt.grace, CapBasisPoints: tt.cap}, terms)
+		})
+	}
+}
+
+func TestLateDeliveryPenaltyDeductedFromEscrow(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.submit(t, "terms", func() error { return f.contract.SetPenaltyTerms(f.manufacturer, 100, 0, 1000) })
+	f.order(t, "order")
+	// The stub runs every transaction an hour after the previous one, so delivery misses this deadline
+	deadline := f.stub.txTime.Add(90 * time.Minute).Format(time.RFC3339)
+	f.submit(t, "accept", func() error {
+		return f.contract.ProductAcceptWithSLA(f.manufacturer, "p1", "maker", "x", deadline, deadline)
+	})
+	f.submit(t, "ship", func() error { return f.contract.ProductShip(f.manufacturer, "p1", "x") })
+	f.submit(t, "deliver", func() error { return f.contract.ProductDeliver(f.manufacturer, "p1", "maker", "x") })
+
+	product := f.product(t)
+	require.NotEmpty(t, product.CreditNoteNumber)
+	creditNote, err := f.contract.ReadCreditNote(f.manufacturer, product.CreditNoteNumber)
+	require.NoError(t, err)
+	require.Equal(t, 1, creditNote.DaysLate)
+	require.Equal(t, "0.10", creditNote.Amount)
+	require.Equal(t, CreditNoteIssued, creditNote.Status)
+
+	f.submit(t, "confirm", func() error { return f.contract.ConfirmDelivery(f.consumer, "p1", "x") })
+	escrow := f.escrow(t)
+	require.Equal(t, EscrowReleased, escrow.Status)
+	require.Equal(t, int64(10), escrow.Penalty)
+	require.Equal(t, int64(990), f.balance(t, manufacturerAccount))
+	require.Equal(t, int64(1510), f.balance(t, consumerAccount))
+
+	creditNote, err = f.contract.ReadCreditNote(f.manufacturer, product.CreditNoteNumber)
+	require.NoError(t, err)
+	require.Equal(t, CreditNoteApplied, creditNote.Status)
+}
@@ -0,0 +1,163 @@
+package chaincode
+
+// defaultContractName is the name contractapi gives SmartContract, which is registered without one
+const defaultContractName = "SmartContract"
+
+var (
+	manufacturerOrgs = []string{"Org1MSP"}
+	consumerOrgs     = []string{"Org2MSP"}
+	tokenIssuerOrgs  = []string{tokenIssuerOrg}
+)
+
+// transactionAliases maps the transactions of the named contracts onto the SmartContract transaction they
+// delegate to with the same parameters, so that transactionPermissions and parameterRules only have to
+// declare each transaction once
+var transactionAliases = map[string]string{
+	"product:Create":                "CreateProduct",
+	"product:CreateBatch":           "CreateProductsBatch",
+	"product:CreateWithGeneratedID": "CreateProductWithGeneratedID",
+	"product:Update":                "UpdateProduct",
+	"product:Read":                  "ReadProduct",
+	"product:Exists":                "ProductExists",
+	"product:GetAll":                "GetAllProducts",
+	"product:GetByManufacturer":     "GetProductsByManufacturer",
+	"product:GetByGTIN":             "GetProductsByGTIN",
+	"product:GetStatus":             "GetProductStatus",
+	"product:VerifyAuthenticity":    "VerifyProductAuthenticity",
+	"product:SetCategory":           "SetProductCategory",
+	"product:History":               "TrackProductHistory",
+	"product:HistoryPage":           "GetProductHistoryPage",
+	"product:ExportEPCIS":           "ExportEPCISDocument",
+	"product:GetRatings":            "GetProductRatings",
+	"product:ExportEPCISForProduct": "ExportProductEPCIS",
+	"product:ParseGS1Identifier":    "ParseGS1Identifier",
+	"product:ReadPriceTerms":        "ReadPriceTerms",
+	"product:VerifyPriceTerms":      "VerifyPriceTerms",
+
+	"order:Place":                     "ProductOrder",
+	"order:Accept":                    "ProductAccept",
+	"order:AcceptBatch":               "ProductAcceptBatch",
+	"order:AcceptWithSLA":             "ProductAcceptWithSLA",
+	"order:Reject":                    "ProductReject",
+	"order:Cancel":                    "CancelOrder",
+	"order:Deliver":                   "ProductDeliver",
+	"order:DeliverWithLocation":       "ProductDeliverWithLocation",
+	"order:ConfirmDelivery":           "ConfirmDelivery",
+	"order:Rate":                      "RateOrder",
+	"order:GetRequested":              "GetOrderRequestedProductList",
+	"order:GetByConsumer":             "GetConsumerOrderedProductList",
+	"order:GetOverdue":                "GetOverdueOrders",
+	"order:GetEscrow":                 "GetEscrow",
+	"order:ReadInvoice":               "ReadInvoice",
+	"order:MarkInvoicePaid":           "MarkInvoicePaid",
+	"order:ReadDeliveryDetails":       "ReadDeliveryDetails",
+	"order:RequestQuote":              "RequestQuote",
+	"order:OfferQuote":                "OfferQuote",
+	"order:CounterQuote":              "CounterQuote",
+	"order:AcceptQuote":               "AcceptQuote",
+	"order:RejectQuote":               "RejectQuote",
+	"order:ReadQuote":                 "ReadQuote",
+	"order:GetAllInvoices":            "GetAllInvoices",
+	"order:ReadCreditNote":            "ReadCreditNote",
+	"order:GetAllCreditNotes":         "GetAllCreditNotes",
+	"order:PurgeDeliveryDetails":      "PurgeDeliveryDetails",
+	"order:GetManufacturerRatings":    "GetManufacturerRatings",
+	"order:GetManufacturerReputation": "GetManufacturerReputation",
+	"order:GetDeliveryPerformance":    "GetDeliveryPerformance",
+
+	"shipment:ShipProduct":          "ProductShip",
+	"shipment:ShipBatch":            "ProductShipBatch",
+	"shipment:Create":               "ShipProducts",
+	"shipment:CreateFromWarehouse":  "ShipProductsFromWarehouse",
+	"shipment:Read":                 "ReadShipment",
+	"shipment:AddWaypoint":          "AddShipmentWaypoint",
+	"shipment:SetDestination":       "SetShipmentDestination",
+	"shipment:SubmitSensorReadings": "SubmitSensorReadings",
+	"shipment:GetSensorBatches":     "GetShipmentSensorBatches",
+
+	"warehouse:Create":          "CreateWarehouse",
+	"warehouse:AddLocation":     "AddWarehouseLocation",
+	"warehouse:Read":            "ReadWarehouse",
+	"warehouse:Receive":         "ReceiveStock",
+	"warehouse:Putaway":         "PutawayStock",
+	"warehouse:Pick":            "PickStock",
+	"warehouse:Transfer":        "TransferStock",
+	"warehouse:GetProductStock": "GetProductStock",
+	"warehouse:GetStock":        "GetWarehouseStock",
+	"warehouse:GetMovements":    "GetStockMovements",
+
+	"admin:InitLedger":             "InitLedger",
+	"admin:SetInvoiceTerms":        "SetInvoiceTerms",
+	"admin:GetInvoiceTerms":        "GetInvoiceTerms",
+	"admin:SetSLATerms":            "SetSLATerms",
+	"admin:GetSLATerms":            "GetSLATerms",
+	"admin:SetPenaltyTerms":        "SetPenaltyTerms",
+	"admin:GetPenaltyTerms":        "GetPenaltyTerms",
+	"admin:SetGeofencePolicy":      "SetGeofencePolicy",
+	"admin:GetGeofencePolicy":      "GetGeofencePolicy",
+	"admin:SetConditionRange":      "SetConditionRange",
+	"admin:GetConditionRange":      "GetConditionRange",
+	"admin:SetIdentifierMode":      "SetIdentifierMode",
+	"admin:GetIdentifierMode":      "GetIdentifierMode",
+	"admin:CompactStatusCounters":  "CompactStatusCounters",
+	"admin:RebuildStatusCounters":  "RebuildStatusCounters",
+	"admin:GetStatusCounts":        "GetStatusCounts",
+	"admin:GetManufacturerMetrics": "GetManufacturerMetrics",
+	"admin:RebuildOrderMetrics":    "RebuildOrderMetrics",
+}
+
+// transactionPermissions lists the orgs allowed to invoke each transaction and is enforced by
+// beforeTransaction. Transactions missing from the map are open to every org; checks that depend on the
+// ledger, such as being the manufacturer of a product or the seller on an invoice, stay in the
+// transactions themselves.
+var transactionPermissions = map[string][]string{
+	// Product catalogue
+	"InitLedger":                   manufacturerOrgs,
+	"CreateProduct":                manufacturerOrgs,
+	"CreateProductsBatch":          manufacturerOrgs,
+	"CreateProductWithGeneratedID": manufacturerOrgs,
+	"UpdateProduct":                manufacturerOrgs,
+	"SetProductCategory":           manufacturerOrgs,
+
+	// Orders
+	"ProductOrder":               consumerOrgs,
+	"CancelOrder":                consumerOrgs,
+	"ConfirmDelivery":            consumerOrgs,
+	"RateOrder":                  consumerOrgs,
+	"RequestQuote":               consumerOrgs,
+	"ProductAccept":              manufacturerOrgs,
+	"ProductAcceptBatch":         manufacturerOrgs,
+	"ProductAcceptWithSLA":       manufacturerOrgs,
+	"ProductReject":              manufacturerOrgs,
+	"ProductDeliver":             manufacturerOrgs,
+	"ProductDeliverWithLocation": manufacturerOrgs,
+
+	// Shipments
+	"ProductShip":               manufacturerOrgs,
+	"ProductShipBatch":          manufacturerOrgs,
+	"ShipProducts":              manufacturerOrgs,
+	"ShipProductsFromWarehouse": manufacturerOrgs,
+
+	// Configuration and maintenance
+	"SetInvoiceTerms":       manufacturerOrgs,
+	"SetSLATerms":           manufacturerOrgs,
+	"SetPenaltyTerms":       manufacturerOrgs,
+	"SetGeofencePolicy":     manufacturerOrgs,
+	"SetConditionRange":     manufacturerOrgs,
+	"SetIdentifierMode":     manufacturerOrgs,
+	"CompactStatusCounters": manufacturerOrgs,
+	"RebuildStatusCounters": manufacturerOrgs,
+	"RebuildOrderMetrics":   manufacturerOrgs,
+
+	// Settlement token
+	"token:Mint": tokenIssuerOrgs,
+}
+
+// transactionName returns the name a transaction is declared under in transactionPermissions and
+// parameterRules, given the name it was invoked with
+func transactionName(function string) string {
+	if name, ok := transactionAliases[function]; ok {
+		return name
+	}
+	return function
+}
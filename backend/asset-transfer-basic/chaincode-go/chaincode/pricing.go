@@ -0,0 +1,294 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// tradeCollection is the private data collection shared by the manufacturer org and every consumer org,
+	// see collections_config.json. It keeps the order documents every member needs to read: invoices, credit
+	// notes, the seller invoice index and delivery destinations. Prices and negotiations are kept in the
+	// terms collection of a single buyer org instead, see termsCollection.
+	tradeCollection = "tradeTermsCollection"
+	// termsCollectionPrefix starts the names of the terms collections, one per manufacturer and buyer org
+	termsCollectionPrefix = "tradeTerms_"
+
+	priceTermsObjectType = "priceTerms"
+	transientPriceTerms  = "price_terms"
+	minSaltLength        = 16
+)
+
+// PriceTerms is the commercial record of a product offered to one buyer org, kept in the terms collection
+// of that org and the manufacturer's. Only its SHA-256 hash is written to the public product as PriceHash.
+type PriceTerms struct {
+	ProductID string `json:"ProductID"`
+	BuyerOrg  string `json:"BuyerOrg"` // MSP ID of the consumer org the price is offered to
+	Price     string `json:"Price"`
+	Terms     string `json:"Terms"`
+	Salt      string `json:"Salt"` // random value chosen by the client so that the hash cannot be guessed
+}
+
+// termsCollection returns the private data collection shared only by a manufacturer org and one buyer org,
+// which keeps their prices and negotiations from competing buyer orgs. Collections are granted per org, so
+// every client of the buyer org can read them; buyers that compete must be enrolled in different orgs.
+// collections_config.json defines one for every pair of manufacturer and consumer orgs.
+func termsCollection(manufacturerOrg string, buyerOrg string) string {
+	return termsCollectionPrefix + manufacturerOrg + "_" + buyerOrg
+}
+
+// ReadPriceTerms returns the private price and terms of a product to the manufacturer org and the buyer org
+// they are offered to
+func (s *SmartContract) ReadPriceTerms(ctx contractapi.TransactionContextInterface, id string) (*PriceTerms, error) {
+	product, err := s.ReadProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if product.PriceHash == "" {
+		return nil, newContractError(CodeNotFound, "the product %s has no private price terms", id)
+	}
+
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if clientOrg != manufacturerOrg(product) && clientOrg != product.PriceBuyerOrg {
+		return nil, errForbidden("Access denied: Only the manufacturer org and %s can read the price terms of product %s", product.PriceBuyerOrg, id)
+	}
+
+	terms, err := readPriceTerms(ctx, product)
+	if err != nil {
+		return nil, err
+	}
+	if terms == nil {
+		return nil, newContractError(CodeNotFound, "the product %s has no private price terms", id)
+	}
+
+	return terms, nil
+}
+
+// VerifyPriceTerms checks the price terms passed in the "price_terms" transient field, including their
+// salt, against the hashes recorded on the public product: that of its private price and that of the terms
+// agreed for its current order, whose salt is in the quote's AgreedTerms. Either party can use it to prove
+// the agreed price to the other or to a third party without revealing it on the ledger.
+func (s *SmartContract) VerifyPriceTerms(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	product, err := s.ReadProduct(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if product.PriceHash == "" && product.QuotePriceHash == "" {
+		return false, errInvalidState("the product %s does not have a private price", id)
+	}
+
+	terms, err := transientPriceTermsFor(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if terms == nil {
+		return false, errValidation("%s must be passed in the transient map", transientPriceTerms)
+	}
+
+	_, hash, err := marshalPriceTerms(terms)
+	if err != nil {
+		return false, err
+	}
+
+	return hash == product.PriceHash || hash == product.QuotePriceHash, nil
+}
+
+// applyPrivatePrice moves the price of a product being written into the terms collection of the buyer org
+// named in the "price_terms" transient field, leaving only the hash on the public record. Without the field
+// earlier private terms stay as they are; an empty "price_terms" value removes them, so that the product can
+// return to a public price.
+func applyPrivatePrice(ctx contractapi.TransactionContextInterface, product *Product) error {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("error getting transient: %v", err)
+	}
+	if termsJSON, ok := transientMap[transientPriceTerms]; ok && len(termsJSON) == 0 {
+		return deletePriceTerms(ctx, product)
+	}
+
+	terms, err := transientPriceTermsFor(ctx, product.ID)
+	if err != nil {
+		return err
+	}
+
+	if terms == nil {
+		if product.PriceHash != "" && product.Price != "" {
+			return errValidation("the product %s has private price terms, pass an empty %s in the transient map to replace them with a public price", product.ID, transientPriceTerms)
+		}
+		return nil
+	}
+
+	if product.Price != "" {
+		return errValidation("the public price must be empty when price_terms are passed in the transient map")
+	}
+
+	// Terms offered to another buyer org before must not stay readable to it
+	if product.PriceBuyerOrg != terms.BuyerOrg {
+		if err := deletePriceTerms(ctx, product); err != nil {
+			return err
+		}
+	}
+	hash, err := writePriceTerms(ctx, product, terms)
+	if err != nil {
+		return err
+	}
+	product.PriceHash = hash
+	product.PriceBuyerOrg = terms.BuyerOrg
+
+	return nil
+}
+
+// deletePriceTerms removes the private price terms of a product, if it has any
+func deletePriceTerms(ctx contractapi.TransactionContextInterface, product *Product) error {
+	if product.PriceHash == "" {
+		return nil
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(priceTermsObjectType, []string{product.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create price terms key: %v", err)
+	}
+	collection := termsCollection(manufacturerOrg(product), product.PriceBuyerOrg)
+	if err := ctx.GetStub().DelPrivateData(collection, key); err != nil {
+		return fmt.Errorf("failed to delete price terms from %s: %v", collection, err)
+	}
+	product.PriceHash = ""
+	product.PriceBuyerOrg = ""
+
+	return nil
+}
+
+// writePriceTerms stores the price terms of a product in the terms collection of their buyer org and returns
+// the hash to record on the product
+func writePriceTerms(ctx contractapi.TransactionContextInterface, product *Product, terms *PriceTerms) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(priceTermsObjectType, []string{product.ID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create price terms key: %v", err)
+	}
+
+	termsJSON, hash, err := marshalPriceTerms(terms)
+	if err != nil {
+		return "", err
+	}
+	collection := termsCollection(manufacturerOrg(product), terms.BuyerOrg)
+	if err := ctx.GetStub().PutPrivateData(collection, key, termsJSON); err != nil {
+		return "", fmt.Errorf("failed to put price terms to %s: %v", collection, err)
+	}
+
+	return hash, nil
+}
+
+// readPriceTerms returns the private price terms of a product, or nil when this peer does not hold them
+func readPriceTerms(ctx contractapi.TransactionContextInterface, product *Product) (*PriceTerms, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(priceTermsObjectType, []string{product.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create price terms key: %v", err)
+	}
+	collection := termsCollection(manufacturerOrg(product), product.PriceBuyerOrg)
+	termsJSON, err := ctx.GetStub().GetPrivateData(collection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price terms from %s: %v", collection, err)
+	}
+	if termsJSON == nil {
+		return nil, nil
+	}
+
+	var terms PriceTerms
+	if err := json.Unmarshal(termsJSON, &terms); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal price terms JSON: %v", err)
+	}
+	return &terms, nil
+}
+
+// priced reports whether a product has a public or a private price
+func priced(product *Product) bool {
+	return product.Price != "" || product.PriceHash != ""
+}
+
+// productPrice returns the price the current order of a product is billed at: the price agreed in its quote,
+// or else the product's price, read from the terms collection when it is private
+func productPrice(ctx contractapi.TransactionContextInterface, product *Product) (string, error) {
+	if product.QuoteID != "" {
+		quote, err := readQuote(ctx, product.QuoteID)
+		if err != nil {
+			return "", err
+		}
+		if quote == nil || quote.AgreedTerms == nil {
+			return "", fmt.Errorf("the quote %s of product %s has no agreed terms available to this peer", product.QuoteID, product.ID)
+		}
+		return quote.AgreedTerms.Price, nil
+	}
+	if product.PriceHash == "" {
+		return product.Price, nil
+	}
+
+	terms, err := readPriceTerms(ctx, product)
+	if err != nil {
+		return "", err
+	}
+	if terms == nil {
+		return "", fmt.Errorf("the private price terms of product %s are not available to this peer", product.ID)
+	}
+
+	return terms.Price, nil
+}
+
+// transientPriceTermsFor reads and validates the price terms in the transient map, returning nil when
+// the client did not pass any or passed an empty value
+func transientPriceTermsFor(ctx contractapi.TransactionContextInterface, id string) (*PriceTerms, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error getting transient: %v", err)
+	}
+
+	termsJSON, ok := transientMap[transientPriceTerms]
+	if !ok || len(termsJSON) == 0 {
+		return nil, nil
+	}
+
+	var terms PriceTerms
+	if err := json.Unmarshal(termsJSON, &terms); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s JSON: %v", transientPriceTerms, err)
+	}
+	if terms.Price == "" {
+		return nil, errValidation("%s must include a Price", transientPriceTerms)
+	}
+	if !isConsumerOrg(terms.BuyerOrg) {
+		return nil, errValidation("%s must include the BuyerOrg the price is offered to, one of %s", transientPriceTerms, strings.Join(consumerOrgs, ", "))
+	}
+	if len(terms.Salt) < minSaltLength {
+		return nil, errValidation("%s must include a Salt of at least %d characters", transientPriceTerms, minSaltLength)
+	}
+	terms.ProductID = id
+
+	return &terms, nil
+}
+
+// marshalPriceTerms serialises price terms the same way every time and returns the hex SHA-256 hash of
+// the bytes, which matches the private data hash Fabric records for the collection write
+func marshalPriceTerms(terms *PriceTerms) ([]byte, string, error) {
+	termsJSON, err := json.Marshal(terms)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal price terms JSON: %v", err)
+	}
+	hash := sha256.Sum256(termsJSON)
+	return termsJSON, hex.EncodeToString(hash[:]), nil
+}
+
+// isConsumerOrg reports whether an org is one of the consumer orgs that has a terms collection
+func isConsumerOrg(mspID string) bool {
+	for _, org := range consumerOrgs {
+		if mspID == org {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testPriceTerms = `{"BuyerOrg":"Org2MSP","Price":"12.00","Terms":"net 30","Salt":"0123456789abcdef"}`
+
+// newPrivatePriceFixture creates p2 at a private price of 12.00 offered to Org2MSP
+func newPrivatePriceFixture(t *testing.T) *escrowFixture {
+	f := newEscrowFixture(t)
+	f.submit(t, "create-private", func() error {
+		f.stub.transient[transientPriceTerms] = []byte(testPriceTerms)
+		return f.contract.CreateProduct(f.manufacturer, "p2", "pear", "good", "", "maker", "")
+	})
+	return f
+}
+
+func TestPrivatePriceKeptInTheTermsCollectionOfTheBuyerOrg(t *testing.T) {
+	f := newPrivatePriceFixture(t)
+
+	product, err := f.contract.ReadProduct(f.manufacturer, "p2")
+	require.NoError(t, err)
+	require.Empty(t, product.Price)
+	require.NotEmpty(t, product.PriceHash)
+	require.Equal(t, "Org2MSP", product.PriceBuyerOrg)
+	require.Len(t, f.stub.privateData[termsCollection("Org1MSP", "Org2MSP")], 1)
+	require.Empty(t, f.stub.privateData[tradeCollection])
+
+	terms, err := f.contract.ReadPriceTerms(f.consumer, "p2")
+	require.NoError(t, err)
+	require.Equal(t, "12.00", terms.Price)
+
+	competitor := newClientContext(f.stub, "Org3MSP", "competitor")
+	_, err = f.contract.ReadPriceTerms(competitor, "p2")
+	RequireContractError(t, err, CodeForbidden)
+	err = f.try("order-competitor", func() error { return f.contract.ProductOrder(competitor, "p2", "eve", "x") })
+	RequireContractError(t, err, CodeForbidden)
+
+	// The buyer org orders and is charged the private price
+	f.submit(t, "order", func() error { return f.contract.ProductOrder(f.consumer, "p2", "bob", "x") })
+	f.submit(t, "accept", func() error { return f.contract.ProductAccept(f.manufacturer, "p2", "maker", "x") })
+	escrow, err := f.contract.GetEscrow(f.manufacturer, "p2")
+	require.NoError(t, err)
+	require.Equal(t, int64(1200), escrow.Amount)
+}
+
+func TestPriceTermsNeedAConsumerBuyerOrg(t *testing.T) {
+	for _, termsJSON := range []string{
+		`{"Price":"12.00","Salt":"0123456789abcdef"}`,
+		`{"BuyerOrg":"Org1MSP","Price":"12.00","Salt":"0123456789abcdef"}`,
+		`{"BuyerOrg":"Org2MSP","Price":"12.00","Salt":"short"}`,
+		`{"BuyerOrg":"Org2MSP","Salt":"0123456789abcdef"}`,
+	} {
+		f := newEscrowFixture(t)
+		err := f.try("create-private", func() error {
+			f.stub.transient[transientPriceTerms] = []byte(termsJSON)
+			return f.contract.CreateProduct(f.manufacturer, "p2", "pear", "good", "", "maker", "")
+		})
+		RequireContractError(t, err, CodeValidationFailed)
+	}
+}
+
+func TestUpdateProductKeepsOrClearsPrivatePrice(t *testing.T) {
+	f := newPrivatePriceFixture(t)
+	product, err := f.contract.ReadProduct(f.manufacturer, "p2")
+	require.NoError(t, err)
+	priceHash := product.PriceHash
+
+	// Without price_terms the private terms stay
+	f.submit(t, "rename", func() error {
+		return f.contract.UpdateProduct(f.manufacturer, "p2", "green pear", "good", "", "maker", "x")
+	})
+	product, err = f.contract.ReadProduct(f.manufacturer, "p2")
+	require.NoError(t, err)
+	require.Equal(t, priceHash, product.PriceHash)
+	terms, err := f.contract.ReadPriceTerms(f.consumer, "p2")
+	require.NoError(t, err)
+	require.Equal(t, "12.00", terms.Price)
+
+	// Nor can a public price replace them by accident
+	err = f.try("reprice-public", func() error {
+		return f.contract.UpdateProduct(f.manufacturer, "p2", "green pear", "good", "11.00", "maker", "x")
+	})
+	RequireContractError(t, err, CodeValidationFailed)
+
+	// An empty price_terms value removes them in favour of the public price
+	f.submit(t, "clear", func() error {
+		f.stub.transient[transientPriceTerms] = []byte{}
+		return f.contract.UpdateProduct(f.manufacturer, "p2", "green pear", "good", "11.00", "maker", "x")
+	})
+	product, err = f.contract.ReadProduct(f.manufacturer, "p2")
+	require.NoError(t, err)
+	require.Equal(t, "11.00", product.Price)
+	require.Empty(t, product.PriceHash)
+	require.Empty(t, product.PriceBuyerOrg)
+	require.Empty(t, f.stub.privateData[termsCollection("Org1MSP", "Org2MSP")])
+}
+
+func TestVerifyPriceTerms(t *testing.T) {
+	f := newPrivatePriceFixture(t)
+
+	tests := []struct {
+		termsJSON string
+		want      bool
+	}{
+		{termsJSON: testPriceTerms, want: true},
+		{termsJSON: `{"BuyerOrg":"Org2MSP","Price":"11.00","Terms":"net 30","Salt":"0123456789abcdef"}`, want: false},
+		{termsJSON: `{"BuyerOrg":"Org2MSP","Price":"12.00","Terms":"net 30","Salt":"fedcba9876543210"}`, want: false},
+	}
+	for _, tt := range tests {
+		f.stub.begin("verify")
+		f.stub.transient[transientPriceTerms] = []byte(tt.termsJSON)
+		verified, err := f.contract.VerifyPriceTerms(f.consumer, "p2")
+		require.NoError(t, err)
+		require.Equal(t, tt.want, verified, tt.termsJSON)
+	}
+
+	// A product with a public price has nothing to verify
+	f.stub.begin("verify-public")
+	f.stub.transient[transientPriceTerms] = []byte(testPriceTerms)
+	_, err := f.contract.VerifyPriceTerms(f.consumer, "p1")
+	RequireContractError(t, err, CodeInvalidState)
+}
@@ -0,0 +1,117 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ProductContract groups the product catalogue transactions under the "product:" prefix. It shares its
+// implementation and world state with SmartContract, which keeps serving the unprefixed names.
+type ProductContract struct {
+	contractapi.Contract
+	core SmartContract
+}
+
+// NewProductContract returns the product contract, invoked with the "product:" prefix
+func NewProductContract() *ProductContract {
+	return &ProductContract{Contract: newContract("product")}
+}
+
+// Create issues a new product to the world state
+func (c *ProductContract) Create(ctx contractapi.TransactionContextInterface, id string, name string, description string, price string, manufacturer string, createddate string) error {
+	return c.core.CreateProduct(ctx, id, name, description, price, manufacturer, createddate)
+}
+
+// CreateWithGeneratedID issues a new product under an ID derived from the transaction ID and returns the ID
+func (c *ProductContract) CreateWithGeneratedID(ctx contractapi.TransactionContextInterface, prefix string, name string, description string, price string, manufacturer string, createddate string) (string, error) {
+	return c.core.CreateProductWithGeneratedID(ctx, prefix, name, description, price, manufacturer, createddate)
+}
+
+// CreateBatch issues every product of a catalogue import or none of them
+func (c *ProductContract) CreateBatch(ctx contractapi.TransactionContextInterface, products []ProductInput) error {
+	return c.core.CreateProductsBatch(ctx, products)
+}
+
+// Update changes the details of an existing product
+func (c *ProductContract) Update(ctx contractapi.TransactionContextInterface, id string, name string, description string, price string, manufacturer string, modifieddate string) error {
+	return c.core.UpdateProduct(ctx, id, name, description, price, manufacturer, modifieddate)
+}
+
+// Read returns the product stored in the world state with the given id
+func (c *ProductContract) Read(ctx contractapi.TransactionContextInterface, id string) (*Product, error) {
+	return c.core.ReadProduct(ctx, id)
+}
+
+// Exists returns true when a product with the given id exists
+func (c *ProductContract) Exists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	return c.core.ProductExists(ctx, id)
+}
+
+// GetAll returns all products in the world state
+func (c *ProductContract) GetAll(ctx contractapi.TransactionContextInterface) ([]*Product, error) {
+	return c.core.GetAllProducts(ctx)
+}
+
+// GetByManufacturer returns the products of a manufacturer
+func (c *ProductContract) GetByManufacturer(ctx contractapi.TransactionContextInterface, manufacturer string) ([]*Product, error) {
+	return c.core.GetProductsByManufacturer(ctx, manufacturer)
+}
+
+// GetByGTIN returns the serialised products of a GTIN
+func (c *ProductContract) GetByGTIN(ctx contractapi.TransactionContextInterface, gtin string) ([]*Product, error) {
+	return c.core.GetProductsByGTIN(ctx, gtin)
+}
+
+// GetStatus returns the status of a product
+func (c *ProductContract) GetStatus(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	return c.core.GetProductStatus(ctx, id)
+}
+
+// VerifyAuthenticity returns true when the product is recorded on the ledger
+func (c *ProductContract) VerifyAuthenticity(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	return c.core.VerifyProductAuthenticity(ctx, id)
+}
+
+// SetCategory assigns a product to a cold chain category
+func (c *ProductContract) SetCategory(ctx contractapi.TransactionContextInterface, id string, manufacturer string, category string) error {
+	return c.core.SetProductCategory(ctx, id, manufacturer, category)
+}
+
+// History returns every recorded version of a product
+func (c *ProductContract) History(ctx contractapi.TransactionContextInterface, id string) ([]*ProductHistoryEntry, error) {
+	return c.core.TrackProductHistory(ctx, id)
+}
+
+// HistoryPage returns a page of the history of a product
+func (c *ProductContract) HistoryPage(ctx contractapi.TransactionContextInterface, id string, fromTime string, toTime string, pageSize int32, bookmark string, newestFirst bool) (*ProductHistoryPage, error) {
+	return c.core.GetProductHistoryPage(ctx, id, fromTime, toTime, pageSize, bookmark, newestFirst)
+}
+
+// ExportEPCIS returns the lifecycles of a batch of products as an EPCIS 2.0 document
+func (c *ProductContract) ExportEPCIS(ctx contractapi.TransactionContextInterface, ids []string) (*EPCISDocument, error) {
+	return c.core.ExportEPCISDocument(ctx, ids)
+}
+
+// GetRatings returns the ratings of every order of a product
+func (c *ProductContract) GetRatings(ctx contractapi.TransactionContextInterface, id string) ([]*Rating, error) {
+	return c.core.GetProductRatings(ctx, id)
+}
+
+// ExportEPCISForProduct returns the lifecycle of a single product as an EPCIS 2.0 document
+func (c *ProductContract) ExportEPCISForProduct(ctx contractapi.TransactionContextInterface, id string) (*EPCISDocument, error) {
+	return c.core.ExportProductEPCIS(ctx, id)
+}
+
+// ParseGS1Identifier splits a product ID into its GTIN and serial number
+func (c *ProductContract) ParseGS1Identifier(ctx contractapi.TransactionContextInterface, id string) (*GS1Identifier, error) {
+	return c.core.ParseGS1Identifier(ctx, id)
+}
+
+// ReadPriceTerms returns the private price terms of a product to the orgs they are shared with
+func (c *ProductContract) ReadPriceTerms(ctx contractapi.TransactionContextInterface, id string) (*PriceTerms, error) {
+	return c.core.ReadPriceTerms(ctx, id)
+}
+
+// VerifyPriceTerms checks price terms passed in the transient map against the hashes on the product
+func (c *ProductContract) VerifyPriceTerms(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	return c.core.VerifyPriceTerms(ctx, id)
+}
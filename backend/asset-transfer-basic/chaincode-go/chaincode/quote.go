@@ -0,0 +1,465 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	quoteObjectType        = "quote"
+	quotePartiesObjectType = "quoteParties"
+	transientQuoteOpen     = "quote_request"
+	transientQuoteOffer    = "quote_offer"
+
+	QuoteRequested = "Requested"
+	QuoteOffered   = "Offered"
+	QuoteCountered = "Countered"
+	QuoteAccepted  = "Accepted"
+	QuoteRejected  = "Rejected"
+)
+
+// Quote is a price negotiation between a consumer and the manufacturer of a product. It is kept in the terms
+// collection of the two orgs so that only they can see it; Log records every step for audit. Only the two
+// orgs are recorded publicly, under the quote ID, so that either can find the collection.
+type Quote struct {
+	QuoteID         string       `json:"QuoteID"`
+	ProductID       string       `json:"ProductID"`
+	Consumer        string       `json:"Consumer"` // consumer name the order is placed for, as in ProductOrder
+	ConsumerOrg     string       `json:"ConsumerOrg"`
+	ConsumerAccount string       `json:"ConsumerAccount"`
+	ManufacturerOrg string       `json:"ManufacturerOrg"`
+	Status          string       `json:"Status"`
+	Price           string       `json:"Price"`      // price of the latest offer or counter-offer
+	Terms           string       `json:"Terms"`      // terms of the latest offer or counter-offer
+	ValidUntil      string       `json:"ValidUntil"` // RFC 3339 expiry of the latest offer
+	LastPartyOrg    string       `json:"LastPartyOrg"`
+	AgreedTerms     *PriceTerms  `json:"AgreedTerms,omitempty" metadata:",optional"` // set on acceptance, hashed into the order's QuotePriceHash
+	Log             []QuoteEvent `json:"Log"`
+}
+
+// QuoteEvent is one step of a negotiation
+type QuoteEvent struct {
+	Action     string `json:"Action"`
+	PartyOrg   string `json:"PartyOrg"`
+	PartyID    string `json:"PartyID"`
+	Price      string `json:"Price"`
+	Terms      string `json:"Terms"`
+	ValidUntil string `json:"ValidUntil"`
+	Note       string `json:"Note"`
+	TxID       string `json:"TxID"`
+	Timestamp  string `json:"Timestamp"`
+}
+
+// quoteParties are the orgs negotiating a quote, recorded in the world state
+type quoteParties struct {
+	ManufacturerOrg string `json:"ManufacturerOrg"`
+	ConsumerOrg     string `json:"ConsumerOrg"`
+}
+
+// quoteRequest is the "quote_request" transient field of RequestQuote
+type quoteRequest struct {
+	ProductID string `json:"ProductID"`
+	Consumer  string `json:"Consumer"`
+	Note      string `json:"Note"`
+}
+
+// quoteOffer is the "quote_offer" transient field of OfferQuote and CounterQuote
+type quoteOffer struct {
+	Price      string `json:"Price"`
+	Terms      string `json:"Terms"`
+	ValidUntil string `json:"ValidUntil"`
+	Note       string `json:"Note"`
+}
+
+// RequestQuote opens a negotiation for a product. The product and consumer name are passed as
+// "quote_request" in the transient map so that they do not appear in the transaction arguments.
+func (s *SmartContract) RequestQuote(ctx contractapi.TransactionContextInterface, quoteID string) error {
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing, err := readQuoteParties(ctx, quoteID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return errAlreadyExists("quote", quoteID)
+	}
+
+	var request quoteRequest
+	if err := readTransientJSON(ctx, transientQuoteOpen, &request); err != nil {
+		return err
+	}
+	if request.ProductID == "" || request.Consumer == "" {
+		return errValidation("%s must include a ProductID and a Consumer", transientQuoteOpen)
+	}
+
+	product, err := s.ReadProduct(ctx, request.ProductID)
+	if err != nil {
+		return err
+	}
+	if !orderable(product) {
+		return errInvalidState("the product %s cannot be ordered in status %s", product.ID, product.Status)
+	}
+
+	quote := &Quote{
+		QuoteID:         quoteID,
+		ProductID:       product.ID,
+		Consumer:        request.Consumer,
+		ConsumerOrg:     clientOrg,
+		ConsumerAccount: clientID,
+		ManufacturerOrg: manufacturerOrg(product),
+		Status:          QuoteRequested,
+		Log:             []QuoteEvent{},
+	}
+	if err := appendQuoteEvent(ctx, quote, QuoteRequested, quoteOffer{Note: request.Note}); err != nil {
+		return err
+	}
+	if err := putQuoteParties(ctx, quoteID, &quoteParties{ManufacturerOrg: quote.ManufacturerOrg, ConsumerOrg: quote.ConsumerOrg}); err != nil {
+		return err
+	}
+
+	return putQuote(ctx, quote)
+}
+
+// OfferQuote lets the manufacturer answer a quote request, or a consumer counter-offer, with a price and
+// validity period passed as "quote_offer" in the transient map
+func (s *SmartContract) OfferQuote(ctx contractapi.TransactionContextInterface, quoteID string) error {
+	quote, clientOrg, err := openQuoteForParty(ctx, quoteID)
+	if err != nil {
+		return err
+	}
+	if clientOrg != quote.ManufacturerOrg {
+		return errForbidden("Access denied: Only the manufacturer org can offer a price")
+	}
+	if quote.LastPartyOrg == clientOrg {
+		return errInvalidState("the quote %s is waiting for the consumer", quoteID)
+	}
+
+	return s.placeQuoteOffer(ctx, quote, QuoteOffered)
+}
+
+// CounterQuote lets either party answer the other's latest offer with a new price, passed as
+// "quote_offer" in the transient map
+func (s *SmartContract) CounterQuote(ctx contractapi.TransactionContextInterface, quoteID string) error {
+	quote, clientOrg, err := openQuoteForParty(ctx, quoteID)
+	if err != nil {
+		return err
+	}
+	if quote.Status == QuoteRequested {
+		return errInvalidState("the quote %s has no offer to counter yet", quoteID)
+	}
+	if quote.LastPartyOrg == clientOrg {
+		return errInvalidState("the quote %s is waiting for the other party", quoteID)
+	}
+
+	return s.placeQuoteOffer(ctx, quote, QuoteCountered)
+}
+
+// AcceptQuote accepts the other party's latest offer while it is still valid and places the order for the
+// product at the agreed price. The agreed terms are kept on the quote and apply to this order only; the
+// product's own price is left as it is for later orders. A consumer accepting may pass "delivery_details" in
+// the transient map as with ProductOrder; without them the order has no delivery details.
+func (s *SmartContract) AcceptQuote(ctx contractapi.TransactionContextInterface, quoteID string, modifieddate string) error {
+	quote, clientOrg, err := openQuoteForParty(ctx, quoteID)
+	if err != nil {
+		return err
+	}
+	if quote.Status == QuoteRequested {
+		return errInvalidState("the quote %s has no offer to accept yet", quoteID)
+	}
+	if quote.LastPartyOrg == clientOrg {
+		return errForbidden("You cannot accept your own offer")
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	validUntil, err := time.Parse(time.RFC3339, quote.ValidUntil)
+	if err != nil {
+		return fmt.Errorf("failed to parse quote validity %s: %v", quote.ValidUntil, err)
+	}
+	if now.After(validUntil) {
+		return errInvalidState("the offer on quote %s expired at %s", quoteID, quote.ValidUntil)
+	}
+
+	product, err := s.ReadProduct(ctx, quote.ProductID)
+	if err != nil {
+		return err
+	}
+	if !orderable(product) {
+		return errInvalidState("the product %s cannot be ordered in status %s", product.ID, product.Status)
+	}
+
+	quote.Status = QuoteAccepted
+	if err := appendQuoteEvent(ctx, quote, QuoteAccepted, quoteOffer{Price: quote.Price, Terms: quote.Terms, ValidUntil: quote.ValidUntil}); err != nil {
+		return err
+	}
+	quoteJSON, err := json.Marshal(quote)
+	if err != nil {
+		return fmt.Errorf("failed to marshal quote JSON: %v", err)
+	}
+
+	// The salt is derived from the private negotiation, so nobody outside it can guess the agreed terms
+	salt := sha256.Sum256(quoteJSON)
+	quote.AgreedTerms = &PriceTerms{
+		ProductID: product.ID,
+		BuyerOrg:  quote.ConsumerOrg,
+		Price:     quote.Price,
+		Terms:     quote.Terms,
+		Salt:      hex.EncodeToString(salt[:]),
+	}
+	_, priceHash, err := marshalPriceTerms(quote.AgreedTerms)
+	if err != nil {
+		return err
+	}
+
+	product.QuoteID = quote.QuoteID
+	product.QuotePriceHash = priceHash
+	product.Status = "Pending Order Request"
+	product.Consumer = quote.Consumer
+	product.ConsumerAccount = quote.ConsumerAccount
+	product.ModifiedDate = modifieddate
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("error getting transient: %v", err)
+	}
+	if _, ok := transientMap[transientDeliveryDetails]; ok && clientOrg != quote.ConsumerOrg {
+		return errValidation("only the consumer can pass %s", transientDeliveryDetails)
+	}
+	// Details of an earlier order of the product must not carry over to this one
+	if err := storeDeliveryDetails(ctx, product); err != nil {
+		return err
+	}
+	if err := recordOrder(ctx, product); err != nil {
+		return err
+	}
+	if err := putQuote(ctx, quote); err != nil {
+		return err
+	}
+
+	return putProduct(ctx, product)
+}
+
+// RejectQuote closes a negotiation without an order. Either party can reject while it is open.
+func (s *SmartContract) RejectQuote(ctx contractapi.TransactionContextInterface, quoteID string) error {
+	quote, _, err := openQuoteForParty(ctx, quoteID)
+	if err != nil {
+		return err
+	}
+
+	quote.Status = QuoteRejected
+	if err := appendQuoteEvent(ctx, quote, QuoteRejected, quoteOffer{}); err != nil {
+		return err
+	}
+
+	return putQuote(ctx, quote)
+}
+
+// ReadQuote returns a quote and its negotiation log to the two orgs negotiating it
+func (s *SmartContract) ReadQuote(ctx contractapi.TransactionContextInterface, quoteID string) (*Quote, error) {
+	quote, err := readQuote(ctx, quoteID)
+	if err != nil {
+		return nil, err
+	}
+	if quote == nil {
+		return nil, errNotFound("quote", quoteID)
+	}
+	return quote, nil
+}
+
+func (s *SmartContract) placeQuoteOffer(ctx contractapi.TransactionContextInterface, quote *Quote, action string) error {
+	var offer quoteOffer
+	if err := readTransientJSON(ctx, transientQuoteOffer, &offer); err != nil {
+		return err
+	}
+	if _, err := parseAmount(offer.Price); err != nil {
+		return err
+	}
+	validUntil, err := time.Parse(time.RFC3339, offer.ValidUntil)
+	if err != nil {
+		return errValidation("%s ValidUntil must be an RFC 3339 time: %v", transientQuoteOffer, err)
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if !validUntil.After(now) {
+		return errValidation("%s ValidUntil must be in the future", transientQuoteOffer)
+	}
+
+	quote.Status = action
+	quote.Price = offer.Price
+	quote.Terms = offer.Terms
+	quote.ValidUntil = offer.ValidUntil
+	if err := appendQuoteEvent(ctx, quote, action, offer); err != nil {
+		return err
+	}
+
+	return putQuote(ctx, quote)
+}
+
+// openQuoteForParty loads a quote that is still being negotiated and checks that the caller is one of its
+// two parties
+func openQuoteForParty(ctx contractapi.TransactionContextInterface, quoteID string) (*Quote, string, error) {
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	quote, err := readQuote(ctx, quoteID)
+	if err != nil {
+		return nil, "", err
+	}
+	if quote == nil {
+		return nil, "", errNotFound("quote", quoteID)
+	}
+	if clientOrg != quote.ConsumerOrg && clientOrg != quote.ManufacturerOrg {
+		return nil, "", errForbidden("Access denied: Only the parties of a quote can negotiate it")
+	}
+	if quote.Status == QuoteAccepted || quote.Status == QuoteRejected {
+		return nil, "", errInvalidState("the quote %s is already %s", quoteID, quote.Status)
+	}
+
+	return quote, clientOrg, nil
+}
+
+// appendQuoteEvent records a negotiation step made by the invoking client
+func appendQuoteEvent(ctx contractapi.TransactionContextInterface, quote *Quote, action string, offer quoteOffer) error {
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	quote.LastPartyOrg = clientOrg
+	quote.Log = append(quote.Log, QuoteEvent{
+		Action:     action,
+		PartyOrg:   clientOrg,
+		PartyID:    clientID,
+		Price:      offer.Price,
+		Terms:      offer.Terms,
+		ValidUntil: offer.ValidUntil,
+		Note:       offer.Note,
+		TxID:       ctx.GetStub().GetTxID(),
+		Timestamp:  now.Format(time.RFC3339),
+	})
+
+	return nil
+}
+
+// readQuote returns nil when the quote does not exist
+func readQuote(ctx contractapi.TransactionContextInterface, quoteID string) (*Quote, error) {
+	parties, err := readQuoteParties(ctx, quoteID)
+	if err != nil {
+		return nil, err
+	}
+	if parties == nil {
+		return nil, nil
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(quoteObjectType, []string{quoteID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quote key: %v", err)
+	}
+	collection := termsCollection(parties.ManufacturerOrg, parties.ConsumerOrg)
+	quoteJSON, err := ctx.GetStub().GetPrivateData(collection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quote from %s: %v", collection, err)
+	}
+	if quoteJSON == nil {
+		return nil, nil
+	}
+
+	var quote Quote
+	if err := json.Unmarshal(quoteJSON, &quote); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quote JSON: %v", err)
+	}
+	return &quote, nil
+}
+
+func putQuote(ctx contractapi.TransactionContextInterface, quote *Quote) error {
+	key, err := ctx.GetStub().CreateCompositeKey(quoteObjectType, []string{quote.QuoteID})
+	if err != nil {
+		return fmt.Errorf("failed to create quote key: %v", err)
+	}
+	quoteJSON, err := json.Marshal(quote)
+	if err != nil {
+		return fmt.Errorf("failed to marshal quote JSON: %v", err)
+	}
+	collection := termsCollection(quote.ManufacturerOrg, quote.ConsumerOrg)
+	if err := ctx.GetStub().PutPrivateData(collection, key, quoteJSON); err != nil {
+		return fmt.Errorf("failed to put quote to %s: %v", collection, err)
+	}
+	return nil
+}
+
+// readQuoteParties returns nil when no quote was requested under the ID
+func readQuoteParties(ctx contractapi.TransactionContextInterface, quoteID string) (*quoteParties, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(quotePartiesObjectType, []string{quoteID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quote parties key: %v", err)
+	}
+	partiesJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if partiesJSON == nil {
+		return nil, nil
+	}
+
+	var parties quoteParties
+	if err := json.Unmarshal(partiesJSON, &parties); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quote parties JSON: %v", err)
+	}
+	return &parties, nil
+}
+
+func putQuoteParties(ctx contractapi.TransactionContextInterface, quoteID string, parties *quoteParties) error {
+	key, err := ctx.GetStub().CreateCompositeKey(quotePartiesObjectType, []string{quoteID})
+	if err != nil {
+		return fmt.Errorf("failed to create quote parties key: %v", err)
+	}
+	partiesJSON, err := json.Marshal(parties)
+	if err != nil {
+		return fmt.Errorf("failed to marshal quote parties JSON: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, partiesJSON); err != nil {
+		return fmt.Errorf("failed to put to world state. %v", err)
+	}
+	return nil
+}
+
+// readTransientJSON unmarshals a required field of the transient map
+func readTransientJSON(ctx contractapi.TransactionContextInterface, name string, value interface{}) error {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("error getting transient: %v", err)
+	}
+	valueJSON, ok := transientMap[name]
+	if !ok {
+		return errValidation("%s must be passed in the transient map", name)
+	}
+	if err := json.Unmarshal(valueJSON, value); err != nil {
+		return fmt.Errorf("failed to unmarshal %s JSON: %v", name, err)
+	}
+	return nil
+}
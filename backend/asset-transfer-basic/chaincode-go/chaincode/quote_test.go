@@ -0,0 +1,94 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testDeliveryDetails = `{"Address":"1 Main St","Salt":"0123456789abcdef","Location":{"Latitude":52.52,"Longitude":13.405}}`
+
+// negotiate has the consumer request a quote for p1 and the manufacturer offer it at price
+func (f *escrowFixture) negotiate(t *testing.T, quoteID string, price string) {
+	t.Helper()
+	f.submit(t, "request-"+quoteID, func() error {
+		f.stub.transient[transientQuoteOpen] = []byte(`{"ProductID":"p1","Consumer":"bob"}`)
+		return f.contract.RequestQuote(f.consumer, quoteID)
+	})
+	f.submit(t, "offer-"+quoteID, func() error {
+		f.stub.transient[transientQuoteOffer] = []byte(`{"Price":"` + price + `","Terms":"net 30","ValidUntil":"2030-01-01T00:00:00Z"}`)
+		return f.contract.OfferQuote(f.manufacturer, quoteID)
+	})
+}
+
+func TestAcceptQuoteBillsOnlyThatOrderAtTheAgreedPrice(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.negotiate(t, "q1", "8.00")
+	f.submit(t, "accept-quote", func() error { return f.contract.AcceptQuote(f.consumer, "q1", "x") })
+
+	// The negotiation is only in the terms collection of the two orgs
+	require.Len(t, f.stub.privateData[termsCollection("Org1MSP", "Org2MSP")], 1)
+	require.Empty(t, f.stub.privateData[tradeCollection])
+
+	product := f.product(t)
+	require.Equal(t, "q1", product.QuoteID)
+	require.Equal(t, "10.00", product.Price)
+	require.Empty(t, product.PriceHash)
+
+	require.NoError(t, f.accept("accept"))
+	require.Equal(t, int64(800), f.escrow(t).Amount)
+	invoice, err := f.contract.ReadInvoice(f.manufacturer, f.product(t).InvoiceNumber)
+	require.NoError(t, err)
+	require.Equal(t, "8.00", invoice.Subtotal)
+
+	// The agreed terms, salt included, prove the price of the order
+	quote, err := f.contract.ReadQuote(f.consumer, "q1")
+	require.NoError(t, err)
+	termsJSON, err := json.Marshal(quote.AgreedTerms)
+	require.NoError(t, err)
+	f.stub.begin("verify")
+	f.stub.transient[transientPriceTerms] = termsJSON
+	verified, err := f.contract.VerifyPriceTerms(f.consumer, "p1")
+	require.NoError(t, err)
+	require.True(t, verified)
+
+	// The next buyer orders at the product's own price
+	f.submit(t, "reject", func() error { return f.contract.ProductReject(f.manufacturer, "p1", "maker", "x") })
+	f.order(t, "reorder")
+	require.Empty(t, f.product(t).QuoteID)
+	require.NoError(t, f.accept("reaccept"))
+	require.Equal(t, int64(1000), f.escrow(t).Amount)
+}
+
+func TestAcceptQuoteDropsDeliveryDetailsOfAnEarlierOrder(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.submit(t, "order", func() error {
+		f.stub.transient[transientDeliveryDetails] = []byte(testDeliveryDetails)
+		return f.contract.ProductOrder(f.consumer, "p1", "alice", "x")
+	})
+	require.NotEmpty(t, f.product(t).DeliveryDetailsHash)
+	f.submit(t, "cancel", func() error { return f.contract.CancelOrder(f.consumer, "p1", "x") })
+
+	f.negotiate(t, "q1", "8.00")
+	f.submit(t, "accept-quote", func() error { return f.contract.AcceptQuote(f.consumer, "q1", "x") })
+
+	require.Empty(t, f.product(t).DeliveryDetailsHash)
+	destination, err := readDeliveryDestination(f.manufacturer, "p1")
+	require.NoError(t, err)
+	require.Nil(t, destination)
+}
+
+func TestAcceptQuoteStoresDeliveryDetailsOfTheConsumer(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.negotiate(t, "q1", "8.00")
+	f.submit(t, "accept-quote", func() error {
+		f.stub.transient[transientDeliveryDetails] = []byte(testDeliveryDetails)
+		return f.contract.AcceptQuote(f.consumer, "q1", "x")
+	})
+
+	require.NotEmpty(t, f.product(t).DeliveryDetailsHash)
+	destination, err := readDeliveryDestination(f.manufacturer, "p1")
+	require.NoError(t, err)
+	require.Equal(t, &GeoPoint{Latitude: 52.52, Longitude: 13.405}, destination)
+}
@@ -0,0 +1,186 @@
+package chaincode
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// ratingObjectType keys ratings by [manufacturer, productID, invoiceNumber], so that each delivered order
+	// can be rated once and a manufacturer's reputation is read from its own ratings only
+	ratingObjectType = "rating"
+
+	minRatingScore = 1
+	maxRatingScore = 5
+)
+
+// Rating is a consumer's verdict on a delivered order. The comment itself stays off the ledger; only its
+// hex SHA-256 hash is recorded so the consumer can later prove what they wrote.
+type Rating struct {
+	ProductID         string `json:"ProductID"`
+	InvoiceNumber     string `json:"InvoiceNumber"` // identifies the rated order of the product
+	Manufacturer      string `json:"Manufacturer"`
+	Consumer          string `json:"Consumer"`
+	ProductScore      int    `json:"ProductScore"`
+	ManufacturerScore int    `json:"ManufacturerScore"`
+	CommentHash       string `json:"CommentHash"`
+	RatedAt           string `json:"RatedAt"`
+	TxID              string `json:"TxID"`
+}
+
+// Reputation is the aggregate of the ratings a manufacturer has received. Averages are rounded to two
+// decimals and are zero while there are no ratings.
+type Reputation struct {
+	Manufacturer             string  `json:"Manufacturer"`
+	Ratings                  int     `json:"Ratings"`
+	AverageManufacturerScore float64 `json:"AverageManufacturerScore"`
+	AverageProductScore      float64 `json:"AverageProductScore"`
+	ScoreCounts              []int   `json:"ScoreCounts"` // number of manufacturer scores of 1 to 5
+}
+
+// RateOrder lets the consumer who placed the current order of a delivered product rate the product and its
+// manufacturer, once per order
+func (s *SmartContract) RateOrder(ctx contractapi.TransactionContextInterface, id string, productScore int, manufacturerScore int, commentHash string) error {
+	product, err := s.ReadProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	// Orders placed before consumer accounts were recorded cannot be tied to the rating consumer
+	if product.ConsumerAccount == "" {
+		return errInvalidState("the order of product %s is not linked to a consumer account and cannot be rated", id)
+	}
+	if err := checkOrderingConsumer(ctx, product); err != nil {
+		return err
+	}
+	if product.Status != "Delivered" {
+		return errInvalidState("the product %s has not been delivered yet", id)
+	}
+	if product.InvoiceNumber == "" {
+		return errInvalidState("the order of product %s has no invoice and cannot be rated", id)
+	}
+
+	for _, score := range []int{productScore, manufacturerScore} {
+		if score < minRatingScore || score > maxRatingScore {
+			return errValidation("scores must be between %d and %d", minRatingScore, maxRatingScore)
+		}
+	}
+	if commentHash != "" {
+		if decoded, err := hex.DecodeString(commentHash); err != nil || len(decoded) != 32 {
+			return errValidation("the comment hash must be a hex SHA-256 hash")
+		}
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(ratingObjectType, []string{product.Manufacturer, product.ID, product.InvoiceNumber})
+	if err != nil {
+		return fmt.Errorf("failed to create rating key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return errInvalidState("the order %s of product %s has already been rated", product.InvoiceNumber, id)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	rating := Rating{
+		ProductID:         product.ID,
+		InvoiceNumber:     product.InvoiceNumber,
+		Manufacturer:      product.Manufacturer,
+		Consumer:          product.Consumer,
+		ProductScore:      productScore,
+		ManufacturerScore: manufacturerScore,
+		CommentHash:       commentHash,
+		RatedAt:           now.Format(time.RFC3339),
+		TxID:              ctx.GetStub().GetTxID(),
+	}
+	ratingJSON, err := json.Marshal(rating)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rating JSON: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, ratingJSON); err != nil {
+		return fmt.Errorf("failed to put rating to world state: %v", err)
+	}
+
+	return nil
+}
+
+// GetManufacturerRatings returns the ratings a manufacturer has received
+func (s *SmartContract) GetManufacturerRatings(ctx contractapi.TransactionContextInterface, manufacturer string) ([]*Rating, error) {
+	return readRatings(ctx, []string{manufacturer})
+}
+
+// GetProductRatings returns the ratings of every order of a product
+func (s *SmartContract) GetProductRatings(ctx contractapi.TransactionContextInterface, id string) ([]*Rating, error) {
+	product, err := s.ReadProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return readRatings(ctx, []string{product.Manufacturer, product.ID})
+}
+
+// GetManufacturerReputation returns the aggregate rating of a manufacturer, open to every org so that
+// buyers can judge suppliers before ordering
+func (s *SmartContract) GetManufacturerReputation(ctx contractapi.TransactionContextInterface, manufacturer string) (*Reputation, error) {
+	ratings, err := readRatings(ctx, []string{manufacturer})
+	if err != nil {
+		return nil, err
+	}
+
+	reputation := &Reputation{
+		Manufacturer: manufacturer,
+		Ratings:      len(ratings),
+		ScoreCounts:  make([]int, maxRatingScore),
+	}
+	if len(ratings) == 0 {
+		return reputation, nil
+	}
+
+	var manufacturerTotal, productTotal int
+	for _, rating := range ratings {
+		manufacturerTotal += rating.ManufacturerScore
+		productTotal += rating.ProductScore
+		reputation.ScoreCounts[rating.ManufacturerScore-minRatingScore]++
+	}
+	reputation.AverageManufacturerScore = roundScore(float64(manufacturerTotal) / float64(len(ratings)))
+	reputation.AverageProductScore = roundScore(float64(productTotal) / float64(len(ratings)))
+
+	return reputation, nil
+}
+
+func readRatings(ctx contractapi.TransactionContextInterface, attributes []string) ([]*Rating, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(ratingObjectType, attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state by partial composite key: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	ratings := []*Rating{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over query results: %v", err)
+		}
+
+		var rating Rating
+		if err := json.Unmarshal(queryResponse.Value, &rating); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal rating JSON: %v", err)
+		}
+		ratings = append(ratings, &rating)
+	}
+
+	return ratings, nil
+}
+
+func roundScore(score float64) float64 {
+	return math.Round(score*100) / 100
+}
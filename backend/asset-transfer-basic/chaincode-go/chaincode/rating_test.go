@@ -0,0 +1,45 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateOrderOnce(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.order(t, "order")
+	require.NoError(t, f.accept("accept"))
+	f.fulfil(t)
+
+	f.submit(t, "rate", func() error { return f.contract.RateOrder(f.consumer, "p1", 4, 5, "") })
+	err := f.try("rate-again", func() error { return f.contract.RateOrder(f.consumer, "p1", 1, 1, "") })
+	RequireContractError(t, err, CodeInvalidState)
+
+	reputation, err := f.contract.GetManufacturerReputation(f.consumer, "maker")
+	require.NoError(t, err)
+	require.Equal(t, 1, reputation.Ratings)
+	require.Equal(t, 5.0, reputation.AverageManufacturerScore)
+	require.Equal(t, 4.0, reputation.AverageProductScore)
+	require.Equal(t, []int{0, 0, 0, 0, 1}, reputation.ScoreCounts)
+}
+
+func TestRateOrderOnlyByOrderingConsumer(t *testing.T) {
+	f := newEscrowFixture(t)
+	f.order(t, "order")
+	require.NoError(t, f.accept("accept"))
+
+	// An order that has not been delivered cannot be rated yet
+	err := f.try("rate-early", func() error { return f.contract.RateOrder(f.consumer, "p1", 5, 5, "") })
+	RequireContractError(t, err, CodeInvalidState)
+	f.fulfil(t)
+
+	// Another client of the consumer org did not place the order
+	other := newClientContext(f.stub, "Org2MSP", "other-client")
+	err = f.try("rate-other", func() error { return f.contract.RateOrder(other, "p1", 1, 1, "") })
+	RequireContractError(t, err, CodeForbidden)
+
+	ratings, err := f.contract.GetProductRatings(f.consumer, "p1")
+	require.NoError(t, err)
+	require.Empty(t, ratings)
+}
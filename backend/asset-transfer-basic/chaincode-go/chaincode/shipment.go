@@ -0,0 +1,251 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const shipmentObjectType = "shipment"
+
+// Shipment is a consignment of one or more ordered products handed to a carrier
+type Shipment struct {
+	ShipmentID          string              `json:"ShipmentID"`
+	ProductIDs          []string            `json:"ProductIDs"`
+	Carrier             string              `json:"Carrier"`
+	CarrierOrg          string              `json:"CarrierOrg"` // MSP ID allowed to add waypoints besides the shipper
+	TrackingNumber      string              `json:"TrackingNumber"`
+	Origin              string              `json:"Origin"`
+	OriginWarehouseID   string              `json:"OriginWarehouseID"` // set when the shipment was picked from a warehouse
+	OriginLocationID    string              `json:"OriginLocationID"`
+	Destination         string              `json:"Destination"`
+	DestinationLocation *GeoPoint           `json:"DestinationLocation,omitempty" metadata:",optional"` // checked against deliveries whose order has no destination
+	ShipperOrg          string              `json:"ShipperOrg"`
+	ShippedAt           string              `json:"ShippedAt"`
+	LastLocation        string              `json:"LastLocation"` // location of the latest waypoint, Origin until the first one
+	Waypoints           []*ShipmentWaypoint `json:"Waypoints"`
+}
+
+// ShipmentWaypoint is a tracking update reported by the carrier
+type ShipmentWaypoint struct {
+	Location      string `json:"Location"`
+	Timestamp     string `json:"Timestamp"`  // when the carrier saw the shipment there, RFC 3339
+	RecordedAt    string `json:"RecordedAt"` // transaction time the update reached the ledger
+	Note          string `json:"Note"`
+	RecordedByOrg string `json:"RecordedByOrg"`
+	TxID          string `json:"TxID"`
+}
+
+// ShipProducts ships several ordered products together in one shipment and returns the shipment ID.
+// The carrier org may add waypoints to the shipment; it defaults to the shipper's org.
+func (s *SmartContract) ShipProducts(ctx contractapi.TransactionContextInterface, productIDs []string, carrier string, carrierOrg string, trackingNumber string, origin string, destination string, modifieddate string) (string, error) {
+	shipment := &Shipment{
+		Carrier:        carrier,
+		CarrierOrg:     carrierOrg,
+		TrackingNumber: trackingNumber,
+		Origin:         origin,
+		Destination:    destination,
+	}
+	if err := s.shipProducts(ctx, productIDs, shipment, modifieddate); err != nil {
+		return "", err
+	}
+
+	return shipment.ShipmentID, nil
+}
+
+// shipProducts marks ordered products as shipped together in a new shipment
+func (s *SmartContract) shipProducts(ctx contractapi.TransactionContextInterface, productIDs []string, shipment *Shipment, modifieddate string) error {
+	if len(productIDs) == 0 {
+		return errValidation("a shipment must contain at least one product")
+	}
+
+	products := make([]*Product, 0, len(productIDs))
+	seen := make(map[string]bool)
+	for _, id := range productIDs {
+		if seen[id] {
+			return errValidation("the product %s is listed more than once", id)
+		}
+		seen[id] = true
+
+		product, err := s.ReadProduct(ctx, id)
+		if err != nil {
+			return err
+		}
+		if product.Status != "Accepted" {
+			return errInvalidState("the product %s has no accepted order to ship, its status is %s", id, product.Status)
+		}
+		products = append(products, product)
+	}
+
+	if err := createShipment(ctx, shipment, products); err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		product.Status = "Shipped"
+		product.ModifiedDate = modifieddate
+		if err := putProduct(ctx, product); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddShipmentWaypoint records where a shipment is. Timestamp is when the carrier saw it there, in RFC 3339;
+// it defaults to the transaction time.
+func (s *SmartContract) AddShipmentWaypoint(ctx contractapi.TransactionContextInterface, shipmentID string, location string, timestamp string, note string) error {
+	shipment, err := s.ReadShipment(ctx, shipmentID)
+	if err != nil {
+		return err
+	}
+
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+	if clientOrg != shipment.CarrierOrg && clientOrg != shipment.ShipperOrg {
+		return errForbidden("Access denied: Only the carrier or shipper org can update a shipment")
+	}
+	if location == "" {
+		return errValidation("the waypoint location must not be empty")
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	seenAt := now
+	if timestamp != "" {
+		if seenAt, err = time.Parse(time.RFC3339, timestamp); err != nil {
+			return errValidation("the waypoint timestamp must be written in RFC 3339: %v", err)
+		}
+	}
+
+	shipment.Waypoints = append(shipment.Waypoints, &ShipmentWaypoint{
+		Location:      location,
+		Timestamp:     seenAt.UTC().Format(time.RFC3339),
+		RecordedAt:    now.Format(time.RFC3339),
+		Note:          note,
+		RecordedByOrg: clientOrg,
+		TxID:          ctx.GetStub().GetTxID(),
+	})
+	shipment.LastLocation = location
+
+	return putShipment(ctx, shipment)
+}
+
+// SetShipmentDestination sets the coordinates of a shipment's destination, which ProductDeliverWithLocation
+// checks delivery locations against when the orders of its products carry no location of their own. It can
+// only be set while every product of the shipment is in transit and none of their orders has a destination.
+func (s *SmartContract) SetShipmentDestination(ctx contractapi.TransactionContextInterface, shipmentID string, latitude float64, longitude float64) error {
+	shipment, err := s.ReadShipment(ctx, shipmentID)
+	if err != nil {
+		return err
+	}
+
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+	if clientOrg != shipment.ShipperOrg {
+		return errForbidden("Access denied: Only the shipper org can set the destination of a shipment")
+	}
+
+	// The shipper also records the delivery, so it must not move the destination the delivery is proven against
+	for _, id := range shipment.ProductIDs {
+		product, err := s.ReadProduct(ctx, id)
+		if err != nil {
+			return err
+		}
+		if product.ShipmentID != shipmentID || product.Status != "Shipped" {
+			return errInvalidState("the product %s of shipment %s is no longer in transit", id, shipmentID)
+		}
+		orderDestination, err := orderDestination(ctx, product)
+		if err != nil {
+			return err
+		}
+		if orderDestination != nil {
+			return errInvalidState("the order of product %s already has a destination", id)
+		}
+	}
+
+	destination := &GeoPoint{Latitude: latitude, Longitude: longitude}
+	if err := destination.validate(); err != nil {
+		return err
+	}
+	shipment.DestinationLocation = destination
+
+	return putShipment(ctx, shipment)
+}
+
+// ReadShipment returns a shipment and its waypoints
+func (s *SmartContract) ReadShipment(ctx contractapi.TransactionContextInterface, shipmentID string) (*Shipment, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(shipmentObjectType, []string{shipmentID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shipment key: %v", err)
+	}
+	shipmentJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if shipmentJSON == nil {
+		return nil, errNotFound("shipment", shipmentID)
+	}
+
+	var shipment Shipment
+	if err := json.Unmarshal(shipmentJSON, &shipment); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal shipment JSON: %v", err)
+	}
+	if shipment.Waypoints == nil {
+		shipment.Waypoints = []*ShipmentWaypoint{}
+	}
+	return &shipment, nil
+}
+
+// createShipment numbers and stores a new shipment of products shipped by the invoking org and links each
+// product to it. The products still have to be written by the caller.
+func createShipment(ctx contractapi.TransactionContextInterface, shipment *Shipment, products []*Product) error {
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	shipment.ShipmentID = documentNumber(ctx, "SHP", now)
+	shipment.ShipperOrg = clientOrg
+	if shipment.CarrierOrg == "" {
+		shipment.CarrierOrg = clientOrg
+	}
+	shipment.ShippedAt = now.Format(time.RFC3339)
+	shipment.LastLocation = shipment.Origin
+	shipment.Waypoints = []*ShipmentWaypoint{}
+
+	shipment.ProductIDs = make([]string, 0, len(products))
+	for _, product := range products {
+		shipment.ProductIDs = append(shipment.ProductIDs, product.ID)
+		product.ShipmentID = shipment.ShipmentID
+	}
+
+	return putShipment(ctx, shipment)
+}
+
+func putShipment(ctx contractapi.TransactionContextInterface, shipment *Shipment) error {
+	key, err := ctx.GetStub().CreateCompositeKey(shipmentObjectType, []string{shipment.ShipmentID})
+	if err != nil {
+		return fmt.Errorf("failed to create shipment key: %v", err)
+	}
+	shipmentJSON, err := json.Marshal(shipment)
+	if err != nil {
+		return fmt.Errorf("failed to marshal shipment JSON: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, shipmentJSON); err != nil {
+		return fmt.Errorf("failed to put to world state. %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ShipmentContract groups shipping, tracking and cold chain transactions under the "shipment:" prefix
+type ShipmentContract struct {
+	contractapi.Contract
+	core SmartContract
+}
+
+// NewShipmentContract returns the shipment contract, invoked with the "shipment:" prefix
+func NewShipmentContract() *ShipmentContract {
+	return &ShipmentContract{Contract: newContract("shipment")}
+}
+
+// ShipProduct ships a single accepted order
+func (c *ShipmentContract) ShipProduct(ctx contractapi.TransactionContextInterface, id string, modifieddate string) error {
+	return c.core.ProductShip(ctx, id, modifieddate)
+}
+
+// ShipBatch ships every listed product in its own shipment, or none of them
+func (c *ShipmentContract) ShipBatch(ctx contractapi.TransactionContextInterface, ids []string, modifieddate string) error {
+	return c.core.ProductShipBatch(ctx, ids, modifieddate)
+}
+
+// Create ships accepted orders together and returns the new shipment ID
+func (c *ShipmentContract) Create(ctx contractapi.TransactionContextInterface, productIDs []string, carrier string, carrierOrg string, trackingNumber string, origin string, destination string, modifieddate string) (string, error) {
+	return c.core.ShipProducts(ctx, productIDs, carrier, carrierOrg, trackingNumber, origin, destination, modifieddate)
+}
+
+// CreateFromWarehouse picks accepted orders from a warehouse location and ships them together
+func (c *ShipmentContract) CreateFromWarehouse(ctx contractapi.TransactionContextInterface, warehouseID string, locationID string, productIDs []string, carrier string, carrierOrg string, trackingNumber string, destination string, modifieddate string) (string, error) {
+	return c.core.ShipProductsFromWarehouse(ctx, warehouseID, locationID, productIDs, carrier, carrierOrg, trackingNumber, destination, modifieddate)
+}
+
+// Read returns a shipment
+func (c *ShipmentContract) Read(ctx contractapi.TransactionContextInterface, shipmentID string) (*Shipment, error) {
+	return c.core.ReadShipment(ctx, shipmentID)
+}
+
+// AddWaypoint records where a shipment has been seen
+func (c *ShipmentContract) AddWaypoint(ctx contractapi.TransactionContextInterface, shipmentID string, location string, timestamp string, note string) error {
+	return c.core.AddShipmentWaypoint(ctx, shipmentID, location, timestamp, note)
+}
+
+// SetDestination records the coordinates a shipment is delivered to
+func (c *ShipmentContract) SetDestination(ctx contractapi.TransactionContextInterface, shipmentID string, latitude float64, longitude float64) error {
+	return c.core.SetShipmentDestination(ctx, shipmentID, latitude, longitude)
+}
+
+// SubmitSensorReadings records a batch of data logger readings taken during a shipment
+func (c *ShipmentContract) SubmitSensorReadings(ctx contractapi.TransactionContextInterface, shipmentID string, loggerID string, readings []SensorReading) (*SensorBatch, error) {
+	return c.core.SubmitSensorReadings(ctx, shipmentID, loggerID, readings)
+}
+
+// GetSensorBatches returns the sensor batches recorded for a shipment
+func (c *ShipmentContract) GetSensorBatches(ctx contractapi.TransactionContextInterface, shipmentID string) ([]*SensorBatch, error) {
+	return c.core.GetShipmentSensorBatches(ctx, shipmentID)
+}
@@ -0,0 +1,236 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	slaTermsConfig = "slaTerms"
+
+	defaultShipWithinDays    = 2
+	defaultDeliverWithinDays = 7
+
+	// Deadlines reported by GetOverdueOrders
+	MissedShipBy    = "ShipByDate"
+	MissedDeliverBy = "DeliverByDate"
+)
+
+// SLATerms are the default number of days after acceptance within which an order must ship and arrive
+type SLATerms struct {
+	ShipWithinDays    int `json:"ShipWithinDays"`
+	DeliverWithinDays int `json:"DeliverWithinDays"`
+}
+
+// OverdueOrder is an accepted order that has missed its promised ship-by or deliver-by date
+type OverdueOrder struct {
+	Product      *Product `json:"Product"`
+	Missed       string   `json:"Missed"` // MissedShipBy or MissedDeliverBy
+	DueDate      string   `json:"DueDate"`
+	HoursOverdue int64    `json:"HoursOverdue"`
+}
+
+// DeliveryPerformance summarises how many of a manufacturer's deliveries arrived by their promised date.
+// Deliveries of orders accepted without a deliver-by date are counted as Untracked and left out of the rate.
+type DeliveryPerformance struct {
+	Manufacturer string  `json:"Manufacturer"`
+	Delivered    int     `json:"Delivered"`
+	OnTime       int     `json:"OnTime"`
+	Late         int     `json:"Late"`
+	Untracked    int     `json:"Untracked"`
+	OnTimeRate   float64 `json:"OnTimeRate"` // OnTime / (OnTime + Late), 0 when nothing was tracked
+}
+
+// SetSLATerms sets the default ship-by and deliver-by periods promised when an order is accepted
+func (s *SmartContract) SetSLATerms(ctx contractapi.TransactionContextInterface, shipWithinDays int, deliverWithinDays int) error {
+	if shipWithinDays < 0 || deliverWithinDays < shipWithinDays {
+		return errValidation("the ship-by period must not be negative or longer than the deliver-by period")
+	}
+
+	return writeConfig(ctx, slaTermsConfig, &SLATerms{
+		ShipWithinDays:    shipWithinDays,
+		DeliverWithinDays: deliverWithinDays,
+	})
+}
+
+// GetSLATerms returns the default ship-by and deliver-by periods
+func (s *SmartContract) GetSLATerms(ctx contractapi.TransactionContextInterface) (*SLATerms, error) {
+	return slaTerms(ctx)
+}
+
+// ProductAcceptWithSLA accepts an order like ProductAccept but promises the given ship-by and deliver-by
+// dates, written in RFC 3339, instead of the configured defaults
+func (s *SmartContract) ProductAcceptWithSLA(ctx contractapi.TransactionContextInterface, id string, manufacturer string, modifieddate string, shipByDate string, deliverByDate string) error {
+	if shipByDate == "" || deliverByDate == "" {
+		return errValidation("the ship-by and deliver-by dates must not be empty")
+	}
+	return s.acceptOrder(ctx, id, manufacturer, modifieddate, shipByDate, deliverByDate)
+}
+
+// GetOverdueOrders returns the orders that are late compared to the current transaction time: accepted
+// orders past their ship-by date and shipped orders past their deliver-by date. An empty manufacturer
+// returns the overdue orders of every manufacturer.
+func (s *SmartContract) GetOverdueOrders(ctx contractapi.TransactionContextInterface, manufacturer string) ([]*OverdueOrder, error) {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state by range: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var overdue []*OverdueOrder
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over query results: %v", err)
+		}
+
+		var product Product
+		if err := json.Unmarshal(queryResponse.Value, &product); err != nil {
+			return nil, fmt.Errorf("error unmarshalling product JSON: %v", err)
+		}
+		if manufacturer != "" && product.Manufacturer != manufacturer {
+			continue
+		}
+
+		var missed, dueDate string
+		switch product.Status {
+		case "Accepted":
+			missed, dueDate = MissedShipBy, product.ShipByDate
+		case "Shipped":
+			missed, dueDate = MissedDeliverBy, product.DeliverByDate
+		}
+		if dueDate == "" {
+			continue
+		}
+		due, err := time.Parse(time.RFC3339, dueDate)
+		if err != nil {
+			return nil, fmt.Errorf("the product %s has an invalid %s: %v", product.ID, missed, err)
+		}
+		// An order that has not shipped yet is also late for delivery once that date passes
+		if product.Status == "Accepted" && product.DeliverByDate != "" {
+			if deliverBy, err := time.Parse(time.RFC3339, product.DeliverByDate); err == nil && now.After(deliverBy) {
+				missed, due = MissedDeliverBy, deliverBy
+			}
+		}
+		if !now.After(due) {
+			continue
+		}
+
+		overdue = append(overdue, &OverdueOrder{
+			Product:      &product,
+			Missed:       missed,
+			DueDate:      due.Format(time.RFC3339),
+			HoursOverdue: int64(now.Sub(due) / time.Hour),
+		})
+	}
+
+	return overdue, nil
+}
+
+// GetDeliveryPerformance computes a manufacturer's on-time delivery rate from the history of its products.
+// Every delivery is compared with the deliver-by date promised for that order, including past orders of
+// products that have since been ordered again.
+func (s *SmartContract) GetDeliveryPerformance(ctx contractapi.TransactionContextInterface, manufacturer string) (*DeliveryPerformance, error) {
+	products, err := s.GetProductsByManufacturer(ctx, manufacturer)
+	if err != nil {
+		return nil, err
+	}
+
+	performance := &DeliveryPerformance{Manufacturer: manufacturer}
+	for _, product := range products {
+		history, err := readProductHistory(ctx, product.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		previousStatus := ""
+		for _, entry := range history {
+			if entry.Product == nil {
+				previousStatus = ""
+				continue
+			}
+			delivered := entry.Product.Status == "Delivered" && previousStatus != "Delivered"
+			previousStatus = entry.Product.Status
+			if !delivered {
+				continue
+			}
+
+			performance.Delivered++
+			if entry.Product.DeliverByDate == "" {
+				performance.Untracked++
+				continue
+			}
+			deliverBy, err := time.Parse(time.RFC3339, entry.Product.DeliverByDate)
+			if err != nil {
+				return nil, fmt.Errorf("the product %s has an invalid %s: %v", product.ID, MissedDeliverBy, err)
+			}
+			deliveredAt, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse history timestamp %s: %v", entry.Timestamp, err)
+			}
+			if deliveredAt.After(deliverBy) {
+				performance.Late++
+			} else {
+				performance.OnTime++
+			}
+		}
+	}
+
+	if tracked := performance.OnTime + performance.Late; tracked > 0 {
+		performance.OnTimeRate = float64(performance.OnTime) / float64(tracked)
+	}
+
+	return performance, nil
+}
+
+// promiseDates sets the ship-by and deliver-by dates of an order being accepted. Empty dates default to the
+// configured SLA terms counted from the transaction time.
+func promiseDates(ctx contractapi.TransactionContextInterface, product *Product, shipByDate string, deliverByDate string) error {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	terms, err := slaTerms(ctx)
+	if err != nil {
+		return err
+	}
+
+	shipBy := now.AddDate(0, 0, terms.ShipWithinDays)
+	if shipByDate != "" {
+		if shipBy, err = time.Parse(time.RFC3339, shipByDate); err != nil {
+			return errValidation("the ship-by date must be written in RFC 3339: %v", err)
+		}
+	}
+	deliverBy := now.AddDate(0, 0, terms.DeliverWithinDays)
+	if deliverByDate != "" {
+		if deliverBy, err = time.Parse(time.RFC3339, deliverByDate); err != nil {
+			return errValidation("the deliver-by date must be written in RFC 3339: %v", err)
+		}
+	}
+	if shipBy.Before(now) {
+		return errValidation("the ship-by date must not be in the past")
+	}
+	if deliverBy.Before(shipBy) {
+		return errValidation("the deliver-by date must not be before the ship-by date")
+	}
+
+	product.ShipByDate = shipBy.UTC().Format(time.RFC3339)
+	product.DeliverByDate = deliverBy.UTC().Format(time.RFC3339)
+	return nil
+}
+
+func slaTerms(ctx contractapi.TransactionContextInterface) (*SLATerms, error) {
+	terms := &SLATerms{ShipWithinDays: defaultShipWithinDays, DeliverWithinDays: defaultDeliverWithinDays}
+	if _, err := readConfig(ctx, slaTermsConfig, terms); err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
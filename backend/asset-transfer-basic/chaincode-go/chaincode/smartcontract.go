@@ -2,8 +2,8 @@ package chaincode
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -13,6 +13,11 @@ type SmartContract struct {
 	contractapi.Contract
 }
 
+// NewSmartContract returns the default contract, invoked without a prefix
+func NewSmartContract() *SmartContract {
+	return &SmartContract{Contract: newContract("")}
+}
+
 // Asset describes basic details of what makes up a simple asset
 // Insert struct field in alphabetic order => to achieve determinism across languages
 // golang keeps the order when marshal to json but doesn't order automatically
@@ -21,33 +26,45 @@ type Product struct {
 	Name          string `json:"Name"`
 	Description   string `json:"Description"`
 	Price   	  string `json:"Price"`
+	PriceHash     string `json:"PriceHash"` // hash of the private PriceTerms when the price is kept in a terms collection
+	PriceBuyerOrg string `json:"PriceBuyerOrg"` // consumer org the private price is offered to
+	QuoteID       string `json:"QuoteID"`   // quote the current order was placed from; the order is billed at its agreed price
+	QuotePriceHash string `json:"QuotePriceHash"` // hash of the PriceTerms agreed in that quote
 	Status        string `json:"Status"` // Status can be "Pending", "Accepted", "Shipped", "Delivered", etc.
 	Manufacturer  string `json:"Manufacturer"`
+	ManufacturerOrg string `json:"ManufacturerOrg"` // MSP ID of the org that created the product
 	Consumer      string `json:"Consumer"`
+	ConsumerAccount string `json:"ConsumerAccount"` // client identity that placed the current order, charged by the escrow
 	CreatedDate   string `json:"CreatedDate"`
 	ModifiedDate  string `json:"ModifiedDate"`
 	DeliveredDate string `json:"DeliveredDate"`
+	ConfirmedDate string `json:"ConfirmedDate"` // set when the consumer confirms receipt
+	DeliveryDetailsHash string `json:"DeliveryDetailsHash"` // salted hash of the consumer's private DeliveryDetails
+	OrderedDate   string `json:"OrderedDate"`   // when the current order was placed, RFC 3339
+	InvoiceNumber string `json:"InvoiceNumber"` // invoice issued when the current order was accepted
+	ShipByDate    string `json:"ShipByDate"`    // promised at acceptance, RFC 3339
+	DeliverByDate string `json:"DeliverByDate"` // promised at acceptance, RFC 3339
+	CreditNoteNumber string `json:"CreditNoteNumber"` // credit note issued when the current order was delivered late
+	ShipmentID    string `json:"ShipmentID"`    // shipment the current order was shipped in
+	Category      string `json:"Category"`      // selects the ConditionRange for cold-chain monitoring
+	Flags         []string `json:"Flags,omitempty" metadata:",optional"` // compliance issues such as "Temperature Excursion"
+	ProofOfDelivery *ProofOfDelivery `json:"ProofOfDelivery,omitempty" metadata:",optional"` // where the current order was delivered
 	OwnerType 	  string `json:"OwnerType"`
+	ModifiedBy    string `json:"ModifiedBy"`    // client identity that wrote this version of the product
+	ModifiedByOrg string `json:"ModifiedByOrg"` // MSP ID of that client
 }
 
 // InitLedger adds a base set of assets to the ledger
 func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
-	// Check the invoking client's organization
-	clientOrg, err := getClientOrganization(ctx)
-	if err != nil {
-		return err
-	}
-
-	// Only allow peers in Org1 to execute this function
-	if clientOrg != "Org1MSP" {
-		return errors.New("Access denied: Only peers in Org1 are allowed to execute InitLedger")
-	}
 	assets := []Product{
 		{ID: "1", Name: "apple", Description: "good", Status: "Created", Manufacturer: "null", Consumer: "null", CreatedDate: "null", DeliveredDate: "null"},
 		{ID: "2", Name: "orange", Description: "good", Status: "Created", Manufacturer: "null", Consumer: "null", CreatedDate: "null", DeliveredDate: "null"},
 	}
 
 	for _, asset := range assets {
+		if err := updateStatusCounters(ctx, &asset); err != nil {
+			return err
+		}
 		assetJSON, err := json.Marshal(asset)
 		if err != nil {
 			return err
@@ -73,22 +90,20 @@ func (s *SmartContract) ProductExists(ctx contractapi.TransactionContextInterfac
 }
 
 func (s *SmartContract) CreateProduct(ctx contractapi.TransactionContextInterface, id string, name string, description string, price string, manufacturer string, createddate string) error {
-	// Check the invoking client's organization
 	clientOrg, err := getClientOrganization(ctx)
 	if err != nil {
 		return err
 	}
-
-	// Only allow peers in Org1 to execute this function
-	if clientOrg != "Org1MSP" {
-		return errors.New("Access denied: Only peers in Org1 are allowed to execute CreateProduct")
+	// In GS1 mode the product is stored under its element string, whichever form of the GTIN was passed
+	if id, err = canonicalProductID(ctx, id); err != nil {
+		return err
 	}
 	exists, err := s.ProductExists(ctx, id)
 	if err != nil {
 		return err
 	}
 	if exists {
-		return fmt.Errorf("the product %s already exists", id)
+		return errAlreadyExists("product", id)
 	}
 
 	product := Product{
@@ -103,15 +118,35 @@ func (s *SmartContract) CreateProduct(ctx contractapi.TransactionContextInterfac
 		ModifiedDate:  "null",
 		DeliveredDate: "null",
 		OwnerType:	   id,
+		ManufacturerOrg: clientOrg,
 	}
 
-	productJSON, err := json.Marshal(product)
-	
-	if err != nil {
+	if err := indexProductGTIN(ctx, id); err != nil {
 		return err
 	}
+	if err := applyPrivatePrice(ctx, &product); err != nil {
+		return err
+	}
+	if !priced(&product) {
+		return errValidation("the product %s needs a price, either public or as %s in the transient map", id, transientPriceTerms)
+	}
+
+	return putProduct(ctx, &product)
+}
+
+// CreateProductWithGeneratedID issues a new product under an ID derived from the transaction ID and returns
+// the ID. The optional prefix, such as a manufacturer code, starts the ID; in GS1 mode it must be the GTIN
+// and the ID is an SGTIN with a generated serial number.
+func (s *SmartContract) CreateProductWithGeneratedID(ctx contractapi.TransactionContextInterface, prefix string, name string, description string, price string, manufacturer string, createddate string) (string, error) {
+	id, err := generateProductID(ctx, prefix)
+	if err != nil {
+		return "", err
+	}
+	if err := s.CreateProduct(ctx, id, name, description, price, manufacturer, createddate); err != nil {
+		return "", err
+	}
 
-	return ctx.GetStub().PutState(id, productJSON)
+	return id, nil
 }
 
 // GetAllProducts returns all products stored in the world state
@@ -175,22 +210,12 @@ func (s *SmartContract) GetProductsByManufacturer(ctx contractapi.TransactionCon
 }
 
 func (s *SmartContract) UpdateProduct(ctx contractapi.TransactionContextInterface, id string, name string, description string, price string, manufacturer string, modifieddate string) error {
-	// Check the invoking client's organization
-	clientOrg, err := getClientOrganization(ctx)
-	if err != nil {
-		return err
-	}
-
-	// Only allow peers in Org1 to execute this function
-	if clientOrg != "Org1MSP" {
-		return errors.New("Access denied: Only peers in Org1 are allowed to execute UpdateProduct")
-	}
 	exists, err := s.ProductExists(ctx, id)
 	if err != nil {
 		return err
 	}
 	if !exists {
-		return fmt.Errorf("the product %s does not exist", id)
+		return errNotFound("product", id)
 	}
 
 	// Retrieve the existing product
@@ -204,7 +229,7 @@ func (s *SmartContract) UpdateProduct(ctx contractapi.TransactionContextInterfac
 		return fmt.Errorf("failed to unmarshal existing product JSON: %v", err)
 	}
 	if existingProduct.Manufacturer != manufacturer {
-		return errors.New("You can update only the products that you created")
+		return errForbidden("You can update only the products that you created")
 
 	}
 
@@ -214,40 +239,26 @@ func (s *SmartContract) UpdateProduct(ctx contractapi.TransactionContextInterfac
 	existingProduct.Description = description
 	existingProduct.Price = price
 	existingProduct.ModifiedDate = modifieddate
-
-	// Marshal the updated product
-	updatedProductJSON, err := json.Marshal(existingProduct)
-	if err != nil {
-		return fmt.Errorf("failed to marshal updated product JSON: %v", err)
+	if err := applyPrivatePrice(ctx, &existingProduct); err != nil {
+		return err
 	}
-
-	// Put the updated product back to the world state
-	err = ctx.GetStub().PutState(id, updatedProductJSON)
-	if err != nil {
-		return fmt.Errorf("failed to update product in world state: %v", err)
+	if !priced(&existingProduct) {
+		return errValidation("the product %s needs a price, either public or as %s in the transient map", id, transientPriceTerms)
 	}
 
-	return nil
+	// Put the updated product back to the world state
+	return putProduct(ctx, &existingProduct)
 }
 
-// ProductOrder updates the status and owner of a product to mark it as ordered
+// ProductOrder updates the status and owner of a product to mark it as ordered.
+// Shipping details may be passed as "delivery_details" in the transient map, see DeliveryDetails.
 func (s *SmartContract) ProductOrder(ctx contractapi.TransactionContextInterface, id string, newOwner string, modifieddate string) error {
-	// Check the invoking client's organization
-	clientOrg, err := getClientOrganization(ctx)
-	if err != nil {
-		return err
-	}
-
-	// Only allow peers in Org2 to execute this function
-	if clientOrg != "Org2MSP" {
-		return errors.New("Access denied: Only peers in Org2 are allowed to execute ProductOrder")
-	}
 	exists, err := s.ProductExists(ctx, id)
 	if err != nil {
 		return err
 	}
 	if !exists {
-		return fmt.Errorf("the product %s does not exist", id)
+		return errNotFound("product", id)
 	}
 
 	// Retrieve the existing product
@@ -260,52 +271,61 @@ func (s *SmartContract) ProductOrder(ctx contractapi.TransactionContextInterface
 	if err != nil {
 		return fmt.Errorf("failed to unmarshal existing product JSON: %v", err)
 	}
-	// Check if the product is already ordered
-	if existingProduct.Status == "Accepted" {
-		return fmt.Errorf("the product %s is already ordered", id)
+	// An accepted or shipped order holds the consumer's escrow, which must not change hands
+	if !orderable(&existingProduct) {
+		return errInvalidState("the product %s cannot be ordered in status %s", id, existingProduct.Status)
+	}
+	// Products created before prices were required cannot be escrowed or invoiced
+	if !priced(&existingProduct) {
+		return errInvalidState("the product %s has no price and cannot be ordered", id)
+	}
+	// A private price is offered to the clients of one consumer org only
+	if existingProduct.PriceHash != "" {
+		clientOrg, err := getClientOrganization(ctx)
+		if err != nil {
+			return err
+		}
+		if clientOrg != existingProduct.PriceBuyerOrg {
+			return errForbidden("the product %s is offered at a private price to %s only", id, existingProduct.PriceBuyerOrg)
+		}
 	}
-	// Check if the product is already delivered
-	if existingProduct.Status == "Delivered" {
-		return fmt.Errorf("the product %s is already delivered", id)
+
+	consumerAccount, err := getClientID(ctx)
+	if err != nil {
+		return err
 	}
 
 	// Update the product status and owner
 	existingProduct.Status = "Pending Order Request"
 	existingProduct.Consumer = newOwner
+	existingProduct.ConsumerAccount = consumerAccount
 	existingProduct.ModifiedDate = modifieddate
-
-	// Marshal the updated product
-	updatedProductJSON, err := json.Marshal(existingProduct)
-	if err != nil {
-		return fmt.Errorf("failed to marshal updated product JSON: %v", err)
+	existingProduct.QuoteID = ""
+	existingProduct.QuotePriceHash = ""
+	if err := storeDeliveryDetails(ctx, &existingProduct); err != nil {
+		return err
 	}
-
-	// Put the updated product back to the world state
-	err = ctx.GetStub().PutState(id, updatedProductJSON)
-	if err != nil {
-		return fmt.Errorf("failed to update product in world state: %v", err)
+	if err := recordOrder(ctx, &existingProduct); err != nil {
+		return err
 	}
 
-	return nil
+	// Put the updated product back to the world state
+	return putProduct(ctx, &existingProduct)
 }
 
+// ProductDeliver marks a product as delivered. It is refused when the geofence policy requires delivery
+// coordinates, see ProductDeliverWithLocation.
 func (s *SmartContract) ProductDeliver(ctx contractapi.TransactionContextInterface, id string, manufacturer string, delivereddate string) error {
-	// Check the invoking client's organization
-	clientOrg, err := getClientOrganization(ctx)
-	if err != nil {
-		return err
-	}
+	return s.deliverProduct(ctx, id, manufacturer, delivereddate, nil)
+}
 
-	// Only allow peers in Org1 to execute this function
-	if clientOrg != "Org1MSP" {
-		return errors.New("Access denied: Only peers in Org1 are allowed to execute ProductDeliver")
-	}
+func (s *SmartContract) deliverProduct(ctx contractapi.TransactionContextInterface, id string, manufacturer string, delivereddate string, location *GeoPoint) error {
 	exists, err := s.ProductExists(ctx, id)
 	if err != nil {
 		return err
 	}
 	if !exists {
-		return fmt.Errorf("the product %s does not exist", id)
+		return errNotFound("product", id)
 	}
 
 	// Retrieve the existing product
@@ -323,46 +343,44 @@ func (s *SmartContract) ProductDeliver(ctx contractapi.TransactionContextInterfa
 	// 	return fmt.Errorf("the product %s has not been ordered or has already delivered", id)
 	// }
 	if existingProduct.Manufacturer != manufacturer {
-		return errors.New("You can only deliver your own products")
+		return errForbidden("You can only deliver your own products")
+	}
+	// Only a shipped product can be delivered, and only once
+	if existingProduct.Status != "Shipped" {
+		return errInvalidState("the product %s has not been shipped, its status is %s", id, existingProduct.Status)
+	}
+
+	// Check where the delivery was recorded against the destination
+	if err := recordProofOfDelivery(ctx, &existingProduct, location); err != nil {
+		return err
+	}
+
+	// Compensate the consumer if the delivery is late
+	if err := issueLatePenalty(ctx, &existingProduct); err != nil {
+		return err
 	}
 
 	existingProduct.Status = "Delivered"
 	existingProduct.DeliveredDate = delivereddate
 	existingProduct.ModifiedDate = delivereddate
 
-	// Marshal the updated product
-	updatedProductJSON, err := json.Marshal(existingProduct)
-	if err != nil {
-		return fmt.Errorf("failed to marshal updated product JSON: %v", err)
-	}
-
 	// Put the updated product back to the world state
-	err = ctx.GetStub().PutState(id, updatedProductJSON)
-	if err != nil {
-		return fmt.Errorf("failed to update product in world state: %v", err)
-	}
-
-	return nil
+	return putProduct(ctx, &existingProduct)
 }
 
-// ProductAccept updates the status of a product to mark it as accepted by the manufacturer
+// ProductAccept updates the status of a product to mark it as accepted by the manufacturer.
+// The ship-by and deliver-by dates are promised according to the configured SLA terms.
 func (s *SmartContract) ProductAccept(ctx contractapi.TransactionContextInterface, id string, manufacturer string, modifieddate string) error {
-	// Check the invoking client's organization
-	clientOrg, err := getClientOrganization(ctx)
-	if err != nil {
-		return err
-	}
+	return s.acceptOrder(ctx, id, manufacturer, modifieddate, "", "")
+}
 
-	// Only allow peers in Org1 to execute this function (assuming manufacturer is in Org1)
-	if clientOrg != "Org1MSP" {
-		return errors.New("Access denied: Only peers in Org1 are allowed to execute ProductAccept")
-	}
+func (s *SmartContract) acceptOrder(ctx contractapi.TransactionContextInterface, id string, manufacturer string, modifieddate string, shipByDate string, deliverByDate string) error {
 	exists, err := s.ProductExists(ctx, id)
 	if err != nil {
 		return err
 	}
 	if !exists {
-		return fmt.Errorf("the product %s does not exist", id)
+		return errNotFound("product", id)
 	}
 
 	// Retrieve the existing product
@@ -376,51 +394,48 @@ func (s *SmartContract) ProductAccept(ctx contractapi.TransactionContextInterfac
 		return fmt.Errorf("failed to unmarshal existing product JSON: %v", err)
 	}
 
-	// Check if the product is already accepted
-	if existingProduct.Status == "Accepted" {
-		return fmt.Errorf("the product %s is already accepted", id)
-	}
-	// Check if the product is already delivered
-	if existingProduct.Status == "Delivered" {
-		return fmt.Errorf("the product %s is already delivered", id)
+	// Only a pending order can be accepted: accepting a cancelled, rejected or shipped order would debit the
+	// consumer without a new order
+	if existingProduct.Status != "Pending Order Request" {
+		return errInvalidState("the product %s has no pending order to accept, its status is %s", id, existingProduct.Status)
 	}
 
-	// Update the product status to "Accepted"
-	existingProduct.Status = "Accepted"
+	// Lock the consumer's payment until delivery is confirmed
+	if err := lockEscrow(ctx, &existingProduct); err != nil {
+		return err
+	}
 
-	// Marshal the updated product
-	updatedProductJSON, err := json.Marshal(existingProduct)
-	if err != nil {
-		return fmt.Errorf("failed to marshal updated product JSON: %v", err)
+	// Bill the consumer for the accepted order
+	if err := issueInvoice(ctx, &existingProduct); err != nil {
+		return err
 	}
 
-	// Put the updated product back to the world state
-	err = ctx.GetStub().PutState(id, updatedProductJSON)
-	if err != nil {
-		return fmt.Errorf("failed to update product in world state: %v", err)
+	// Promise when the order will ship and arrive
+	if err := promiseDates(ctx, &existingProduct, shipByDate, deliverByDate); err != nil {
+		return err
 	}
 
-	return nil
+	// Documents of an earlier order of the product no longer apply
+	existingProduct.CreditNoteNumber = ""
+	existingProduct.ShipmentID = ""
+	existingProduct.ProofOfDelivery = nil
+
+	// Update the product status to "Accepted"
+	existingProduct.Status = "Accepted"
+
+	// Put the updated product back to the world state
+	return putProduct(ctx, &existingProduct)
 }
 
-// ProductShip updates the status of a product to mark it as shipped by the manufacturer
+// ProductShip updates the status of a product to mark it as shipped by the manufacturer in a shipment of its
+// own. Use ShipProducts to record the carrier and route.
 func (s *SmartContract) ProductShip(ctx contractapi.TransactionContextInterface, id string, modifieddate string) error {
-	// Check the invoking client's organization
-	clientOrg, err := getClientOrganization(ctx)
-	if err != nil {
-		return err
-	}
-
-	// Only allow peers in Org1 to execute this function (assuming manufacturer is in Org1)
-	if clientOrg != "Org1MSP" {
-		return errors.New("Access denied: Only peers in Org1 are allowed to execute ProductShip")
-	}
 	exists, err := s.ProductExists(ctx, id)
 	if err != nil {
 		return err
 	}
 	if !exists {
-		return fmt.Errorf("the product %s does not exist", id)
+		return errNotFound("product", id)
 	}
 
 	// Retrieve the existing product
@@ -434,32 +449,22 @@ func (s *SmartContract) ProductShip(ctx contractapi.TransactionContextInterface,
 		return fmt.Errorf("failed to unmarshal existing product JSON: %v", err)
 	}
 
-	// Check if the product is already shipped
-	if existingProduct.Status == "Shipped" {
-		return fmt.Errorf("the product %s is already shipped", id)
+	// Only an accepted order can be shipped: its payment is in escrow and it has been invoiced
+	if existingProduct.Status != "Accepted" {
+		return errInvalidState("the product %s has no accepted order to ship, its status is %s", id, existingProduct.Status)
 	}
-	// Check if the product is already delivered
-	if existingProduct.Status == "Delivered" {
-		return fmt.Errorf("the product %s is already delivered", id)
+
+	// Record the shipment so that it can be tracked
+	if err := createShipment(ctx, &Shipment{}, []*Product{&existingProduct}); err != nil {
+		return err
 	}
 
 	// Update the product status to "Shipped"
 	existingProduct.Status = "Shipped"
 	existingProduct.ModifiedDate = modifieddate
 
-	// Marshal the updated product
-	updatedProductJSON, err := json.Marshal(existingProduct)
-	if err != nil {
-		return fmt.Errorf("failed to marshal updated product JSON: %v", err)
-	}
-
 	// Put the updated product back to the world state
-	err = ctx.GetStub().PutState(id, updatedProductJSON)
-	if err != nil {
-		return fmt.Errorf("failed to update product in world state: %v", err)
-	}
-
-	return nil
+	return putProduct(ctx, &existingProduct)
 }
 
 // ReadProduct returns the product information stored in the world state with the given ID
@@ -470,7 +475,7 @@ func (s *SmartContract) ReadProduct(ctx contractapi.TransactionContextInterface,
 		return nil, err
 	}
 	if !exists {
-		return nil, fmt.Errorf("the product %s does not exist", id)
+		return nil, errNotFound("product", id)
 	}
 
 	// Retrieve the product from the world state
@@ -559,7 +564,7 @@ func (s *SmartContract) GetProductStatus(ctx contractapi.TransactionContextInter
         return "", err
     }
     if !exists {
-        return "", fmt.Errorf("the product %s does not exist", id)
+        return "", errNotFound("product", id)
     }
 
     // Retrieve the product from the world state
@@ -607,6 +612,9 @@ func (s *SmartContract) VerifyProductAuthenticity(ctx contractapi.TransactionCon
 }
 
 func getClientOrganization(ctx contractapi.TransactionContextInterface) (string, error) {
+	if txCtx := loadedContext(ctx); txCtx != nil {
+		return txCtx.clientOrg, nil
+	}
 	clientIdentity, err := ctx.GetClientIdentity().GetMSPID()
 	if err != nil {
 		return "", err
@@ -614,30 +622,69 @@ func getClientOrganization(ctx contractapi.TransactionContextInterface) (string,
 	return clientIdentity, nil
 }
 
-func (s *SmartContract) TrackProductHistory(ctx contractapi.TransactionContextInterface, id string) ([]Product, error) {
-	var productHistory []Product
+func getClientID(ctx contractapi.TransactionContextInterface) (string, error) {
+	if txCtx := loadedContext(ctx); txCtx != nil {
+		return txCtx.clientID, nil
+	}
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client identity: %v", err)
+	}
+	return clientID, nil
+}
 
-	resultsIterator, err := ctx.GetStub().GetHistoryForKey(id)
+// getTxTime returns the transaction timestamp chosen by the client, which is the same on every endorser
+func getTxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	if txCtx := loadedContext(ctx); txCtx != nil {
+		return txCtx.txTime, nil
+	}
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read product history: %v", err)
+		return time.Time{}, fmt.Errorf("failed to get transaction timestamp: %v", err)
 	}
-	defer resultsIterator.Close()
+	return txTimestamp.AsTime().UTC(), nil
+}
 
-	for resultsIterator.HasNext() {
-		response, err := resultsIterator.Next()
-		if err != nil {
-			return nil, fmt.Errorf("failed to iterate history results: %v", err)
-		}
+// orderable reports whether a product is free to take a new order
+func orderable(product *Product) bool {
+	return product.Status != "Accepted" && product.Status != "Shipped" && product.Status != "Delivered"
+}
 
-		var product Product
-		if err := json.Unmarshal(response.Value, &product); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal product JSON: %v", err)
-		}
+// putProduct stamps the product with the invoking client's identity, keeps the status counters in step and
+// writes it to the world state
+func putProduct(ctx contractapi.TransactionContextInterface, product *Product) error {
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return err
+	}
+	product.ModifiedBy = clientID
+	product.ModifiedByOrg = clientOrg
 
-		productHistory = append(productHistory, product)
+	if err := updateStatusCounters(ctx, product); err != nil {
+		return err
 	}
 
-	return productHistory, nil
+	productJSON, err := json.Marshal(product)
+	if err != nil {
+		return fmt.Errorf("failed to marshal product JSON: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(product.ID, productJSON)
+	if err != nil {
+		return fmt.Errorf("failed to update product in world state: %v", err)
+	}
+
+	return nil
+}
+
+// TrackProductHistory returns every recorded version of a product, oldest first, together with the
+// transaction that wrote it and the fields it changed
+func (s *SmartContract) TrackProductHistory(ctx contractapi.TransactionContextInterface, id string) ([]*ProductHistoryEntry, error) {
+	return readProductHistory(ctx, id)
 }
 
 // package chaincode
@@ -28,132 +28,103 @@ type stateQueryIterator interface {
 	shim.StateQueryIteratorInterface
 }
 
-func TestInitLedger(t *testing.T) {
-	chaincodeStub := &mocks.ChaincodeStub{}
+func newTransactionContext(chaincodeStub *mocks.ChaincodeStub) *mocks.TransactionContext {
 	transactionContext := &mocks.TransactionContext{}
 	transactionContext.GetStubReturns(chaincodeStub)
+	transactionContext.GetClientIdentityReturns(&chaincode.ClientIdentity{MSPID: "Org1MSP", ID: "maker-client"})
+	return transactionContext
+}
+
+func TestInitLedger(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := newTransactionContext(chaincodeStub)
 
-	assetTransfer := chaincode.SmartContract{}
-	err := assetTransfer.InitLedger(transactionContext)
+	productTransfer := chaincode.SmartContract{}
+	err := productTransfer.InitLedger(transactionContext)
 	require.NoError(t, err)
 
 	chaincodeStub.PutStateReturns(fmt.Errorf("failed inserting key"))
-	err = assetTransfer.InitLedger(transactionContext)
+	err = productTransfer.InitLedger(transactionContext)
 	require.EqualError(t, err, "failed to put to world state. failed inserting key")
 }
 
-func TestCreateAsset(t *testing.T) {
+func TestCreateProduct(t *testing.T) {
 	chaincodeStub := &mocks.ChaincodeStub{}
-	transactionContext := &mocks.TransactionContext{}
-	transactionContext.GetStubReturns(chaincodeStub)
+	transactionContext := newTransactionContext(chaincodeStub)
 
-	assetTransfer := chaincode.SmartContract{}
-	err := assetTransfer.CreateAsset(transactionContext, "", "", 0, "", 0)
+	productTransfer := chaincode.SmartContract{}
+	err := productTransfer.CreateProduct(transactionContext, "product1", "apple", "good", "10.00", "maker", "")
 	require.NoError(t, err)
 
-	chaincodeStub.GetStateReturns([]byte{}, nil)
-	err = assetTransfer.CreateAsset(transactionContext, "asset1", "", 0, "", 0)
-	require.EqualError(t, err, "the asset asset1 already exists")
-
-	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
-	err = assetTransfer.CreateAsset(transactionContext, "asset1", "", 0, "", 0)
-	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
+	err = productTransfer.CreateProduct(transactionContext, "product1", "apple", "good", "", "maker", "")
+	chaincode.RequireContractError(t, err, chaincode.CodeValidationFailed)
+
+	chaincodeStub.GetStateCalls(func(key string) ([]byte, error) {
+		if key == "product1" {
+			return []byte{}, nil
+		}
+		return nil, nil
+	})
+	err = productTransfer.CreateProduct(transactionContext, "product1", "apple", "good", "10.00", "maker", "")
+	chaincode.RequireContractError(t, err, chaincode.CodeAlreadyExists)
+
+	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve product"))
+	err = productTransfer.CreateProduct(transactionContext, "product1", "apple", "good", "10.00", "maker", "")
+	require.ErrorContains(t, err, "unable to retrieve product")
 }
 
-func TestReadAsset(t *testing.T) {
+func TestReadProduct(t *testing.T) {
 	chaincodeStub := &mocks.ChaincodeStub{}
-	transactionContext := &mocks.TransactionContext{}
-	transactionContext.GetStubReturns(chaincodeStub)
+	transactionContext := newTransactionContext(chaincodeStub)
 
-	expectedAsset := &chaincode.Asset{ID: "asset1"}
-	bytes, err := json.Marshal(expectedAsset)
+	expectedProduct := &chaincode.Product{ID: "product1"}
+	bytes, err := json.Marshal(expectedProduct)
 	require.NoError(t, err)
 
 	chaincodeStub.GetStateReturns(bytes, nil)
-	assetTransfer := chaincode.SmartContract{}
-	asset, err := assetTransfer.ReadAsset(transactionContext, "")
+	productTransfer := chaincode.SmartContract{}
+	product, err := productTransfer.ReadProduct(transactionContext, "")
 	require.NoError(t, err)
-	require.Equal(t, expectedAsset, asset)
+	require.Equal(t, expectedProduct, product)
 
-	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
-	_, err = assetTransfer.ReadAsset(transactionContext, "")
-	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
+	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve product"))
+	_, err = productTransfer.ReadProduct(transactionContext, "")
+	require.EqualError(t, err, "failed to read from world state: unable to retrieve product")
 
 	chaincodeStub.GetStateReturns(nil, nil)
-	asset, err = assetTransfer.ReadAsset(transactionContext, "asset1")
-	require.EqualError(t, err, "the asset asset1 does not exist")
-	require.Nil(t, asset)
+	product, err = productTransfer.ReadProduct(transactionContext, "product1")
+	chaincode.RequireContractError(t, err, chaincode.CodeNotFound)
+	require.Nil(t, product)
 }
 
-func TestUpdateAsset(t *testing.T) {
+func TestUpdateProduct(t *testing.T) {
 	chaincodeStub := &mocks.ChaincodeStub{}
-	transactionContext := &mocks.TransactionContext{}
-	transactionContext.GetStubReturns(chaincodeStub)
+	transactionContext := newTransactionContext(chaincodeStub)
 
-	expectedAsset := &chaincode.Asset{ID: "asset1"}
-	bytes, err := json.Marshal(expectedAsset)
+	expectedProduct := &chaincode.Product{ID: "product1", Manufacturer: "maker", Price: "10.00"}
+	bytes, err := json.Marshal(expectedProduct)
 	require.NoError(t, err)
 
 	chaincodeStub.GetStateReturns(bytes, nil)
-	assetTransfer := chaincode.SmartContract{}
-	err = assetTransfer.UpdateAsset(transactionContext, "", "", 0, "", 0)
-	require.NoError(t, err)
-
-	chaincodeStub.GetStateReturns(nil, nil)
-	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "", 0, "", 0)
-	require.EqualError(t, err, "the asset asset1 does not exist")
-
-	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
-	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "", 0, "", 0)
-	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
-}
-
-func TestDeleteAsset(t *testing.T) {
-	chaincodeStub := &mocks.ChaincodeStub{}
-	transactionContext := &mocks.TransactionContext{}
-	transactionContext.GetStubReturns(chaincodeStub)
-
-	asset := &chaincode.Asset{ID: "asset1"}
-	bytes, err := json.Marshal(asset)
+	productTransfer := chaincode.SmartContract{}
+	err = productTransfer.UpdateProduct(transactionContext, "product1", "apple", "good", "12.00", "maker", "")
 	require.NoError(t, err)
 
-	chaincodeStub.GetStateReturns(bytes, nil)
-	chaincodeStub.DelStateReturns(nil)
-	assetTransfer := chaincode.SmartContract{}
-	err = assetTransfer.DeleteAsset(transactionContext, "")
-	require.NoError(t, err)
+	err = productTransfer.UpdateProduct(transactionContext, "product1", "apple", "good", "12.00", "other", "")
+	chaincode.RequireContractError(t, err, chaincode.CodeForbidden)
 
 	chaincodeStub.GetStateReturns(nil, nil)
-	err = assetTransfer.DeleteAsset(transactionContext, "asset1")
-	require.EqualError(t, err, "the asset asset1 does not exist")
+	err = productTransfer.UpdateProduct(transactionContext, "product1", "apple", "good", "12.00", "maker", "")
+	chaincode.RequireContractError(t, err, chaincode.CodeNotFound)
 
-	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
-	err = assetTransfer.DeleteAsset(transactionContext, "")
-	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
+	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve product"))
+	err = productTransfer.UpdateProduct(transactionContext, "product1", "apple", "good", "12.00", "maker", "")
+	require.EqualError(t, err, "failed to read from world state: unable to retrieve product")
 }
 
-func TestTransferAsset(t *testing.T) {
-	chaincodeStub := &mocks.ChaincodeStub{}
-	transactionContext := &mocks.TransactionContext{}
-	transactionContext.GetStubReturns(chaincodeStub)
-
-	asset := &chaincode.Asset{ID: "asset1"}
-	bytes, err := json.Marshal(asset)
-	require.NoError(t, err)
-
-	chaincodeStub.GetStateReturns(bytes, nil)
-	assetTransfer := chaincode.SmartContract{}
-	_, err = assetTransfer.TransferAsset(transactionContext, "", "")
-	require.NoError(t, err)
-
-	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
-	_, err = assetTransfer.TransferAsset(transactionContext, "", "")
-	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
-}
-
-func TestGetAllAssets(t *testing.T) {
-	asset := &chaincode.Asset{ID: "asset1"}
-	bytes, err := json.Marshal(asset)
+func TestGetAllProducts(t *testing.T) {
+	product := &chaincode.Product{ID: "product1"}
+	bytes, err := json.Marshal(product)
 	require.NoError(t, err)
 
 	iterator := &mocks.StateQueryIterator{}
@@ -162,23 +133,22 @@ func TestGetAllAssets(t *testing.T) {
 	iterator.NextReturns(&queryresult.KV{Value: bytes}, nil)
 
 	chaincodeStub := &mocks.ChaincodeStub{}
-	transactionContext := &mocks.TransactionContext{}
-	transactionContext.GetStubReturns(chaincodeStub)
+	transactionContext := newTransactionContext(chaincodeStub)
 
 	chaincodeStub.GetStateByRangeReturns(iterator, nil)
-	assetTransfer := &chaincode.SmartContract{}
-	assets, err := assetTransfer.GetAllAssets(transactionContext)
+	productTransfer := &chaincode.SmartContract{}
+	products, err := productTransfer.GetAllProducts(transactionContext)
 	require.NoError(t, err)
-	require.Equal(t, []*chaincode.Asset{asset}, assets)
+	require.Equal(t, []*chaincode.Product{product}, products)
 
 	iterator.HasNextReturns(true)
 	iterator.NextReturns(nil, fmt.Errorf("failed retrieving next item"))
-	assets, err = assetTransfer.GetAllAssets(transactionContext)
-	require.EqualError(t, err, "failed retrieving next item")
-	require.Nil(t, assets)
-
-	chaincodeStub.GetStateByRangeReturns(nil, fmt.Errorf("failed retrieving all assets"))
-	assets, err = assetTransfer.GetAllAssets(transactionContext)
-	require.EqualError(t, err, "failed retrieving all assets")
-	require.Nil(t, assets)
+	products, err = productTransfer.GetAllProducts(transactionContext)
+	require.EqualError(t, err, "error iterating over query results: failed retrieving next item")
+	require.Nil(t, products)
+
+	chaincodeStub.GetStateByRangeReturns(nil, fmt.Errorf("failed retrieving all products"))
+	products, err = productTransfer.GetAllProducts(transactionContext)
+	require.EqualError(t, err, "failed to get state by range: failed retrieving all products")
+	require.Nil(t, products)
 }
@@ -0,0 +1,237 @@
+package chaincode
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ledgerStub is an in-memory world state and private data store for tests that run several transactions
+// in a row. Writes of a transaction only become visible when it is committed, as on a peer; every
+// transaction started with begin runs one hour after the previous one.
+type ledgerStub struct {
+	mocks.ChaincodeStub
+	state       map[string][]byte
+	privateData map[string]map[string][]byte
+	writes      map[string][]byte // nil values are deletes
+	privWrites  map[string]map[string][]byte
+	transient   map[string][]byte
+	history     map[string][]*queryresult.KeyModification // committed writes of every key, oldest first
+	historyRead int                                       // history results read through GetHistoryForKey
+	txID        string
+	txTime      time.Time
+}
+
+func newLedgerStub() *ledgerStub {
+	return &ledgerStub{
+		state:       make(map[string][]byte),
+		privateData: make(map[string]map[string][]byte),
+		history:     make(map[string][]*queryresult.KeyModification),
+		txTime:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// begin starts a transaction, discarding the writes of an uncommitted one
+func (s *ledgerStub) begin(txID string) {
+	s.txID = txID
+	s.txTime = s.txTime.Add(time.Hour)
+	s.writes = make(map[string][]byte)
+	s.privWrites = make(map[string]map[string][]byte)
+	s.transient = make(map[string][]byte)
+}
+
+// commit applies the writes of the current transaction
+func (s *ledgerStub) commit() {
+	for key, value := range s.writes {
+		s.history[key] = append(s.history[key], &queryresult.KeyModification{
+			TxId:      s.txID,
+			Value:     value,
+			Timestamp: timestamppb.New(s.txTime),
+			IsDelete:  value == nil,
+		})
+		if value == nil {
+			delete(s.state, key)
+		} else {
+			s.state[key] = value
+		}
+	}
+	for collection, writes := range s.privWrites {
+		if s.privateData[collection] == nil {
+			s.privateData[collection] = make(map[string][]byte)
+		}
+		for key, value := range writes {
+			if value == nil {
+				delete(s.privateData[collection], key)
+			} else {
+				s.privateData[collection][key] = value
+			}
+		}
+	}
+}
+
+func (s *ledgerStub) GetTxID() string {
+	return s.txID
+}
+
+func (s *ledgerStub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	return timestamppb.New(s.txTime), nil
+}
+
+func (s *ledgerStub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+func (s *ledgerStub) SetEvent(name string, payload []byte) error {
+	return nil
+}
+
+func (s *ledgerStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+func (s *ledgerStub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	return (&shim.ChaincodeStub{}).SplitCompositeKey(compositeKey)
+}
+
+func (s *ledgerStub) GetState(key string) ([]byte, error) {
+	return s.state[key], nil
+}
+
+func (s *ledgerStub) PutState(key string, value []byte) error {
+	s.writes[key] = value
+	return nil
+}
+
+func (s *ledgerStub) DelState(key string) error {
+	s.writes[key] = nil
+	return nil
+}
+
+func (s *ledgerStub) GetStateByRange(startKey string, endKey string) (shim.StateQueryIteratorInterface, error) {
+	return newKeyIterator(s.state, func(key string) bool {
+		return !strings.HasPrefix(key, "\x00") && key >= startKey && (endKey == "" || key < endKey)
+	}), nil
+}
+
+func (s *ledgerStub) GetStateByPartialCompositeKey(objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	prefix, err := shim.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyIterator(s.state, func(key string) bool { return strings.HasPrefix(key, prefix) }), nil
+}
+
+func (s *ledgerStub) GetPrivateData(collection string, key string) ([]byte, error) {
+	return s.privateData[collection][key], nil
+}
+
+func (s *ledgerStub) PutPrivateData(collection string, key string, value []byte) error {
+	if s.privWrites[collection] == nil {
+		s.privWrites[collection] = make(map[string][]byte)
+	}
+	s.privWrites[collection][key] = value
+	return nil
+}
+
+func (s *ledgerStub) DelPrivateData(collection string, key string) error {
+	return s.PutPrivateData(collection, key, nil)
+}
+
+func (s *ledgerStub) PurgePrivateData(collection string, key string) error {
+	return s.PutPrivateData(collection, key, nil)
+}
+
+func (s *ledgerStub) GetPrivateDataByPartialCompositeKey(collection string, objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	prefix, err := shim.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyIterator(s.privateData[collection], func(key string) bool { return strings.HasPrefix(key, prefix) }), nil
+}
+
+// GetHistoryForKey returns the committed writes of a key newest first, as Fabric 2 does
+func (s *ledgerStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	iterator := &historyIterator{read: &s.historyRead}
+	for i := len(s.history[key]) - 1; i >= 0; i-- {
+		iterator.results = append(iterator.results, s.history[key][i])
+	}
+	return iterator, nil
+}
+
+// keyIterator iterates over a snapshot of the matching keys in key order
+type keyIterator struct {
+	results []*queryresult.KV
+	next    int
+}
+
+func newKeyIterator(values map[string][]byte, match func(key string) bool) *keyIterator {
+	var keys []string
+	for key := range values {
+		if match(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	iterator := &keyIterator{}
+	for _, key := range keys {
+		iterator.results = append(iterator.results, &queryresult.KV{Key: key, Value: values[key]})
+	}
+	return iterator
+}
+
+func (it *keyIterator) HasNext() bool {
+	return it.next < len(it.results)
+}
+
+func (it *keyIterator) Next() (*queryresult.KV, error) {
+	it.next++
+	return it.results[it.next-1], nil
+}
+
+func (it *keyIterator) Close() error {
+	return nil
+}
+
+// newClientContext returns a transaction context on stub for a client of an org
+func newClientContext(stub *ledgerStub, mspID string, id string) *mocks.TransactionContext {
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(stub)
+	transactionContext.GetClientIdentityReturns(&ClientIdentity{MSPID: mspID, ID: id})
+	return transactionContext
+}
+
+// newHookedContext returns the context contractapi creates for a transaction on stub by a client of an org,
+// whose stub reads the writes of the transaction. beforeTransaction has yet to load it.
+func newHookedContext(stub *ledgerStub, mspID string, id string) *TransactionContext {
+	transactionContext := new(TransactionContext)
+	transactionContext.SetStub(stub)
+	transactionContext.SetClientIdentity(&ClientIdentity{MSPID: mspID, ID: id})
+	return transactionContext
+}
+
+// historyIterator iterates over a snapshot of the history of a key
+type historyIterator struct {
+	results []*queryresult.KeyModification
+	next    int
+	read    *int
+}
+
+func (it *historyIterator) HasNext() bool {
+	return it.next < len(it.results)
+}
+
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	it.next++
+	*it.read++
+	return it.results[it.next-1], nil
+}
+
+func (it *historyIterator) Close() error {
+	return nil
+}
@@ -0,0 +1,271 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	balanceObjectType   = "balance"
+	allowanceObjectType = "allowance"
+	tokenInfoObjectType = "tokenInfo"
+	totalSupplyKey      = "totalSupply"
+
+	// tokenIssuerOrg is the only org allowed to mint settlement tokens
+	tokenIssuerOrg = "Org1MSP"
+
+	tokenName     = "Supply Chain Settlement Token"
+	tokenSymbol   = "SCST"
+	tokenDecimals = amountDecimals
+)
+
+// TokenContract is an ERC-20 style settlement token. Accounts are client identities and amounts are whole
+// minor currency units, so 1050 tokens settle a price of "10.50". SmartContract debits and credits the same
+// balances when it escrows order payments.
+type TokenContract struct {
+	contractapi.Contract
+}
+
+// NewTokenContract returns the settlement token contract, invoked with the "token:" prefix
+func NewTokenContract() *TokenContract {
+	return &TokenContract{Contract: newContract("token")}
+}
+
+// tokenEvent is the payload of the Transfer and Approval events
+type tokenEvent struct {
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Owner   string `json:"owner,omitempty"`
+	Spender string `json:"spender,omitempty"`
+	Value   int64  `json:"value"`
+}
+
+// Name returns the name of the token
+func (t *TokenContract) Name(ctx contractapi.TransactionContextInterface) (string, error) {
+	return tokenName, nil
+}
+
+// Symbol returns the symbol of the token
+func (t *TokenContract) Symbol(ctx contractapi.TransactionContextInterface) (string, error) {
+	return tokenSymbol, nil
+}
+
+// Decimals returns the number of decimals a token amount is displayed with
+func (t *TokenContract) Decimals(ctx contractapi.TransactionContextInterface) (int, error) {
+	return tokenDecimals, nil
+}
+
+// Mint creates new tokens and credits them to an account. Only the token issuer org can mint.
+func (t *TokenContract) Mint(ctx contractapi.TransactionContextInterface, account string, amount int64) error {
+	if account == "" {
+		return errValidation("the account must not be empty")
+	}
+	if amount <= 0 {
+		return errValidation("the amount to mint must be a positive integer")
+	}
+
+	totalSupply, err := readTotalSupply(ctx)
+	if err != nil {
+		return err
+	}
+	if amount > math.MaxInt64-totalSupply {
+		return errValidation("minting %d tokens would overflow the total supply of %d", amount, totalSupply)
+	}
+	if err := addBalance(ctx, account, amount); err != nil {
+		return err
+	}
+	if err := writeTotalSupply(ctx, totalSupply+amount); err != nil {
+		return err
+	}
+
+	return setTokenEvent(ctx, "Transfer", tokenEvent{To: account, Value: amount})
+}
+
+// TotalSupply returns the number of tokens minted so far
+func (t *TokenContract) TotalSupply(ctx contractapi.TransactionContextInterface) (int64, error) {
+	return readTotalSupply(ctx)
+}
+
+// BalanceOf returns the token balance of an account
+func (t *TokenContract) BalanceOf(ctx contractapi.TransactionContextInterface, account string) (int64, error) {
+	return readBalance(ctx, account)
+}
+
+// ClientAccountID returns the account of the invoking client, which is its client identity
+func (t *TokenContract) ClientAccountID(ctx contractapi.TransactionContextInterface) (string, error) {
+	return getClientID(ctx)
+}
+
+// ClientAccountBalance returns the token balance of the invoking client
+func (t *TokenContract) ClientAccountBalance(ctx contractapi.TransactionContextInterface) (int64, error) {
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return readBalance(ctx, clientID)
+}
+
+// Transfer moves tokens from the invoking client's account to a recipient
+func (t *TokenContract) Transfer(ctx contractapi.TransactionContextInterface, recipient string, amount int64) error {
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return err
+	}
+	if err := transferTokens(ctx, clientID, recipient, amount); err != nil {
+		return err
+	}
+
+	return setTokenEvent(ctx, "Transfer", tokenEvent{From: clientID, To: recipient, Value: amount})
+}
+
+// Approve allows a spender to transfer up to amount tokens out of the invoking client's account, replacing
+// any earlier allowance
+func (t *TokenContract) Approve(ctx contractapi.TransactionContextInterface, spender string, amount int64) error {
+	clientID, err := getClientID(ctx)
+	if err != nil {
+		return err
+	}
+	if spender == "" {
+		return errValidation("the spender must not be empty")
+	}
+	if amount < 0 {
+		return errValidation("the allowance must not be negative")
+	}
+
+	if err := writeAllowance(ctx, clientID, spender, amount); err != nil {
+		return err
+	}
+
+	return setTokenEvent(ctx, "Approval", tokenEvent{Owner: clientID, Spender: spender, Value: amount})
+}
+
+// Allowance returns how many tokens a spender may still transfer out of an owner's account
+func (t *TokenContract) Allowance(ctx contractapi.TransactionContextInterface, owner string, spender string) (int64, error) {
+	return readAllowance(ctx, owner, spender)
+}
+
+// TransferFrom lets the invoking client spend tokens out of another account within its allowance
+func (t *TokenContract) TransferFrom(ctx contractapi.TransactionContextInterface, from string, recipient string, amount int64) error {
+	spender, err := getClientID(ctx)
+	if err != nil {
+		return err
+	}
+
+	allowance, err := readAllowance(ctx, from, spender)
+	if err != nil {
+		return err
+	}
+	if allowance < amount {
+		return errInvalidState("the allowance of %d is less than the %d tokens to transfer", allowance, amount)
+	}
+
+	if err := transferTokens(ctx, from, recipient, amount); err != nil {
+		return err
+	}
+	if err := writeAllowance(ctx, from, spender, allowance-amount); err != nil {
+		return err
+	}
+
+	return setTokenEvent(ctx, "Transfer", tokenEvent{From: from, To: recipient, Value: amount})
+}
+
+// transferTokens debits one account and credits another in the current transaction
+func transferTokens(ctx contractapi.TransactionContextInterface, from string, to string, amount int64) error {
+	if to == "" {
+		return errValidation("the recipient must not be empty")
+	}
+	if from == to {
+		return errValidation("cannot transfer to and from the same account")
+	}
+	if amount <= 0 {
+		return errValidation("the amount to transfer must be a positive integer")
+	}
+
+	if err := addBalance(ctx, from, -amount); err != nil {
+		return err
+	}
+	return addBalance(ctx, to, amount)
+}
+
+func readBalance(ctx contractapi.TransactionContextInterface, account string) (int64, error) {
+	return readIntState(ctx, balanceObjectType, []string{account})
+}
+
+// addBalance credits (or, with a negative delta, debits) an account, refusing to overdraw it or to overflow
+// its balance
+func addBalance(ctx contractapi.TransactionContextInterface, account string, delta int64) error {
+	balance, err := readBalance(ctx, account)
+	if err != nil {
+		return err
+	}
+	if delta > 0 && balance > math.MaxInt64-delta {
+		return errInvalidState("crediting %d tokens would overflow the balance of %d of account %s", delta, balance, account)
+	}
+	if balance+delta < 0 {
+		return errInvalidState("insufficient funds: account %s has %d and needs %d", account, balance, -delta)
+	}
+	return writeIntState(ctx, balanceObjectType, []string{account}, balance+delta)
+}
+
+func readAllowance(ctx contractapi.TransactionContextInterface, owner string, spender string) (int64, error) {
+	return readIntState(ctx, allowanceObjectType, []string{owner, spender})
+}
+
+func writeAllowance(ctx contractapi.TransactionContextInterface, owner string, spender string, amount int64) error {
+	return writeIntState(ctx, allowanceObjectType, []string{owner, spender}, amount)
+}
+
+func readTotalSupply(ctx contractapi.TransactionContextInterface) (int64, error) {
+	return readIntState(ctx, tokenInfoObjectType, []string{totalSupplyKey})
+}
+
+func writeTotalSupply(ctx contractapi.TransactionContextInterface, totalSupply int64) error {
+	return writeIntState(ctx, tokenInfoObjectType, []string{totalSupplyKey}, totalSupply)
+}
+
+// readIntState reads an integer stored under a composite key, treating a missing key as zero
+func readIntState(ctx contractapi.TransactionContextInterface, objectType string, attributes []string) (int64, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s key: %v", objectType, err)
+	}
+	valueBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if valueBytes == nil {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseInt(string(valueBytes), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s value: %v", objectType, err)
+	}
+	return value, nil
+}
+
+func writeIntState(ctx contractapi.TransactionContextInterface, objectType string, attributes []string, value int64) error {
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return fmt.Errorf("failed to create %s key: %v", objectType, err)
+	}
+	if err := ctx.GetStub().PutState(key, []byte(strconv.FormatInt(value, 10))); err != nil {
+		return fmt.Errorf("failed to put to world state. %v", err)
+	}
+	return nil
+}
+
+func setTokenEvent(ctx contractapi.TransactionContextInterface, name string, event tokenEvent) error {
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %v", name, err)
+	}
+	if err := ctx.GetStub().SetEvent(name, eventJSON); err != nil {
+		return fmt.Errorf("failed to set %s event: %v", name, err)
+	}
+	return nil
+}
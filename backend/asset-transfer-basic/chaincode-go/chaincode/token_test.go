@@ -0,0 +1,68 @@
+package chaincode
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMintRefusesSupplyOverflow(t *testing.T) {
+	stub := newLedgerStub()
+	issuer := newClientContext(stub, tokenIssuerOrg, "issuer")
+	token := NewTokenContract()
+
+	stub.begin("mint")
+	require.NoError(t, token.Mint(issuer, "alice", math.MaxInt64-10))
+	stub.commit()
+
+	stub.begin("mint-overflow")
+	RequireContractError(t, token.Mint(issuer, "bob", 11), CodeValidationFailed)
+
+	stub.begin("mint-rest")
+	require.NoError(t, token.Mint(issuer, "bob", 10))
+	stub.commit()
+
+	totalSupply, err := token.TotalSupply(issuer)
+	require.NoError(t, err)
+	require.Equal(t, int64(math.MaxInt64), totalSupply)
+}
+
+func TestAddBalance(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance int64
+		delta   int64
+		want    int64
+		wantErr bool
+	}{
+		{name: "credit", balance: 100, delta: 50, want: 150},
+		{name: "debit", balance: 100, delta: -100, want: 0},
+		{name: "overdraw", balance: 100, delta: -101, wantErr: true},
+		{name: "credit up to the maximum", balance: math.MaxInt64 - 1, delta: 1, want: math.MaxInt64},
+		{name: "overflow", balance: math.MaxInt64 - 1, delta: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newLedgerStub()
+			ctx := newClientContext(stub, "Org2MSP", "alice")
+			stub.begin("seed")
+			require.NoError(t, writeIntState(ctx, balanceObjectType, []string{"alice"}, tt.balance))
+			stub.commit()
+
+			stub.begin("add")
+			err := addBalance(ctx, "alice", tt.delta)
+			if tt.wantErr {
+				RequireContractError(t, err, CodeInvalidState)
+				return
+			}
+			require.NoError(t, err)
+			stub.commit()
+
+			balance, err := readBalance(ctx, "alice")
+			require.NoError(t, err)
+			require.Equal(t, tt.want, balance)
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package chaincode
+
+import (
+	"regexp"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxIDLength = 128
+	// maxPrefixLength leaves room in generated IDs for the serial derived from the transaction ID
+	maxPrefixLength = 64
+	maxNameLength   = 100
+	maxTextLength   = 1000
+
+	// idPattern allows printable ASCII without spaces in the IDs of new records, which keeps them usable in
+	// composite keys, URLs and GS1 Digital Link URIs
+	idPattern = `^[\x21-\x7E]+$`
+	// textPattern rejects control characters. It is also used for references to existing records, which
+	// may predate idPattern.
+	textPattern = `^[^\x00-\x1F\x7F]*$`
+
+	// appDatePattern matches the moment.js layout the REST API writes dates with, "MMMM Do YYYY, h:mm:ss a"
+	appDatePattern = `^(January|February|March|April|May|June|July|August|September|October|November|December) ` +
+		`([1-9]|[12][0-9]|3[01])(st|nd|rd|th) [0-9]{4}, ([1-9]|1[0-2]):[0-5][0-9]:[0-5][0-9] (am|pm)$`
+)
+
+// Formats a ParameterRule can require on top of its pattern
+const (
+	FormatAmount   = "amount"    // a price with at most two decimals, see parseAmount
+	FormatDate     = "app-date"  // an RFC 3339 timestamp or a date written by the REST API, such as "May 4th 2024, 3:04:05 pm"
+	FormatDateTime = "date-time" // an RFC 3339 timestamp
+)
+
+// ParameterRule constrains one parameter of a transaction. Rules are positional: the n-th rule of a
+// transaction applies to its n-th parameter. Parameters that are not strings only carry their name; their
+// type is checked by contractapi against the contract metadata.
+type ParameterRule struct {
+	Name      string `json:"name"`
+	Required  bool   `json:"required"`
+	MaxLength int    `json:"maxLength,omitempty" metadata:",optional"` // in characters
+	Pattern   string `json:"pattern,omitempty" metadata:",optional"`   // a regular expression the value must match
+	Format    string `json:"format,omitempty" metadata:",optional"`    // FormatAmount, FormatDate or FormatDateTime
+}
+
+func idParam(name string) ParameterRule {
+	return ParameterRule{Name: name, Required: true, MaxLength: maxIDLength, Pattern: idPattern}
+}
+
+func refParam(name string) ParameterRule {
+	return ParameterRule{Name: name, Required: true, MaxLength: maxIDLength, Pattern: textPattern}
+}
+
+func textParam(name string, maxLength int) ParameterRule {
+	return ParameterRule{Name: name, Required: true, MaxLength: maxLength, Pattern: textPattern}
+}
+
+func optionalTextParam(name string, maxLength int) ParameterRule {
+	return ParameterRule{Name: name, MaxLength: maxLength, Pattern: textPattern}
+}
+
+func enumParam(name string, pattern string) ParameterRule {
+	return ParameterRule{Name: name, Required: true, Pattern: pattern}
+}
+
+func dateParam(name string) ParameterRule {
+	return ParameterRule{Name: name, Required: true, Format: FormatDate}
+}
+
+func optionalTimeParam(name string) ParameterRule {
+	return ParameterRule{Name: name, Format: FormatDateTime}
+}
+
+// priceParam may only be empty when private price_terms are passed in the transient map, which
+// CreateProduct and UpdateProduct check once the transient map has been read
+func priceParam() ParameterRule {
+	return ParameterRule{Name: "price", Format: FormatAmount}
+}
+
+func typedParam(name string) ParameterRule {
+	return ParameterRule{Name: name}
+}
+
+// parameterRules declares the rules of every SmartContract transaction, enforced by beforeTransaction
+// before the transaction runs and published in the contract metadata by Chaincode. The named contracts share
+// them through transactionAliases.
+var parameterRules = map[string][]ParameterRule{
+	// Products
+	"CreateProduct": {idParam("id"), textParam("name", maxNameLength), optionalTextParam("description", maxTextLength),
+		priceParam(), textParam("manufacturer", maxNameLength), dateParam("createddate")},
+	"UpdateProduct": {refParam("id"), textParam("name", maxNameLength), optionalTextParam("description", maxTextLength),
+		priceParam(), textParam("manufacturer", maxNameLength), dateParam("modifieddate")},
+	"CreateProductWithGeneratedID": {{Name: "prefix", MaxLength: maxPrefixLength, Pattern: `^[\x21-\x7E]*$`},
+		textParam("name", maxNameLength), optionalTextParam("description", maxTextLength),
+		priceParam(), textParam("manufacturer", maxNameLength), dateParam("createddate")},
+	"CreateProductsBatch":       {typedParam("products")},
+	"ReadProduct":               {refParam("id")},
+	"ProductExists":             {refParam("id")},
+	"GetAllProducts":            {},
+	"GetProductsByManufacturer": {textParam("manufacturer", maxNameLength)},
+	"GetProductsByGTIN":         {enumParam("gtin", `^[0-9]{14}$`)},
+	"GetProductStatus":          {refParam("id")},
+	"VerifyProductAuthenticity": {refParam("id")},
+	"SetProductCategory":        {refParam("id"), textParam("manufacturer", maxNameLength), textParam("category", maxNameLength)},
+	"TrackProductHistory":       {refParam("id")},
+	"GetProductHistoryPage": {refParam("id"), optionalTimeParam("fromTime"), optionalTimeParam("toTime"),
+		typedParam("pageSize"), optionalTextParam("bookmark", maxIDLength), typedParam("newestFirst")},
+	"ExportEPCISDocument": {typedParam("ids")},
+	"ExportProductEPCIS":  {refParam("id")},
+	"ParseGS1Identifier":  {refParam("id")},
+	"ReadPriceTerms":      {refParam("id")},
+	"VerifyPriceTerms":    {refParam("id")},
+	"GetProductRatings":   {refParam("id")},
+
+	// Orders
+	"ProductOrder":       {refParam("id"), textParam("newOwner", maxNameLength), dateParam("modifieddate")},
+	"ProductAccept":      {refParam("id"), textParam("manufacturer", maxNameLength), dateParam("modifieddate")},
+	"ProductAcceptBatch": {typedParam("ids"), textParam("manufacturer", maxNameLength), dateParam("modifieddate")},
+	"ProductAcceptWithSLA": {refParam("id"), textParam("manufacturer", maxNameLength), dateParam("modifieddate"),
+		optionalTimeParam("shipByDate"), optionalTimeParam("deliverByDate")},
+	"ProductReject":  {refParam("id"), textParam("manufacturer", maxNameLength), dateParam("modifieddate")},
+	"CancelOrder":    {refParam("id"), dateParam("modifieddate")},
+	"ProductDeliver": {refParam("id"), textParam("manufacturer", maxNameLength), dateParam("delivereddate")},
+	"ProductDeliverWithLocation": {refParam("id"), textParam("manufacturer", maxNameLength), dateParam("delivereddate"),
+		typedParam("latitude"), typedParam("longitude")},
+	"ConfirmDelivery": {refParam("id"), dateParam("confirmeddate")},
+	"RateOrder": {refParam("id"), typedParam("productScore"), typedParam("manufacturerScore"),
+		{Name: "commentHash", Pattern: `^([0-9a-fA-F]{64})?$`}},
+	"GetOrderRequestedProductList":  {textParam("userName", maxNameLength)},
+	"GetConsumerOrderedProductList": {textParam("userName", maxNameLength)},
+	"GetOverdueOrders":              {textParam("manufacturer", maxNameLength)},
+	"GetEscrow":                     {refParam("id")},
+	"ReadDeliveryDetails":           {refParam("id")},
+	"PurgeDeliveryDetails":          {refParam("id")},
+
+	// Quotes, whose terms are passed as transient data
+	"RequestQuote": {idParam("quoteID")},
+	"OfferQuote":   {refParam("quoteID")},
+	"CounterQuote": {refParam("quoteID")},
+	"AcceptQuote":  {refParam("quoteID"), dateParam("modifieddate")},
+	"RejectQuote":  {refParam("quoteID")},
+	"ReadQuote":    {refParam("quoteID")},
+
+	// Invoices
+	"ReadInvoice":       {refParam("invoiceNumber")},
+	"MarkInvoicePaid":   {refParam("invoiceNumber"), optionalTextParam("paymentReference", maxNameLength)},
+	"ReadCreditNote":    {refParam("creditNoteNumber")},
+	"GetAllInvoices":    {},
+	"GetAllCreditNotes": {},
+
+	// Shipments
+	"ProductShip":      {refParam("id"), dateParam("modifieddate")},
+	"ProductShipBatch": {typedParam("ids"), dateParam("modifieddate")},
+	"ShipProducts": {typedParam("productIDs"), optionalTextParam("carrier", maxNameLength), optionalTextParam("carrierOrg", maxNameLength),
+		optionalTextParam("trackingNumber", maxNameLength), optionalTextParam("origin", maxNameLength),
+		optionalTextParam("destination", maxNameLength), dateParam("modifieddate")},
+	"ShipProductsFromWarehouse": {refParam("warehouseID"), refParam("locationID"), typedParam("productIDs"),
+		optionalTextParam("carrier", maxNameLength), optionalTextParam("carrierOrg", maxNameLength),
+		optionalTextParam("trackingNumber", maxNameLength), optionalTextParam("destination", maxNameLength), dateParam("modifieddate")},
+	"ReadShipment": {refParam("shipmentID")},
+	"AddShipmentWaypoint": {refParam("shipmentID"), textParam("location", maxNameLength), optionalTimeParam("timestamp"),
+		optionalTextParam("note", maxTextLength)},
+	"SetShipmentDestination":   {refParam("shipmentID"), typedParam("latitude"), typedParam("longitude")},
+	"SubmitSensorReadings":     {refParam("shipmentID"), refParam("loggerID"), typedParam("readings")},
+	"GetShipmentSensorBatches": {refParam("shipmentID")},
+
+	// Warehouses
+	"CreateWarehouse":      {idParam("warehouseID"), textParam("name", maxNameLength), optionalTextParam("address", maxTextLength)},
+	"AddWarehouseLocation": {refParam("warehouseID"), idParam("locationID"), optionalTextParam("description", maxTextLength)},
+	"ReadWarehouse":        {refParam("warehouseID")},
+	"ReceiveStock": {refParam("warehouseID"), refParam("locationID"), refParam("productID"), typedParam("quantity"),
+		optionalTextParam("reference", maxNameLength)},
+	"PutawayStock": {refParam("warehouseID"), refParam("fromLocationID"), refParam("toLocationID"),
+		refParam("productID"), typedParam("quantity")},
+	"PickStock": {refParam("warehouseID"), refParam("locationID"), refParam("productID"), typedParam("quantity"),
+		optionalTextParam("reference", maxNameLength)},
+	"TransferStock": {refParam("fromWarehouseID"), refParam("fromLocationID"), refParam("toWarehouseID"),
+		refParam("toLocationID"), refParam("productID"), typedParam("quantity"), optionalTextParam("reference", maxNameLength)},
+	"GetWarehouseStock": {refParam("warehouseID")},
+	"GetProductStock":   {refParam("productID")},
+	"GetStockMovements": {refParam("productID")},
+
+	// Analytics and reputation
+	"GetManufacturerMetrics":    {textParam("manufacturer", maxNameLength), enumParam("period", `^(day|week)$`)},
+	"GetDeliveryPerformance":    {textParam("manufacturer", maxNameLength)},
+	"GetStatusCounts":           {optionalTextParam("manufacturer", maxNameLength)},
+	"GetManufacturerRatings":    {textParam("manufacturer", maxNameLength)},
+	"GetManufacturerReputation": {textParam("manufacturer", maxNameLength)},
+
+	// Configuration and maintenance
+	"InitLedger":        {},
+	"SetInvoiceTerms":   {typedParam("taxRateBasisPoints"), typedParam("paymentTermsDays")},
+	"GetInvoiceTerms":   {},
+	"SetSLATerms":       {typedParam("shipWithinDays"), typedParam("deliverWithinDays")},
+	"GetSLATerms":       {},
+	"SetPenaltyTerms":   {typedParam("rateBasisPointsPerDay"), typedParam("graceHours"), typedParam("capBasisPoints")},
+	"GetPenaltyTerms":   {},
+	"SetGeofencePolicy": {typedParam("radiusMeters"), enumParam("mode", `^(flag|reject)$`), typedParam("requireLocation")},
+	"GetGeofencePolicy": {},
+	"SetConditionRange": {textParam("category", maxNameLength), typedParam("minTemperature"), typedParam("maxTemperature"),
+		typedParam("minHumidity"), typedParam("maxHumidity")},
+	"GetConditionRange":     {textParam("category", maxNameLength)},
+	"SetIdentifierMode":     {enumParam("mode", `^(any|gs1)$`)},
+	"GetIdentifierMode":     {},
+	"CompactStatusCounters": {optionalTextParam("manufacturer", maxNameLength)},
+	"RebuildStatusCounters": {},
+}
+
+// parameterPatterns holds the compiled patterns of parameterRules
+var parameterPatterns = compileParameterPatterns()
+
+func compileParameterPatterns() map[string]*regexp.Regexp {
+	patterns := make(map[string]*regexp.Regexp)
+	for _, rules := range parameterRules {
+		for _, rule := range rules {
+			if rule.Pattern != "" && patterns[rule.Pattern] == nil {
+				patterns[rule.Pattern] = regexp.MustCompile(rule.Pattern)
+			}
+		}
+	}
+	return patterns
+}
+
+var appDateRegexp = regexp.MustCompile(appDatePattern)
+
+// validateParameters checks the arguments of a transaction against its rules. Transactions without rules,
+// and calls with the wrong number of arguments, are left to contractapi to reject.
+func validateParameters(function string, params []string) error {
+	rules, ok := parameterRules[function]
+	if !ok || len(rules) != len(params) {
+		return nil
+	}
+	for i, rule := range rules {
+		if err := rule.check(params[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check returns a VALIDATION_FAILED error naming the parameter and the rule it breaks
+func (rule ParameterRule) check(param string) error {
+	if param == "" {
+		if rule.Required {
+			return rule.violation("required", "%s is required", rule.Name)
+		}
+		return nil
+	}
+	if rule.MaxLength > 0 && utf8.RuneCountInString(param) > rule.MaxLength {
+		return rule.violation("maxLength", "%s must not be longer than %d characters", rule.Name, rule.MaxLength)
+	}
+	if rule.Pattern != "" && !parameterPatterns[rule.Pattern].MatchString(param) {
+		return rule.violation("pattern", "%s must match %s", rule.Name, rule.Pattern)
+	}
+
+	switch rule.Format {
+	case FormatAmount:
+		if _, err := parseAmount(param); err != nil {
+			return rule.violation("format", "%s must be an amount with at most %d decimals", rule.Name, amountDecimals)
+		}
+	case FormatDate:
+		if _, err := time.Parse(time.RFC3339, param); err != nil && !appDateRegexp.MatchString(param) {
+			return rule.violation("format", "%s must be an RFC 3339 timestamp or a date such as %q", rule.Name, "May 4th 2024, 3:04:05 pm")
+		}
+	case FormatDateTime:
+		if _, err := time.Parse(time.RFC3339, param); err != nil {
+			return rule.violation("format", "%s must be an RFC 3339 timestamp", rule.Name)
+		}
+	}
+	return nil
+}
+
+func (rule ParameterRule) violation(ruleName string, format string, args ...interface{}) error {
+	return errValidation(format, args...).withDetail("parameter", rule.Name).withDetail("rule", ruleName)
+}
@@ -0,0 +1,169 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParameterRuleCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     ParameterRule
+		param    string
+		wantRule string // the rule reported as broken, empty when the parameter is valid
+	}{
+		{name: "required", rule: idParam("id"), param: "", wantRule: "required"},
+		{name: "optional", rule: optionalTextParam("note", 10), param: ""},
+		{name: "id", rule: idParam("id"), param: "(01)10614141000415(21)ABC"},
+		{name: "id with a space", rule: idParam("id"), param: "a b", wantRule: "pattern"},
+		{name: "id too long", rule: idParam("id"), param: strings.Repeat("x", maxIDLength+1), wantRule: "maxLength"},
+		{name: "id at the maximum length", rule: idParam("id"), param: strings.Repeat("x", maxIDLength)},
+		{name: "length in characters", rule: textParam("name", 3), param: "äöü"},
+		{name: "text", rule: textParam("name", maxNameLength), param: "Green apples, 1 kg"},
+		{name: "text with a control character", rule: textParam("name", maxNameLength), param: "a\nb", wantRule: "pattern"},
+		{name: "reference with a space", rule: refParam("id"), param: "old id"},
+		{name: "enum", rule: enumParam("period", `^(day|week)$`), param: "week"},
+		{name: "enum mismatch", rule: enumParam("period", `^(day|week)$`), param: "month", wantRule: "pattern"},
+		{name: "amount", rule: priceParam(), param: "12.50"},
+		{name: "empty amount", rule: priceParam(), param: ""},
+		{name: "amount with three decimals", rule: priceParam(), param: "12.505", wantRule: "format"},
+		{name: "app date", rule: dateParam("createddate"), param: "May 4th 2024, 3:04:05 pm"},
+		{name: "RFC 3339 as app date", rule: dateParam("createddate"), param: "2024-05-04T15:04:05Z"},
+		{name: "invalid app date", rule: dateParam("createddate"), param: "4 May 2024", wantRule: "format"},
+		{name: "date-time", rule: optionalTimeParam("from"), param: "2024-05-04T15:04:05+02:00"},
+		{name: "invalid date-time", rule: optionalTimeParam("from"), param: "May 4th 2024, 3:04:05 pm", wantRule: "format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.check(tt.param)
+			if tt.wantRule == "" {
+				require.NoError(t, err)
+				return
+			}
+			RequireContractError(t, err, CodeValidationFailed)
+			details := err.(*ContractError).Details
+			require.Equal(t, tt.rule.Name, details["parameter"])
+			require.Equal(t, tt.wantRule, details["rule"])
+		})
+	}
+}
+
+func TestValidateParameters(t *testing.T) {
+	require.NoError(t, validateParameters("CreateProduct", []string{"p1", "apple", "", "1.00", "maker", "May 4th 2024, 3:04:05 pm"}))
+
+	err := validateParameters("CreateProduct", []string{"p1", "", "", "1.00", "maker", "May 4th 2024, 3:04:05 pm"})
+	RequireContractError(t, err, CodeValidationFailed)
+	require.Equal(t, "name", err.(*ContractError).Details["parameter"])
+
+	// An empty manufacturer selects the counters of all manufacturers
+	require.NoError(t, validateParameters("GetStatusCounts", []string{""}))
+	require.NoError(t, validateParameters("CompactStatusCounters", []string{""}))
+
+	// Transactions without rules and calls with the wrong number of arguments are left to contractapi
+	require.NoError(t, validateParameters("NoSuchTransaction", []string{""}))
+	require.NoError(t, validateParameters("CreateProduct", []string{""}))
+}
+
+// TestParameterRulesMatchTransactions checks every rule set against the parameters of its transaction,
+// because validateParameters skips rules whose count does not match
+func TestParameterRulesMatchTransactions(t *testing.T) {
+	chaincodeMetadata := readContractMetadata(t)
+
+	checked := make(map[string]bool)
+	for contractName, contract := range chaincodeMetadata.Contracts {
+		for _, transaction := range contract.Transactions {
+			function := transaction.Name
+			if contractName != defaultContractName {
+				function = contractName + ":" + function
+			}
+			name := transactionName(function)
+			rules, ok := parameterRules[name]
+			if !ok {
+				continue
+			}
+			checked[name] = true
+
+			require.Len(t, transaction.Parameters, len(rules), "parameters of %s", function)
+			for i, rule := range rules {
+				require.Equal(t, rule.Name, transaction.Parameters[i].Name, "parameter %d of %s", i, function)
+			}
+		}
+	}
+
+	for name := range parameterRules {
+		require.True(t, checked[name], "parameterRules lists %s, which is not a transaction", name)
+	}
+}
+
+func TestMetadataPublishesParameterRules(t *testing.T) {
+	chaincodeMetadata := readContractMetadata(t)
+
+	var createProduct *metadata.TransactionMetadata
+	for i, transaction := range chaincodeMetadata.Contracts[defaultContractName].Transactions {
+		if transaction.Name == "CreateProduct" {
+			createProduct = &chaincodeMetadata.Contracts[defaultContractName].Transactions[i]
+		}
+	}
+	require.NotNil(t, createProduct)
+
+	id := createProduct.Parameters[0]
+	require.Equal(t, "id", id.Name)
+	require.Equal(t, int64(1), *id.Schema.MinLength)
+	require.Equal(t, int64(maxIDLength), *id.Schema.MaxLength)
+	require.Equal(t, idPattern, id.Schema.Pattern)
+
+	price := createProduct.Parameters[3]
+	require.Equal(t, "price", price.Name)
+	require.Nil(t, price.Schema.MinLength)
+	require.Equal(t, FormatAmount, price.Schema.Format)
+}
+
+func readContractMetadata(t *testing.T) *metadata.ContractChaincodeMetadata {
+	t.Helper()
+	cc, err := NewChaincode(NewSmartContract(), NewProductContract(), NewOrderContract(), NewShipmentContract(), NewWarehouseContract(), NewAdminContract(), NewTokenContract())
+	require.NoError(t, err)
+
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.GetFunctionAndParametersReturns(getMetadataFunction, nil)
+	response := cc.Invoke(chaincodeStub)
+	require.Equal(t, int32(shim.OK), response.Status, response.Message)
+
+	var chaincodeMetadata metadata.ContractChaincodeMetadata
+	require.NoError(t, json.Unmarshal(response.Payload, &chaincodeMetadata))
+	return &chaincodeMetadata
+}
+
+// TestNamedContractsCoverSmartContract checks that every SmartContract transaction can also be invoked
+// through a named contract, and that every alias names a transaction of both contracts
+func TestNamedContractsCoverSmartContract(t *testing.T) {
+	chaincodeMetadata := readContractMetadata(t)
+
+	transactions := make(map[string]bool)
+	for contractName, contract := range chaincodeMetadata.Contracts {
+		for _, transaction := range contract.Transactions {
+			function := transaction.Name
+			if contractName != defaultContractName {
+				function = contractName + ":" + function
+			}
+			transactions[function] = true
+		}
+	}
+
+	aliased := make(map[string]bool)
+	for alias, name := range transactionAliases {
+		require.True(t, transactions[alias], "transactionAliases lists %s, which is not a transaction", alias)
+		require.True(t, transactions[name], "transactionAliases maps %s onto %s, which is not a transaction", alias, name)
+		aliased[name] = true
+	}
+
+	for _, transaction := range chaincodeMetadata.Contracts[defaultContractName].Transactions {
+		require.True(t, aliased[transaction.Name], "%s is not reachable through a named contract", transaction.Name)
+	}
+}
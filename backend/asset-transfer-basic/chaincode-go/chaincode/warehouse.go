@@ -0,0 +1,500 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	warehouseObjectType     = "warehouse"
+	stockObjectType         = "stock"
+	stockWarehouseIndex     = "warehouse~stock"
+	stockMovementObjectType = "stockMovement"
+
+	MovementReceive  = "receive"  // goods arrive at a location from outside
+	MovementPutaway  = "putaway"  // goods move between locations of one warehouse
+	MovementPick     = "pick"     // goods leave a location, for example into a shipment
+	MovementTransfer = "transfer" // goods move to a location of another warehouse
+)
+
+// Warehouse is a site owned by an org, divided into storage locations
+type Warehouse struct {
+	WarehouseID string               `json:"WarehouseID"`
+	Name        string               `json:"Name"`
+	Address     string               `json:"Address"`
+	OwnerOrg    string               `json:"OwnerOrg"`
+	Locations   []*WarehouseLocation `json:"Locations"`
+}
+
+// WarehouseLocation is a place inside a warehouse where stock is kept, such as a bin, shelf or dock
+type WarehouseLocation struct {
+	LocationID  string `json:"LocationID"`
+	Description string `json:"Description"`
+}
+
+// StockLevel is the quantity of a product held at one warehouse location. A serialised product is a
+// single item, so its quantity is always 1 and it is held at one location at most; only a product class,
+// identified by a GTIN without serial number, is stocked in larger quantities.
+type StockLevel struct {
+	ProductID   string `json:"ProductID"`
+	WarehouseID string `json:"WarehouseID"`
+	LocationID  string `json:"LocationID"`
+	Quantity    int64  `json:"Quantity"`
+}
+
+// StockMovement records a change to the stock of a product. Receipts have no source and picks have no
+// destination.
+type StockMovement struct {
+	TxID            string `json:"TxID"`
+	Type            string `json:"Type"`
+	ProductID       string `json:"ProductID"`
+	Quantity        int64  `json:"Quantity"`
+	FromWarehouseID string `json:"FromWarehouseID"`
+	FromLocationID  string `json:"FromLocationID"`
+	ToWarehouseID   string `json:"ToWarehouseID"`
+	ToLocationID    string `json:"ToLocationID"`
+	Reference       string `json:"Reference"` // delivery note, order or shipment the movement belongs to
+	RecordedByOrg   string `json:"RecordedByOrg"`
+	Timestamp       string `json:"Timestamp"`
+}
+
+// CreateWarehouse registers a warehouse owned by the invoking org
+func (s *SmartContract) CreateWarehouse(ctx contractapi.TransactionContextInterface, warehouseID string, name string, address string) error {
+	if warehouseID == "" {
+		return errValidation("the warehouse ID must not be empty")
+	}
+	existing, err := readWarehouse(ctx, warehouseID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return errAlreadyExists("warehouse", warehouseID)
+	}
+
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+
+	return putWarehouse(ctx, &Warehouse{
+		WarehouseID: warehouseID,
+		Name:        name,
+		Address:     address,
+		OwnerOrg:    clientOrg,
+		Locations:   []*WarehouseLocation{},
+	})
+}
+
+// AddWarehouseLocation adds a storage location to a warehouse of the invoking org
+func (s *SmartContract) AddWarehouseLocation(ctx contractapi.TransactionContextInterface, warehouseID string, locationID string, description string) error {
+	warehouse, err := ownedWarehouse(ctx, warehouseID)
+	if err != nil {
+		return err
+	}
+	if locationID == "" {
+		return errValidation("the location ID must not be empty")
+	}
+	if warehouse.location(locationID) != nil {
+		return newContractError(CodeAlreadyExists, "the warehouse %s already has a location %s", warehouseID, locationID)
+	}
+
+	warehouse.Locations = append(warehouse.Locations, &WarehouseLocation{LocationID: locationID, Description: description})
+
+	return putWarehouse(ctx, warehouse)
+}
+
+// ReadWarehouse returns a warehouse and its locations
+func (s *SmartContract) ReadWarehouse(ctx contractapi.TransactionContextInterface, warehouseID string) (*Warehouse, error) {
+	warehouse, err := readWarehouse(ctx, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	if warehouse == nil {
+		return nil, errNotFound("warehouse", warehouseID)
+	}
+	return warehouse, nil
+}
+
+// ReceiveStock books goods arriving at a location of one of the invoking org's warehouses
+func (s *SmartContract) ReceiveStock(ctx contractapi.TransactionContextInterface, warehouseID string, locationID string, productID string, quantity int64, reference string) error {
+	return s.moveStock(ctx, &StockMovement{
+		Type:          MovementReceive,
+		ProductID:     productID,
+		Quantity:      quantity,
+		ToWarehouseID: warehouseID,
+		ToLocationID:  locationID,
+		Reference:     reference,
+	})
+}
+
+// PutawayStock moves goods between two locations of one of the invoking org's warehouses, typically from
+// the receiving dock into storage
+func (s *SmartContract) PutawayStock(ctx contractapi.TransactionContextInterface, warehouseID string, fromLocationID string, toLocationID string, productID string, quantity int64) error {
+	return s.moveStock(ctx, &StockMovement{
+		Type:            MovementPutaway,
+		ProductID:       productID,
+		Quantity:        quantity,
+		FromWarehouseID: warehouseID,
+		FromLocationID:  fromLocationID,
+		ToWarehouseID:   warehouseID,
+		ToLocationID:    toLocationID,
+	})
+}
+
+// PickStock takes goods out of a location of one of the invoking org's warehouses
+func (s *SmartContract) PickStock(ctx contractapi.TransactionContextInterface, warehouseID string, locationID string, productID string, quantity int64, reference string) error {
+	return s.moveStock(ctx, &StockMovement{
+		Type:            MovementPick,
+		ProductID:       productID,
+		Quantity:        quantity,
+		FromWarehouseID: warehouseID,
+		FromLocationID:  locationID,
+		Reference:       reference,
+	})
+}
+
+// TransferStock moves goods from a location of one of the invoking org's warehouses to a location of
+// another warehouse, which may belong to another org
+func (s *SmartContract) TransferStock(ctx contractapi.TransactionContextInterface, fromWarehouseID string, fromLocationID string, toWarehouseID string, toLocationID string, productID string, quantity int64, reference string) error {
+	if fromWarehouseID == toWarehouseID {
+		return errValidation("use PutawayStock to move goods within a warehouse")
+	}
+	return s.moveStock(ctx, &StockMovement{
+		Type:            MovementTransfer,
+		ProductID:       productID,
+		Quantity:        quantity,
+		FromWarehouseID: fromWarehouseID,
+		FromLocationID:  fromLocationID,
+		ToWarehouseID:   toWarehouseID,
+		ToLocationID:    toLocationID,
+		Reference:       reference,
+	})
+}
+
+// ShipProductsFromWarehouse ships ordered products like ShipProducts, picking each of them from a location
+// of one of the invoking org's warehouses. The shipment starts at the warehouse address.
+func (s *SmartContract) ShipProductsFromWarehouse(ctx contractapi.TransactionContextInterface, warehouseID string, locationID string, productIDs []string, carrier string, carrierOrg string, trackingNumber string, destination string, modifieddate string) (string, error) {
+	warehouse, err := ownedWarehouse(ctx, warehouseID)
+	if err != nil {
+		return "", err
+	}
+
+	shipment := &Shipment{
+		Carrier:           carrier,
+		CarrierOrg:        carrierOrg,
+		TrackingNumber:    trackingNumber,
+		Origin:            warehouse.Address,
+		OriginWarehouseID: warehouseID,
+		OriginLocationID:  locationID,
+		Destination:       destination,
+	}
+	if err := s.shipProducts(ctx, productIDs, shipment, modifieddate); err != nil {
+		return "", err
+	}
+
+	for _, id := range productIDs {
+		if err := s.PickStock(ctx, warehouseID, locationID, id, 1, shipment.ShipmentID); err != nil {
+			return "", err
+		}
+	}
+
+	return shipment.ShipmentID, nil
+}
+
+// GetProductStock returns the stock of a product at every location holding some
+func (s *SmartContract) GetProductStock(ctx contractapi.TransactionContextInterface, productID string) ([]*StockLevel, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(stockObjectType, []string{productID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state by partial composite key: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var levels []*StockLevel
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over query results: %v", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+		level, err := readStockLevel(ctx, keyParts[0], keyParts[1], keyParts[2])
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, nil
+}
+
+// GetWarehouseStock returns the stock held at every location of a warehouse
+func (s *SmartContract) GetWarehouseStock(ctx contractapi.TransactionContextInterface, warehouseID string) ([]*StockLevel, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(stockWarehouseIndex, []string{warehouseID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state by partial composite key: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var levels []*StockLevel
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over query results: %v", err)
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+		level, err := readStockLevel(ctx, keyParts[2], keyParts[0], keyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, nil
+}
+
+// GetStockMovements returns every stock movement of a product, oldest first
+func (s *SmartContract) GetStockMovements(ctx contractapi.TransactionContextInterface, productID string) ([]*StockMovement, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(stockMovementObjectType, []string{productID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state by partial composite key: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var movements []*StockMovement
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over query results: %v", err)
+		}
+
+		var movement StockMovement
+		if err := json.Unmarshal(queryResponse.Value, &movement); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stock movement JSON: %v", err)
+		}
+		movements = append(movements, &movement)
+	}
+	sortMovements(movements)
+
+	return movements, nil
+}
+
+// moveStock checks a movement against the warehouses involved, updates the stock at its source and
+// destination and records it. The invoking org must own the source warehouse, or the destination of a
+// receipt.
+func (s *SmartContract) moveStock(ctx contractapi.TransactionContextInterface, movement *StockMovement) error {
+	if movement.Quantity <= 0 {
+		return errValidation("the quantity must be a positive integer")
+	}
+	exists, err := s.ProductExists(ctx, movement.ProductID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errNotFound("product", movement.ProductID)
+	}
+	if movement.FromWarehouseID == movement.ToWarehouseID && movement.FromLocationID == movement.ToLocationID {
+		return errValidation("the source and destination locations must differ")
+	}
+	if serialised(movement.ProductID) {
+		if movement.Quantity != 1 {
+			return errValidation("the product %s is a single serialised item, its quantity must be 1", movement.ProductID)
+		}
+		// A serialised item cannot be received while it is in stock somewhere else
+		if movement.FromWarehouseID == "" {
+			levels, err := s.GetProductStock(ctx, movement.ProductID)
+			if err != nil {
+				return err
+			}
+			if len(levels) > 0 {
+				return errInvalidState("the product %s is already in stock at %s/%s", movement.ProductID, levels[0].WarehouseID, levels[0].LocationID)
+			}
+		}
+	}
+
+	if movement.FromWarehouseID != "" {
+		warehouse, err := ownedWarehouse(ctx, movement.FromWarehouseID)
+		if err != nil {
+			return err
+		}
+		if warehouse.location(movement.FromLocationID) == nil {
+			return newContractError(CodeNotFound, "the warehouse %s has no location %s", movement.FromWarehouseID, movement.FromLocationID)
+		}
+		if err := addStock(ctx, movement.ProductID, movement.FromWarehouseID, movement.FromLocationID, -movement.Quantity); err != nil {
+			return err
+		}
+	}
+	if movement.ToWarehouseID != "" {
+		var warehouse *Warehouse
+		if movement.FromWarehouseID == "" {
+			warehouse, err = ownedWarehouse(ctx, movement.ToWarehouseID)
+		} else {
+			warehouse, err = s.ReadWarehouse(ctx, movement.ToWarehouseID)
+		}
+		if err != nil {
+			return err
+		}
+		if warehouse.location(movement.ToLocationID) == nil {
+			return newContractError(CodeNotFound, "the warehouse %s has no location %s", movement.ToWarehouseID, movement.ToLocationID)
+		}
+		if err := addStock(ctx, movement.ProductID, movement.ToWarehouseID, movement.ToLocationID, movement.Quantity); err != nil {
+			return err
+		}
+	}
+
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	movement.TxID = ctx.GetStub().GetTxID()
+	movement.RecordedByOrg = clientOrg
+	movement.Timestamp = now.Format(time.RFC3339)
+
+	key, err := ctx.GetStub().CreateCompositeKey(stockMovementObjectType, []string{movement.ProductID, movement.TxID})
+	if err != nil {
+		return fmt.Errorf("failed to create stock movement key: %v", err)
+	}
+	movementJSON, err := json.Marshal(movement)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stock movement JSON: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, movementJSON); err != nil {
+		return fmt.Errorf("failed to put to world state. %v", err)
+	}
+	return nil
+}
+
+// addStock changes the quantity of a product at a location, refusing to go below zero. Locations that run
+// empty are removed from the stock and warehouse index keys.
+func addStock(ctx contractapi.TransactionContextInterface, productID string, warehouseID string, locationID string, delta int64) error {
+	quantity, err := readIntState(ctx, stockObjectType, []string{productID, warehouseID, locationID})
+	if err != nil {
+		return err
+	}
+	if quantity+delta < 0 {
+		return errInvalidState("insufficient stock: %s/%s holds %d of product %s and %d are needed", warehouseID, locationID, quantity, productID, -delta)
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(stockWarehouseIndex, []string{warehouseID, locationID, productID})
+	if err != nil {
+		return fmt.Errorf("failed to create stock index key: %v", err)
+	}
+	if quantity+delta == 0 {
+		key, err := ctx.GetStub().CreateCompositeKey(stockObjectType, []string{productID, warehouseID, locationID})
+		if err != nil {
+			return fmt.Errorf("failed to create stock key: %v", err)
+		}
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return fmt.Errorf("failed to delete stock from world state: %v", err)
+		}
+		return ctx.GetStub().DelState(indexKey)
+	}
+	if quantity == 0 {
+		// Only the key is needed, the value is a placeholder as in the Fabric marbles index pattern
+		if err := ctx.GetStub().PutState(indexKey, []byte{0x00}); err != nil {
+			return fmt.Errorf("failed to put to world state. %v", err)
+		}
+	}
+
+	return writeIntState(ctx, stockObjectType, []string{productID, warehouseID, locationID}, quantity+delta)
+}
+
+func readStockLevel(ctx contractapi.TransactionContextInterface, productID string, warehouseID string, locationID string) (*StockLevel, error) {
+	quantity, err := readIntState(ctx, stockObjectType, []string{productID, warehouseID, locationID})
+	if err != nil {
+		return nil, err
+	}
+	return &StockLevel{ProductID: productID, WarehouseID: warehouseID, LocationID: locationID, Quantity: quantity}, nil
+}
+
+// serialised reports whether a product ID identifies a single item rather than a product class. Only a
+// GS1 GTIN without serial number identifies a class.
+func serialised(productID string) bool {
+	identifier, err := parseGS1Identifier(productID)
+	return err != nil || identifier.Serial != ""
+}
+
+// sortMovements orders movements by timestamp; composite keys sort them by transaction ID
+func sortMovements(movements []*StockMovement) {
+	sort.SliceStable(movements, func(i, j int) bool {
+		return movements[i].Timestamp < movements[j].Timestamp
+	})
+}
+
+// ownedWarehouse reads a warehouse and checks that the invoking org owns it
+func ownedWarehouse(ctx contractapi.TransactionContextInterface, warehouseID string) (*Warehouse, error) {
+	warehouse, err := readWarehouse(ctx, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	if warehouse == nil {
+		return nil, errNotFound("warehouse", warehouseID)
+	}
+
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if clientOrg != warehouse.OwnerOrg {
+		return nil, errForbidden("Access denied: Only the owner org can manage a warehouse")
+	}
+	return warehouse, nil
+}
+
+func (w *Warehouse) location(locationID string) *WarehouseLocation {
+	for _, location := range w.Locations {
+		if location.LocationID == locationID {
+			return location
+		}
+	}
+	return nil
+}
+
+func readWarehouse(ctx contractapi.TransactionContextInterface, warehouseID string) (*Warehouse, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(warehouseObjectType, []string{warehouseID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create warehouse key: %v", err)
+	}
+	warehouseJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if warehouseJSON == nil {
+		return nil, nil
+	}
+
+	var warehouse Warehouse
+	if err := json.Unmarshal(warehouseJSON, &warehouse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal warehouse JSON: %v", err)
+	}
+	return &warehouse, nil
+}
+
+func putWarehouse(ctx contractapi.TransactionContextInterface, warehouse *Warehouse) error {
+	key, err := ctx.GetStub().CreateCompositeKey(warehouseObjectType, []string{warehouse.WarehouseID})
+	if err != nil {
+		return fmt.Errorf("failed to create warehouse key: %v", err)
+	}
+	warehouseJSON, err := json.Marshal(warehouse)
+	if err != nil {
+		return fmt.Errorf("failed to marshal warehouse JSON: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, warehouseJSON); err != nil {
+		return fmt.Errorf("failed to put to world state. %v", err)
+	}
+	return nil
+}
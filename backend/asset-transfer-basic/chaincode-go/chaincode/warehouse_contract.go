@@ -0,0 +1,66 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// WarehouseContract groups warehouse and stock transactions under the "warehouse:" prefix
+type WarehouseContract struct {
+	contractapi.Contract
+	core SmartContract
+}
+
+// NewWarehouseContract returns the warehouse contract, invoked with the "warehouse:" prefix
+func NewWarehouseContract() *WarehouseContract {
+	return &WarehouseContract{Contract: newContract("warehouse")}
+}
+
+// Create registers a new warehouse
+func (c *WarehouseContract) Create(ctx contractapi.TransactionContextInterface, warehouseID string, name string, address string) error {
+	return c.core.CreateWarehouse(ctx, warehouseID, name, address)
+}
+
+// AddLocation adds a storage location to a warehouse
+func (c *WarehouseContract) AddLocation(ctx contractapi.TransactionContextInterface, warehouseID string, locationID string, description string) error {
+	return c.core.AddWarehouseLocation(ctx, warehouseID, locationID, description)
+}
+
+// Read returns a warehouse and its locations
+func (c *WarehouseContract) Read(ctx contractapi.TransactionContextInterface, warehouseID string) (*Warehouse, error) {
+	return c.core.ReadWarehouse(ctx, warehouseID)
+}
+
+// Receive books stock into a warehouse location
+func (c *WarehouseContract) Receive(ctx contractapi.TransactionContextInterface, warehouseID string, locationID string, productID string, quantity int64, reference string) error {
+	return c.core.ReceiveStock(ctx, warehouseID, locationID, productID, quantity, reference)
+}
+
+// Putaway moves stock between the locations of a warehouse
+func (c *WarehouseContract) Putaway(ctx contractapi.TransactionContextInterface, warehouseID string, fromLocationID string, toLocationID string, productID string, quantity int64) error {
+	return c.core.PutawayStock(ctx, warehouseID, fromLocationID, toLocationID, productID, quantity)
+}
+
+// Pick books stock out of a warehouse location
+func (c *WarehouseContract) Pick(ctx contractapi.TransactionContextInterface, warehouseID string, locationID string, productID string, quantity int64, reference string) error {
+	return c.core.PickStock(ctx, warehouseID, locationID, productID, quantity, reference)
+}
+
+// Transfer moves stock from one warehouse to another
+func (c *WarehouseContract) Transfer(ctx contractapi.TransactionContextInterface, fromWarehouseID string, fromLocationID string, toWarehouseID string, toLocationID string, productID string, quantity int64, reference string) error {
+	return c.core.TransferStock(ctx, fromWarehouseID, fromLocationID, toWarehouseID, toLocationID, productID, quantity, reference)
+}
+
+// GetProductStock returns the stock of a product in every warehouse location
+func (c *WarehouseContract) GetProductStock(ctx contractapi.TransactionContextInterface, productID string) ([]*StockLevel, error) {
+	return c.core.GetProductStock(ctx, productID)
+}
+
+// GetStock returns the stock held in a warehouse
+func (c *WarehouseContract) GetStock(ctx contractapi.TransactionContextInterface, warehouseID string) ([]*StockLevel, error) {
+	return c.core.GetWarehouseStock(ctx, warehouseID)
+}
+
+// GetMovements returns the stock movements of a product
+func (c *WarehouseContract) GetMovements(ctx contractapi.TransactionContextInterface, productID string) ([]*StockMovement, error) {
+	return c.core.GetStockMovements(ctx, productID)
+}
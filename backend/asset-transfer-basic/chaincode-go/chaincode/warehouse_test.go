@@ -0,0 +1,88 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newWarehouseFixture adds a warehouse w1 with a dock and a shelf to an escrow fixture
+func newWarehouseFixture(t *testing.T) *escrowFixture {
+	f := newEscrowFixture(t)
+	f.submit(t, "warehouse", func() error { return f.contract.CreateWarehouse(f.manufacturer, "w1", "main", "Factory Road 1") })
+	f.submit(t, "dock", func() error { return f.contract.AddWarehouseLocation(f.manufacturer, "w1", "dock", "receiving dock") })
+	f.submit(t, "shelf", func() error { return f.contract.AddWarehouseLocation(f.manufacturer, "w1", "shelf", "shelf A") })
+	return f
+}
+
+func (f *escrowFixture) stock(t *testing.T, productID string) []*StockLevel {
+	t.Helper()
+	levels, err := f.contract.GetProductStock(f.manufacturer, productID)
+	require.NoError(t, err)
+	return levels
+}
+
+func TestSerialisedProductIsStockedOnce(t *testing.T) {
+	f := newWarehouseFixture(t)
+
+	err := f.try("receive-two", func() error { return f.contract.ReceiveStock(f.manufacturer, "w1", "dock", "p1", 2, "DN-1") })
+	RequireContractError(t, err, CodeValidationFailed)
+
+	f.submit(t, "receive", func() error { return f.contract.ReceiveStock(f.manufacturer, "w1", "dock", "p1", 1, "DN-1") })
+	err = f.try("receive-again", func() error { return f.contract.ReceiveStock(f.manufacturer, "w1", "shelf", "p1", 1, "DN-2") })
+	RequireContractError(t, err, CodeInvalidState)
+
+	f.submit(t, "putaway", func() error { return f.contract.PutawayStock(f.manufacturer, "w1", "dock", "shelf", "p1", 1) })
+	require.Equal(t, []*StockLevel{{ProductID: "p1", WarehouseID: "w1", LocationID: "shelf", Quantity: 1}}, f.stock(t, "p1"))
+}
+
+func TestProductClassIsStockedInQuantities(t *testing.T) {
+	f := newWarehouseFixture(t)
+	f.submit(t, "create-class", func() error {
+		return f.contract.CreateProduct(f.manufacturer, "(01)10614141000415", "pears", "good", "5.00", "maker", "2024-01-01T00:00:00Z")
+	})
+
+	f.submit(t, "receive", func() error {
+		return f.contract.ReceiveStock(f.manufacturer, "w1", "dock", "(01)10614141000415", 5, "DN-1")
+	})
+	f.submit(t, "receive-more", func() error {
+		return f.contract.ReceiveStock(f.manufacturer, "w1", "shelf", "(01)10614141000415", 3, "DN-2")
+	})
+	f.submit(t, "pick", func() error {
+		return f.contract.PickStock(f.manufacturer, "w1", "dock", "(01)10614141000415", 2, "ORDER-1")
+	})
+
+	require.Equal(t, []*StockLevel{
+		{ProductID: "(01)10614141000415", WarehouseID: "w1", LocationID: "dock", Quantity: 3},
+		{ProductID: "(01)10614141000415", WarehouseID: "w1", LocationID: "shelf", Quantity: 3},
+	}, f.stock(t, "(01)10614141000415"))
+}
+
+func TestShipProductsFromWarehouse(t *testing.T) {
+	f := newWarehouseFixture(t)
+	f.submit(t, "receive", func() error { return f.contract.ReceiveStock(f.manufacturer, "w1", "shelf", "p1", 1, "DN-1") })
+	f.order(t, "order")
+	require.NoError(t, f.accept("accept"))
+
+	// The product is not at the dock, so nothing is shipped
+	err := f.try("ship-from-dock", func() error {
+		_, err := f.contract.ShipProductsFromWarehouse(f.manufacturer, "w1", "dock", []string{"p1"}, "carrier", "", "T1", "customer", "x")
+		return err
+	})
+	RequireContractError(t, err, CodeInvalidState)
+	require.Equal(t, "Accepted", f.product(t).Status)
+
+	var shipmentID string
+	f.submit(t, "ship", func() error {
+		shipmentID, err = f.contract.ShipProductsFromWarehouse(f.manufacturer, "w1", "shelf", []string{"p1"}, "carrier", "", "T1", "customer", "x")
+		return err
+	})
+	require.Equal(t, "Shipped", f.product(t).Status)
+	require.Empty(t, f.stock(t, "p1"))
+
+	movements, err := f.contract.GetStockMovements(f.manufacturer, "p1")
+	require.NoError(t, err)
+	require.Len(t, movements, 2)
+	require.Equal(t, MovementPick, movements[1].Type)
+	require.Equal(t, shipmentID, movements[1].Reference)
+}
@@ -9,7 +9,7 @@ echo "Up the network and creating the channel with ca"
 
 echo "Deploying ChainCode with goLang"
 
-./network.sh deployCC -ccn basic -ccp ../asset-transfer-basic/chaincode-go -ccl go
+./network.sh deployCC -ccn basic -ccp ../asset-transfer-basic/chaincode-go -ccl go -cccg ../asset-transfer-basic/chaincode-go/collections_config.json
 
 
 # echo "Exporting variables for org1"
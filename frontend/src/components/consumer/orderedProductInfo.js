@@ -16,6 +16,7 @@ function OrderedProductInfo() {
   const [productStatus, setProductStatus] = useState("");
   const [createdDate, setCreatedDate] = useState("");
   const [data, setData] = useState(null);
+  const [shipment, setShipment] = useState(null);
   const [error, setError] = useState(false);
   const [success, setSuccess] = useState(false);
   const [loader, setLoader] = useState(false);
@@ -42,6 +43,15 @@ function OrderedProductInfo() {
           setProductStatus(res.data["result"].Status);
           setCreatedDate(res.data["result"].CreatedDate);
 
+          if (res.data["result"].ShipmentID) {
+            const shipmentRes = await ManufacturerService.getShipment(
+              res.data["result"].ShipmentID
+            );
+            if (shipmentRes.data["success"]) {
+              setShipment(shipmentRes.data["result"]);
+            }
+          }
+
           console.log("uf", productName, productDescription, productPrice);
         } else {
           setError(res.data["message"]);
@@ -156,6 +166,34 @@ function OrderedProductInfo() {
           <h1 class="mt-12 mb-8 text-left font-black text-gray-700">
             Product Shipping Info
           </h1>
+          {shipment && (
+            <div class="mb-8">
+              <p class="text-base leading-4 mt-4 text-gray-600 dark:text-gray-300">
+                Shipment: {shipment.ShipmentID}
+              </p>
+              {shipment.Carrier && (
+                <p class="text-base leading-4 mt-4 text-gray-600 dark:text-gray-300">
+                  Carrier: {shipment.Carrier} ({shipment.TrackingNumber})
+                </p>
+              )}
+              <p class="text-base leading-4 mt-4 text-gray-600 dark:text-gray-300">
+                Current Location: {shipment.LastLocation || "-"}
+              </p>
+              {shipment.Destination && (
+                <p class="text-base leading-4 mt-4 text-gray-600 dark:text-gray-300">
+                  Destination: {shipment.Destination}
+                </p>
+              )}
+              <ul class="mt-4 text-sm text-gray-600 dark:text-gray-300">
+                {shipment.Waypoints.map((waypoint) => (
+                  <li key={waypoint.TxID} class="mt-2">
+                    {waypoint.Timestamp} - {waypoint.Location}
+                    {waypoint.Note && `: ${waypoint.Note}`}
+                  </li>
+                ))}
+              </ul>
+            </div>
+          )}
           <div class="flex">
             <div class="w-1/3 text-center px-6">
               <div class="bg-gray-300 rounded-lg flex items-center justify-center border border-gray-200">
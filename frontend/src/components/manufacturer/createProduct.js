@@ -47,7 +47,7 @@ function CreateItem() {
       if (res.data.success) {
         setError("");
         setSuccess("Product Created Successfully!");
-        setTokenId(res.data.message.result);
+        setTokenId(res.data.productId);
       } else {
         setSuccess("");
         setError(res.data.error.message);
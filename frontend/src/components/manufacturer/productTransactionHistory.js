@@ -20,7 +20,12 @@ function ProductTransactionHistory() {
         setLoader(false);
         if (res.data["success"]) {
           setShow(true);
-          setData(res.data["data"]);
+          // History entries wrap each product version; delete markers have no product
+          setData(
+            (res.data["data"] || [])
+              .filter((entry) => entry.Product)
+              .map((entry) => entry.Product)
+          );
           console.log(res.data["success"]);
         } else {
           setError(res.data["message"]);
@@ -60,10 +60,22 @@ function getConsumerProductOrderList() {
     });
 }
 
+function rateOrder(data) {
+  return httpService.post("rateOrder", data).then((response) => response.data);
+}
+
+function getManufacturerReputation(manufacturer) {
+  return httpService.get(
+    `getManufacturerReputation/${encodeURIComponent(manufacturer)}`
+  );
+}
+
 const ConsumerService = {
   getProductListByConsumer,
   orderProduct,
   getConsumerProductOrderList,
+  rateOrder,
+  getManufacturerReputation,
 };
 
 export default ConsumerService;
@@ -50,6 +50,21 @@ function getProductList() {
   });
 }
 
+function getManufacturerMetrics(period) {
+  let userName = localStorage.getItem("username");
+  return httpService.post("getManufacturerMetrics", {
+    userName,
+    period,
+  });
+}
+
+function getStatusCounts() {
+  let userName = localStorage.getItem("username");
+  return httpService.post("getStatusCounts", {
+    userName,
+  });
+}
+
 function getRequestedProductOrderList() {
   let userName = localStorage.getItem("username");
   return httpService
@@ -83,6 +98,10 @@ function getProductByToken(token) {
   return httpService.get(`readProduct/${token}`);
 }
 
+function getShipment(shipmentId) {
+  return httpService.get(`readShipment/${shipmentId}`);
+}
+
 function getProductTransactionByToken(token) {
   return httpService.get(`getProductHistory/${token}`);
 }
@@ -180,7 +199,10 @@ const ManufacturerService = {
   updateProduct,
   getProductByToken,
   getProductList,
+  getManufacturerMetrics,
+  getStatusCounts,
   getProductTransactionByToken,
+  getShipment,
   getRequestedProductOrderList,
   acceptProductOrder,
   shipProductOrder,